@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bitrateFlag is a flag holding a bitrate in bits per second, which can
+// be given with a k, M or G suffix.
+type bitrateFlag float64
+
+// String implements flag.Value.
+func (b *bitrateFlag) String() string {
+	return formatBitrate(float64(*b))
+}
+
+// Set implements flag.Value.
+func (b *bitrateFlag) Set(s string) error {
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		multiplier = 1e3
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return fmt.Errorf("invalid bitrate %q", s)
+	}
+	*b = bitrateFlag(v * multiplier)
+	return nil
+}
+
+// formatBitrate returns a human-readable bitrate.
+func formatBitrate(bps float64) string {
+	switch {
+	case bps >= 1e9:
+		return strconv.FormatFloat(bps/1e9, 'f', -1, 64) + "G"
+	case bps >= 1e6:
+		return strconv.FormatFloat(bps/1e6, 'f', -1, 64) + "M"
+	case bps >= 1e3:
+		return strconv.FormatFloat(bps/1e3, 'f', -1, 64) + "k"
+	}
+	return strconv.FormatFloat(bps, 'f', -1, 64)
+}
+
+// bitrateSample is the size of a packet, at its reception time.
+type bitrateSample struct {
+	at   time.Time
+	size int
+}
+
+// bitrateMeter computes the bitrate of a track over a sliding window.
+// It is not safe for concurrent use: the track mutex must be held.
+type bitrateMeter struct {
+	window  time.Duration
+	samples []bitrateSample
+	// index of the oldest packet of the window in samples.
+	head  int
+	bytes int
+}
+
+// push records a packet and returns the bitrate over the window, in bits
+// per second. During the first window, the bitrate is underestimated
+// rather than computed over a too short period.
+func (m *bitrateMeter) push(now time.Time, size int) float64 {
+	m.samples = append(m.samples, bitrateSample{at: now, size: size})
+	m.bytes += size
+
+	// Forget the packets which left the window, compacting from time to time :
+	for now.Sub(m.samples[m.head].at) >= m.window {
+		m.bytes -= m.samples[m.head].size
+		m.head++
+	}
+	if m.head > len(m.samples)/2 {
+		m.samples = append(m.samples[:0], m.samples[m.head:]...)
+		m.head = 0
+	}
+
+	return float64(m.bytes*8) / m.window.Seconds()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// bounds of -blocksize: below, the packets of video would be mostly headers;
+// above, they would not fit in a UDP datagram.
+const (
+	minBlocksize = 64
+	maxBlocksize = 65507
+)
+
+// requestBlocksize adds the Blocksize header (RFC 2326, 12.7) to the SETUP
+// requests of the client, asking the server to keep the RTP payloads of
+// every track within size bytes, so that packets aren't fragmented on
+// paths with a small MTU. The size doesn't include the RTP, UDP and IP
+// headers.
+func requestBlocksize(client *gortsplib.Client, size int) {
+	value := base.HeaderValue{strconv.Itoa(size)}
+
+	onRequest := client.OnRequest
+	client.OnRequest = func(req *base.Request) {
+		if req.Method == base.Setup {
+			req.Header["Blocksize"] = value
+		}
+		if onRequest != nil {
+			onRequest(req)
+		}
+	}
+}
+
+// acceptedBlocksize returns the Blocksize returned in a SETUP response, which
+// some servers send to confirm or lower the requested size, or zero.
+func acceptedBlocksize(res *base.Response) int {
+	if res == nil || len(res.Header["Blocksize"]) == 0 {
+		return 0
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(res.Header["Blocksize"][0]))
+	if err != nil || size <= 0 {
+		log.Printf("WARNING: invalid Blocksize header in the SETUP response: %q", res.Header["Blocksize"][0])
+		return 0
+	}
+	return size
+}
+
+// blocksizeReport tells whether the server respected the Blocksize of a
+// track, from the largest payload received.
+type blocksizeReport struct {
+	Requested int `json:"requested"`
+	// size returned in the SETUP response, if any.
+	Accepted   int  `json:"accepted,omitempty"`
+	MaxPayload int  `json:"max_payload"`
+	Respected  bool `json:"respected"`
+}
+
+// newBlocksizeReport returns the report of a track which received payloads
+// of at most maxPayload bytes. Tracks which received no packet are deemed
+// to respect the size.
+func newBlocksizeReport(requested, accepted, maxPayload int) *blocksizeReport {
+	return &blocksizeReport{
+		Requested:  requested,
+		Accepted:   accepted,
+		MaxPayload: maxPayload,
+		Respected:  maxPayload <= requested,
+	}
+}
+
+// checkBlocksize logs whether the SETUP tracks were delivered within the
+// requested Blocksize.
+func checkBlocksize(tracks []trackReport) {
+	for _, t := range tracks {
+		b := t.Blocksize
+		if b == nil || t.Packets == 0 {
+			continue
+		}
+		if !b.Respected {
+			log.Printf("WARNING: track #%d received payloads of up to %d bytes, beyond the Blocksize of %d: "+
+				"the server ignored it", t.Index, b.MaxPayload, b.Requested)
+			continue
+		}
+		log.Printf("Track #%d received payloads of up to %d bytes, within the Blocksize of %d",
+			t.Index, b.MaxPayload, b.Requested)
+	}
+}
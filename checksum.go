@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// frameHashes are the algorithms of -checksum-algorithm.
+var frameHashes = map[string]func() hash.Hash{
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// frameHashNames returns the names of the algorithms, sorted.
+func frameHashNames() []string {
+	names := make([]string, 0, len(frameHashes))
+	for name := range frameHashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// frameChecksum returns the hash of an access unit, in hex. Every NAL unit
+// of video, or the frame of audio, is hashed preceded by its size as a
+// 4-byte big-endian integer, so that the boundaries of NAL units count
+// while the packetization, which relays may change, doesn't.
+func frameChecksum(newHash func() hash.Hash, au *accessUnit) string {
+	h := newHash()
+	var size [4]byte
+	for _, u := range au.units {
+		binary.BigEndian.PutUint32(size[:], uint32(len(u)))
+		h.Write(size[:])
+		h.Write(u)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checksumManifest writes the hash of every access unit of the tracks which
+// can be depacketized into a CSV file, with the RTP timestamp and size of
+// the unit, so that the manifests of two capture points of a stream can be
+// compared.
+type checksumManifest struct {
+	mutex     sync.Mutex
+	file      *os.File
+	buf       *bufio.Writer
+	w         *csv.Writer
+	algorithm string
+	newHash   func() hash.Hash
+	frames    map[int]int
+}
+
+// newChecksumManifest creates the manifest at path, hashing units with the
+// given algorithm, and writes its header.
+func newChecksumManifest(path string, algorithm string) (*checksumManifest, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &checksumManifest{
+		file:      f,
+		buf:       bufio.NewWriter(f),
+		algorithm: algorithm,
+		newHash:   frameHashes[algorithm],
+		frames:    make(map[int]int),
+	}
+	cm.w = csv.NewWriter(cm.buf)
+	err = cm.w.Write([]string{"frame", "track", "rtp_timestamp", "size", algorithm})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return cm, nil
+}
+
+// write appends the hash of an access unit.
+func (cm *checksumManifest) write(au *accessUnit) error {
+	sum := frameChecksum(cm.newHash, au)
+	size := 0
+	for _, u := range au.units {
+		size += len(u)
+	}
+
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.frames[au.track.index]++
+	return cm.w.Write([]string{
+		strconv.Itoa(cm.frames[au.track.index]),
+		strconv.Itoa(au.track.index),
+		strconv.FormatUint(uint64(uint32(au.pts)), 10),
+		strconv.Itoa(size),
+		sum,
+	})
+}
+
+// close flushes and closes the file.
+func (cm *checksumManifest) close() error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	cm.w.Flush()
+	err := cm.w.Error()
+	if ferr := cm.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := cm.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openChecksums writes the hashes of the frames of the supported tracks
+// into the -checksum-out manifest.
+func (o *outputs) openChecksums(tracks []*track) error {
+	setupDepacketizers(tracks, nil)
+	checksums, err := newChecksumManifest(o.cfg.outputPath(o.cfg.checksumOut), o.cfg.checksumAlgorithm)
+	if err != nil {
+		return fmt.Errorf("creating checksum manifest: %w", err)
+	}
+	o.onClose(func() {
+		err := checksums.close()
+		if err != nil {
+			log.Printf("Error closing checksum manifest: %v", err)
+		}
+	})
+	queue := o.newQueue("checksum")
+
+	o.onAccessUnit(func(au *accessUnit) {
+		queue.push(func() error {
+			return checksums.write(au)
+		})
+	})
+	return nil
+}
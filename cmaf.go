@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// name of the playlist of a CMAF output.
+const cmafPlaylistName = "index.m3u8"
+
+// cmafSegment is a media segment of the playlist.
+type cmafSegment struct {
+	name     string
+	duration time.Duration
+	// initialization segment of the segment, when it differs from the one
+	// of the previous segment.
+	init string
+}
+
+// cmafSegments writes the segments of a CMAF output into a directory: the
+// initialization segment, init.mp4, and a fragment per file,
+// segment-00001.m4s, segment-00002.m4s..., listed by an HLS playlist of
+// the EVENT type, index.m3u8, rewritten after every segment. A change of
+// parameters starts a new initialization segment, init-0002.mp4..., after a
+// discontinuity. The playlist gets an end tag when the capture ends.
+type cmafSegments struct {
+	dir      string
+	inits    int
+	init     string
+	segments []cmafSegment
+}
+
+// newCMAFMuxer creates the directory of a CMAF output, receiving the SETUP
+// tracks whose codec can be written into MP4 files. Segments start on
+// keyframes of the leading track, once they last segmentDuration.
+func newCMAFMuxer(dir string, tracks []*track, segmentDuration time.Duration, warmup bool) (*mp4Muxer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mp4Muxer{
+		path:             dir,
+		fragmentDuration: segmentDuration,
+		segments:         &cmafSegments{dir: dir},
+		onFormatChange:   formatChangeRotate,
+		formatChanged:    make(chan struct{}),
+		tracks:           make(map[*track]*mp4Track),
+		warmup:           warmup,
+	}
+	err = m.addTracks(tracks, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, mt := range m.ordered {
+		log.Printf("Writing track %s to CMAF segments", mt.track)
+	}
+	return m, nil
+}
+
+// writeInit writes an initialization segment, which applies to the segments
+// which follow.
+func (s *cmafSegments) writeInit(data []byte) error {
+	s.inits++
+	name := "init.mp4"
+	if s.inits > 1 {
+		name = fmt.Sprintf("init-%04d.mp4", s.inits)
+	}
+	err := os.WriteFile(filepath.Join(s.dir, name), data, 0o644)
+	if err != nil {
+		return err
+	}
+	s.init = name
+	return nil
+}
+
+// writeSegment writes a media segment, and adds it to the playlist.
+func (s *cmafSegments) writeSegment(data []byte, duration time.Duration) error {
+	seg := cmafSegment{
+		name:     fmt.Sprintf("segment-%05d.m4s", len(s.segments)+1),
+		duration: duration,
+	}
+	if s.init != s.lastInit() {
+		seg.init = s.init
+	}
+
+	err := os.WriteFile(filepath.Join(s.dir, seg.name), data, 0o644)
+	if err != nil {
+		return err
+	}
+	s.segments = append(s.segments, seg)
+	return s.writePlaylist(false)
+}
+
+// lastInit returns the initialization segment of the last media segment.
+func (s *cmafSegments) lastInit() string {
+	for i := len(s.segments) - 1; i >= 0; i-- {
+		if s.segments[i].init != "" {
+			return s.segments[i].init
+		}
+	}
+	return ""
+}
+
+// close ends the playlist.
+func (s *cmafSegments) close() error {
+	log.Printf("%d CMAF segments written into %s", len(s.segments), s.dir)
+	return s.writePlaylist(true)
+}
+
+// writePlaylist writes the playlist, replacing the previous one at once so
+// that players never read a partial one.
+func (s *cmafSegments) writePlaylist(ended bool) error {
+	// The target duration is the longest segment, rounded up :
+	target := 1
+	for _, seg := range s.segments {
+		target = max(target, int(math.Ceil(seg.duration.Seconds())))
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", target)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:EVENT\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for i, seg := range s.segments {
+		if seg.init != "" {
+			if i != 0 {
+				b.WriteString("#EXT-X-DISCONTINUITY\n")
+			}
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\"\n", seg.init)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", seg.duration.Seconds(), seg.name)
+	}
+	if ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	path := filepath.Join(s.dir, cmafPlaylistName)
+	err := os.WriteFile(path+".tmp", []byte(b.String()), 0o644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// openCMAF segments the supported tracks into the -cmaf-out directory.
+func (o *outputs) openCMAF(tracks []*track) error {
+	cmaf, err := newCMAFMuxer(o.cfg.outputPath(o.cfg.cmafOut), tracks, o.cfg.cmafSegmentDuration,
+		o.cfg.warmupDescribe)
+	if err != nil {
+		return fmt.Errorf("creating CMAF output: %w", err)
+	}
+	o.onClose(func() {
+		err := cmaf.close()
+		if err != nil {
+			log.Printf("Error finalizing CMAF output: %v", err)
+		}
+	})
+	queue := o.newQueue("CMAF")
+
+	setupDepacketizers(tracks, func(t *track) bool {
+		_, ok := cmaf.tracks[t]
+		return ok
+	})
+	o.onAccessUnit(func(au *accessUnit) {
+		queue.push(func() error {
+			err := cmaf.writeAccessUnit(au)
+			if err != nil {
+				log.Printf("Error writing track %s to CMAF: %v", au.track, err)
+			}
+			return nil
+		})
+	})
+	return nil
+}
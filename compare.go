@@ -0,0 +1,177 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+)
+
+// sdpDifference is a difference between the SDPs of two streams. Old is
+// the value of the primary URL, New the one of the compared URL; either is
+// omitted when the media or the parameter only exists on one side.
+type sdpDifference struct {
+	Media int    `json:"media"`
+	Field string `json:"field"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// compareReport is the outcome of -compare, printed on stdout.
+type compareReport struct {
+	URL         string          `json:"url"`
+	CompareURL  string          `json:"compare_url"`
+	Identical   bool            `json:"identical"`
+	Differences []sdpDifference `json:"differences"`
+}
+
+// compareStreams DESCRIBEs the primary and the compared URLs, and prints the
+// differences between their medias. Fields which change on every DESCRIBE,
+// like the session ID of the origin, are ignored. It returns the exit code,
+// which is non-zero when the streams differ with -compare-strict.
+func compareStreams(cfg *config) int {
+	oldDesc, res, err := describeOnly(cfg, cfg.url)
+	if err != nil {
+		log.Printf("Error during DESCRIBE of %s: %v", cfg.url, err)
+		logStatusHint(res, err)
+		return 1
+	}
+	newDesc, res, err := describeOnly(cfg, cfg.compareURL)
+	if err != nil {
+		log.Printf("Error during DESCRIBE of %s: %v", cfg.compareURL, err)
+		logStatusHint(res, err)
+		return 1
+	}
+
+	report := &compareReport{
+		URL:         cfg.url,
+		CompareURL:  cfg.compareURL,
+		Differences: diffSessions(oldDesc, newDesc),
+	}
+	report.Identical = len(report.Differences) == 0
+	printJSON(os.Stdout, report, cfg.reportJSONPretty)
+
+	if report.Identical {
+		log.Println("The SDPs of both streams are equivalent")
+		return 0
+	}
+	log.Printf("The SDPs of both streams differ, %d differences found", len(report.Differences))
+	if cfg.compareStrict {
+		return 1
+	}
+	return 0
+}
+
+// describeOnly connects to the URL and returns its session description,
+// along with the DESCRIBE response when the server answered.
+func describeOnly(cfg *config, rawURL string) (*description.Session, *base.Response, error) {
+	u, err := base.ParseURL(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client := &gortsplib.Client{
+		ReadTimeout:  cfg.readTimeout,
+		WriteTimeout: cfg.readTimeout,
+	}
+	client.DialContext = (&net.Dialer{}).DialContext
+	if cfg.bindAddr != nil {
+		client.DialContext = bindDialContext(cfg.bindAddr)
+	}
+	if cfg.pinnedHost != nil {
+		client.DialContext = cfg.pinnedHost.wrap(client.DialContext)
+	}
+
+	err = client.Start(u.Scheme, u.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer client.Close()
+
+	return client.Describe(u)
+}
+
+// diffSessions returns the differences between the medias of two sessions,
+// compared in order.
+func diffSessions(oldDesc, newDesc *description.Session) []sdpDifference {
+	diffs := []sdpDifference{}
+	add := func(media int, field string, oldValue string, newValue string) {
+		if oldValue != newValue {
+			diffs = append(diffs, sdpDifference{Media: media, Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	for i := 0; i < max(len(oldDesc.Medias), len(newDesc.Medias)); i++ {
+		if i >= len(newDesc.Medias) {
+			add(i, "media", mediaSummary(oldDesc.Medias[i]), "")
+			continue
+		}
+		if i >= len(oldDesc.Medias) {
+			add(i, "media", "", mediaSummary(newDesc.Medias[i]))
+			continue
+		}
+
+		oldMedia, newMedia := oldDesc.Medias[i], newDesc.Medias[i]
+		add(i, "type", string(oldMedia.Type), string(newMedia.Type))
+		add(i, "direction", mediaDirection(oldMedia), mediaDirection(newMedia))
+		add(i, "formats", strconv.Itoa(len(oldMedia.Formats)), strconv.Itoa(len(newMedia.Formats)))
+
+		for j := 0; j < min(len(oldMedia.Formats), len(newMedia.Formats)); j++ {
+			diffFormats(oldMedia.Formats[j], newMedia.Formats[j], func(field string, oldValue, newValue string) {
+				if j != 0 {
+					field = "format" + strconv.Itoa(j) + "." + field
+				}
+				add(i, field, oldValue, newValue)
+			})
+		}
+	}
+	return diffs
+}
+
+// diffFormats reports the differences between two formats, to add.
+func diffFormats(oldFormat, newFormat format.Format, add func(field string, oldValue, newValue string)) {
+	add("codec", oldFormat.Codec(), newFormat.Codec())
+	add("payload_type", strconv.Itoa(int(oldFormat.PayloadType())), strconv.Itoa(int(newFormat.PayloadType())))
+	add("clock_rate", strconv.Itoa(oldFormat.ClockRate()), strconv.Itoa(newFormat.ClockRate()))
+	add("rtpmap", oldFormat.RTPMap(), newFormat.RTPMap())
+
+	oldFMTP, newFMTP := oldFormat.FMTP(), newFormat.FMTP()
+	keys := make(map[string]struct{})
+	for k := range oldFMTP {
+		keys[k] = struct{}{}
+	}
+	for k := range newFMTP {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		add("fmtp."+k, oldFMTP[k], newFMTP[k])
+	}
+}
+
+// mediaSummary describes a media in a single string.
+func mediaSummary(medi *description.Media) string {
+	s := string(medi.Type)
+	for _, forma := range medi.Formats {
+		s += "/" + forma.Codec()
+	}
+	return s
+}
+
+// mediaDirection returns the direction of a media.
+func mediaDirection(medi *description.Media) string {
+	if medi.IsBackChannel {
+		return "sendonly"
+	}
+	return "recvonly"
+}
@@ -0,0 +1,1170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// intListFlag is a flag holding a comma-separated list of integers.
+type intListFlag []int
+
+// String implements flag.Value.
+func (l *intListFlag) String() string {
+	strs := make([]string, len(*l))
+	for i, v := range *l {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, ",")
+}
+
+// Set implements flag.Value.
+func (l *intListFlag) Set(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", part)
+		}
+		*l = append(*l, v)
+	}
+	return nil
+}
+
+// config holds the options given on the command line.
+type config struct {
+	// RTSP URL of the source :
+	url string
+
+	// Time a SETUP track may stay silent after PLAY before it is
+	// reported as advertised but dead :
+	stallTimeout time.Duration
+
+	// Timeout of the reads and writes on the connection, while streaming
+	// and for every request :
+	readTimeout time.Duration
+
+	// Maximum duration of the DESCRIBE and SETUP phases, or zero to rely
+	// on readTimeout alone :
+	describeTimeout time.Duration
+	setupTimeout    time.Duration
+
+	// Maximum number of SETUP requests in flight, or zero for no limit. The
+	// tracks are set up one at a time on the RTSP connection, so that any
+	// limit is already met :
+	maxConcurrentSetups int
+
+	// Time to wait for the responses to some methods, instead of
+	// readTimeout :
+	methodTimeouts methodTimeoutFlag
+
+	// Retry DESCRIBE up to describeRetryLimit times, describeRetryDelay
+	// apart, while the server returns an empty SDP :
+	describeRetryOnEmptySDP bool
+	describeRetryLimit      int
+	describeRetryDelay      time.Duration
+
+	// Retry DESCRIBE and SETUP when answered with a 5xx status :
+	serverErrorRetry serverErrorRetry
+
+	// Limits on the SDP returned by the server :
+	maxSDPSize int
+	maxMedias  int
+
+	// Base URL and strategy used to resolve the control attributes of the
+	// medias into SETUP URLs. The base URL of the DESCRIBE response is used
+	// when controlBase is nil :
+	controlBase *base.URL
+	controlMode string
+
+	// rtpmap used for dynamic payload types whose rtpmap is missing or
+	// can't be mapped to a codec :
+	payloadMap payloadMapFlag
+
+	// Indexes of the tracks to SETUP. All tracks when empty :
+	tracks intListFlag
+
+	// Names of some tracks, by track index, shown in the logs and outputs :
+	trackNames trackNameFlag
+
+	// Absolute times of some RTP timestamps, by track index, used instead of
+	// RTCP sender reports to time the packets of these tracks :
+	rtpEpochs rtpEpochFlag
+
+	// Stop after SETUP, without sending PLAY :
+	noPlay bool
+
+	// Abort with an error at the first warning about the setup of the
+	// tracks or their packets, see failFast :
+	failFast bool
+
+	// Only DESCRIBE the URL and compareURL, and print the differences
+	// between their SDPs, failing when they differ with compareStrict :
+	compareURL    string
+	compareStrict bool
+
+	// Only connect and send OPTIONS, within connectTimeout :
+	connectOnly    bool
+	connectTimeout time.Duration
+
+	// Minimum number of packets that every SETUP track must receive for
+	// the capture to succeed, or zero :
+	minPackets uint64
+
+	// Largest RTP payload requested in SETUP, or zero :
+	blocksize int
+
+	// Delivery speed requested in PLAY, or zero :
+	speed float64
+
+	// Only DESCRIBE a list of common paths, or the ones of pathsFile, on
+	// the server of the URL, with at most probeConcurrency requests in
+	// flight, started at least probeInterval apart :
+	probeAll         bool
+	pathsFile        string
+	probeConcurrency int
+	probeInterval    time.Duration
+
+	// Don't send the periodic keepalives of the session :
+	disableKeepalive bool
+
+	// Send Generic NACKs (RFC 4585) for the packets found missing, on the
+	// tracks which negotiate them :
+	sendNACK bool
+	// Maximum time the packets following a gap are held, on tracks with
+	// retransmissions, while waiting for the retransmission of the gap :
+	rtxReorderWait time.Duration
+
+	// Measure the round-trip time to the server from RTCP Extended Reports :
+	measureRTT bool
+
+	// Drop duplicated RTP packets, remembering the last dedupWindow
+	// packets of every track :
+	dedup       bool
+	dedupWindow int
+
+	// Drop simulateLoss percent of the packets at random before the
+	// outputs, from a generator seeded with lossSeed :
+	simulateLoss float64
+	lossSeed     uint64
+
+	// Dump the raw SDP and the outcome of every SETUP when SETUP or PLAY
+	// fails, into dumpSDPPath or on stderr :
+	dumpSDPOnError bool
+	dumpSDPPath    string
+
+	// Warn when the bitrate of a track, over bitrateWindow, exceeds
+	// maxBitrate bits per second :
+	maxBitrate    bitrateFlag
+	bitrateWindow time.Duration
+
+	// Time after PLAY during which startup transients are excluded from
+	// the bitrate alerts and peaks, and from the jitter estimate :
+	warmup time.Duration
+
+	// Check the structure of every RTP packet :
+	validateRTP bool
+
+	// Output only one packet every sampleEvery packets of each track :
+	sampleEvery int
+
+	// Stop streaming after this duration, or run until interrupted when zero :
+	duration time.Duration
+
+	// Run bounded captures repeatedly, waiting loopInterval between them :
+	loop         bool
+	loopInterval time.Duration
+
+	// Windows of the day during which captures run, in their time zone,
+	// always when nil :
+	schedule *captureSchedule
+
+	// Print nothing but the final report, on stdout :
+	summaryOnly bool
+
+	// Analyze and report the GOP structure of the video tracks :
+	gopReport bool
+
+	// Warn about video tracks which seem black or frozen, and G.711 tracks
+	// which seem silent, over contentWindow, from their payloads. Video
+	// frames are black when they are all smaller than blackFrameSize bytes,
+	// and audio is silent below silenceLevel dBFS :
+	contentCheck   bool
+	contentWindow  time.Duration
+	blackFrameSize int
+	silenceLevel   float64
+
+	// Measure the loudness and the true peak of the audio tracks :
+	measureLoudness bool
+
+	// Outputs of the packets: the log, and an NDJSON file. Each output,
+	// including the files below, queues up to sinkBuffer writes, and drops
+	// or waits beyond depending on writeOverflow :
+	logPackets    bool
+	ndjsonOut     string
+	sinkBuffer    int
+	writeOverflow string
+
+	// Format of the packets written into the NDJSON file :
+	outFormat string
+
+	// Size of the write buffer of the NDJSON file, and interval at which
+	// it is flushed, or zero to flush it only when full :
+	outputBufferSize int
+	flushInterval    time.Duration
+
+	// Replace the timestamps of the packets in the outputs with frame and
+	// packet indexes, optionally keeping the original values :
+	normalizeTimestamps    bool
+	keepOriginalTimestamps bool
+
+	// Path of the fragmented MP4 file to record, and when to move on to
+	// a new file :
+	mp4Out      string
+	mp4Rotation mp4Rotation
+	// What to do when the parameters of a recorded video track change :
+	onFormatChange string
+
+	// Only record the video, or the audio tracks into the MP4 file :
+	mp4VideoOnly bool
+	mp4AudioOnly bool
+
+	// Create the MP4 files and CMAF segments once the parameters of the
+	// video tracks are known, buffering the units received meanwhile :
+	warmupDescribe bool
+
+	// Directory receiving the CMAF segments and their playlist, and minimum
+	// duration of the segments :
+	cmafOut             string
+	cmafSegmentDuration time.Duration
+
+	// Path of the file receiving the RTSP requests and responses, with the
+	// credentials masked unless traceNoRedact is set, and the size after
+	// which a new file is started :
+	traceFile       string
+	traceNoRedact   bool
+	traceRotateSize int64
+
+	// Path of the Chrome trace file receiving the reassembly timeline of
+	// every frame, rotated with traceRotateSize :
+	traceFrames string
+
+	// Write a CSV line per frame of the tracks which can be depacketized :
+	frameTimelineOut string
+
+	// Hash every frame of the tracks which can be depacketized, into the
+	// frame timeline and a manifest :
+	verifyChecksums   bool
+	checksumAlgorithm string
+	checksumOut       string
+
+	// Write the SEI messages of the H264 and H265 tracks into a file :
+	seiOut string
+
+	// What to do when depacketization fails, see decodeErrorHandler :
+	onDecodeError string
+
+	// Path of the file receiving the packets on which depacketization
+	// failed, and maximum number of packets written into it :
+	decodeErrorDump    string
+	decodeErrorDumpMax int
+
+	// Path of the file receiving the documents of ONVIF metadata tracks :
+	metadataOut string
+
+	// Path of the WebVTT file receiving the captions of ONVIF metadata tracks :
+	webvttOut string
+
+	// Path of the pcapng file receiving the RTP packets of every track :
+	pcapngOut string
+
+	// Path of the file receiving the raw RTP payloads of a track, each one
+	// prefixed with its length when rawPayloadFramed is set, and whether the
+	// index of its frames is written alongside :
+	rawPayloadOut    string
+	rawPayloadTrack  int
+	rawPayloadFramed bool
+	rawPayloadIndex  bool
+
+	// Ignore RTCP entirely, or only process the RTCP of some tracks :
+	noRTCP     bool
+	rtcpTracks intListFlag
+
+	// Go on without RTCP for the UDP tracks whose RTCP port can't be bound :
+	rtcpOptional bool
+
+	// Warn when the clocks of two tracks drift apart by more than this
+	// number of ppm, or never when zero :
+	maxClockDrift float64
+
+	// Log the stats of the tracks every statsInterval. In on-change mode,
+	// only when they changed, or at least every statsHeartbeat :
+	statsInterval  time.Duration
+	statsOnChange  bool
+	statsHeartbeat time.Duration
+
+	// Push the metrics of the tracks to the StatsD server at statsdAddr
+	// every statsdInterval, under statsdPrefix :
+	statsdAddr     string
+	statsdInterval time.Duration
+	statsdPrefix   string
+
+	// Push the metrics of the tracks to the Graphite server at graphiteAddr
+	// every graphiteInterval, under graphitePrefix :
+	graphiteAddr     string
+	graphiteInterval time.Duration
+	graphitePrefix   string
+
+	// Serve the access units of the tracks over gRPC on grpcAddr :
+	grpcAddr string
+
+	// Master key and salt decrypting the SRTP tracks, instead of the keys
+	// declared in the SDP :
+	srtpKey *srtpKey
+
+	// Local address the connection and the UDP sockets are bound to :
+	bindAddr net.IP
+
+	// Resolve the host of the URL once, at startup, and connect to that
+	// address for the whole session. pinnedHost is set by start :
+	resolveOnce bool
+	pinnedHost  *pinnedHost
+
+	// TTL of the multicast packets (the RTCP receiver reports of multicast
+	// tracks) and DSCP of the UDP packets sent by the client, or zero for
+	// the system defaults :
+	multicastTTL int
+	dscp         int
+
+	// Indent the JSON of the packets, and of the other outputs (SDP,
+	// summaries, stats and reports) :
+	packetJSONPretty bool
+	reportJSONPretty bool
+
+	// Start a new session when packets don't match the SDP anymore and
+	// the SDP changed, and whether the last session ended for that :
+	followSDPUpdate bool
+	sdpChanged      atomic.Bool
+
+	// Start a new session when the server announces that it closes the
+	// connection while streaming, and whether the last session ended for
+	// that, see connectionClose :
+	reconnect    bool
+	reconnecting atomic.Bool
+
+	// Numbers of the output files, written as a series with -loop,
+	// -follow-sdp-update, -reconnect, -schedule and the MP4 rotation :
+	files fileNumbers
+
+	// Flatten the JSON of the packets into single-level objects :
+	jsonFlatten bool
+
+	// Fields of the packets kept in the log and the NDJSON file, all of
+	// them when nil :
+	jsonFields *fieldProjection
+
+	// Destinations of the RTP packets forwarded as is over UDP :
+	forwardUDP udpForwardFlag
+
+	// Template of the line printed and written for every packet, instead
+	// of JSON, with -output-template :
+	outputTemplate *template.Template
+
+	// Read commands from the standard input, and whether printing packets
+	// was muted by them :
+	interactive bool
+	muted       atomic.Bool
+}
+
+// parseFlags parses the command line into a config.
+// It exits the program on invalid usage.
+func parseFlags() *config {
+	cfg := &config{}
+
+	flag.DurationVar(&cfg.stallTimeout, "stall-timeout", 10*time.Second,
+		"time a track may stay silent after PLAY before it is reported as dead")
+	flag.DurationVar(&cfg.readTimeout, "read-timeout", 5*time.Second,
+		"timeout of the reads and writes on the connection, including while streaming")
+	flag.DurationVar(&cfg.describeTimeout, "describe-timeout", 0,
+		"maximum duration of the DESCRIBE phase (default: only bounded by -read-timeout)")
+	flag.DurationVar(&cfg.setupTimeout, "setup-timeout", 0,
+		"maximum duration of the SETUP phase, for all tracks (default: only bounded by -read-timeout)")
+	flag.IntVar(&cfg.maxConcurrentSetups, "max-concurrent-setups", 0,
+		"maximum number of SETUP requests in flight (default: no limit); accepted for compatibility, as the\n"+
+			"tracks are always set up one at a time, each SETUP waiting for the response to the previous one")
+	flag.Var(&cfg.methodTimeouts, "method-timeout",
+		"time to wait for the response to OPTIONS, DESCRIBE, SETUP or PLAY instead of -read-timeout, in the\n"+
+			"METHOD=duration form (e.g. SETUP=10s); can be repeated, or given as a comma-separated list;\n"+
+			"-read-timeout applies again from the response to PLAY, to the media flow")
+	flag.BoolVar(&cfg.describeRetryOnEmptySDP, "describe-retry-on-empty-sdp", false,
+		"when DESCRIBE succeeds with an empty SDP, or one without any media, as some devices do\n"+
+			"while starting, send DESCRIBE again after -describe-retry-delay, up to -describe-retry-limit times")
+	flag.IntVar(&cfg.describeRetryLimit, "describe-retry-limit", 5,
+		"with -describe-retry-on-empty-sdp, maximum number of retries")
+	flag.DurationVar(&cfg.describeRetryDelay, "describe-retry-delay", 1*time.Second,
+		"with -describe-retry-on-empty-sdp, time to wait before every retry")
+	flag.BoolVar(&cfg.serverErrorRetry.enabled, "retry-describe-on-5xx", false,
+		"send DESCRIBE and SETUP again when the server answers with a 5xx status (e.g. 503 when overloaded),\n"+
+			"waiting -retry-5xx-delay, doubled after every retry; 4xx statuses and connection errors are not retried")
+	flag.IntVar(&cfg.serverErrorRetry.limit, "retry-5xx-limit", 3,
+		"with -retry-describe-on-5xx, maximum number of retries of a request")
+	flag.DurationVar(&cfg.serverErrorRetry.delay, "retry-5xx-delay", time.Second,
+		"with -retry-describe-on-5xx, time to wait before the first retry, or longer when the server\n"+
+			"asks for it with Retry-After (at most 30s)")
+	flag.IntVar(&cfg.maxSDPSize, "max-sdp-size", sdpMaxSize,
+		"reject SDPs bigger than this number of bytes; it can't exceed the default")
+	flag.IntVar(&cfg.maxMedias, "max-medias", sdpMaxMedias,
+		"reject SDPs declaring more medias than this")
+	controlBase := flag.String("control-base", "",
+		"base URL against which the control attributes of the SDP are resolved\n"+
+			"(default: the Content-Base of the DESCRIBE response, or the URL)")
+	flag.StringVar(&cfg.controlMode, "control-mode", controlModeAppend,
+		"how control attributes are resolved into SETUP URLs: append (appended to the base URL),\n"+
+			"relative (RFC 3986 relative reference) or absolute (from the root of the server)")
+	flag.Var(&cfg.payloadMap, "payload-map",
+		"interpret a dynamic payload type as the given codec, ignoring its rtpmap, in the\n"+
+			"PT=codec/clockrate[/channels] form; codecs are H264, H265 and MPEG4-GENERIC (repeatable)")
+	flag.Var(&cfg.tracks, "tracks",
+		"comma-separated indexes of the tracks to SETUP (default: all tracks)")
+	flag.Var(&cfg.trackNames, "track-name",
+		"name of a track, in the index=name form, shown in the logs, outputs, stats and reports\n"+
+			"along with its index (repeatable)")
+	flag.Var(&cfg.forwardUDP, "forward-udp",
+		"send every RTP packet as received to this UDP destination, in the host:port form, or the packets\n"+
+			"of a track in the track=host:port form, e.g. for ffmpeg's udp:// input (repeatable)")
+	flag.Var(&cfg.rtpEpochs, "rtp-epoch",
+		"absolute time of an RTP timestamp of a track, in the track=rtp_ts@time form with an\n"+
+			"RFC 3339 time, used instead of RTCP sender reports to time its packets (repeatable)")
+	flag.BoolVar(&cfg.noPlay, "no-play", false,
+		"perform CONNECT, DESCRIBE and SETUP, report the negotiated transports and exit without PLAY")
+	flag.BoolVar(&cfg.failFast, "fail-fast", false,
+		"abort with exit code 1 at the first of these warnings: a selected track can't be SETUP,\n"+
+			"an RTCP port can't be bound (-rtcp-optional), a codec can't be extracted by an enabled output,\n"+
+			"packets of a payload type that the SDP doesn't declare, a SETUP track receives no packet\n"+
+			"within -stall-timeout")
+	flag.StringVar(&cfg.compareURL, "compare", "",
+		"DESCRIBE both the URL and this other URL, print the differences between their medias\n"+
+			"(codecs, clock rates, fmtp parameters, added or removed tracks) and exit")
+	flag.BoolVar(&cfg.compareStrict, "compare-strict", false,
+		"with -compare, exit with 1 when the SDPs differ")
+	flag.BoolVar(&cfg.connectOnly, "connect-only", false,
+		"only connect and send OPTIONS, print the round-trip time and exit with 0 when the server answered;\n"+
+			"a lightweight liveness probe")
+	flag.DurationVar(&cfg.connectTimeout, "connect-timeout", 800*time.Millisecond,
+		"with -connect-only, timeout of the connection and of the OPTIONS request")
+	flag.IntVar(&cfg.blocksize, "blocksize", 0,
+		"send the Blocksize header in SETUP, asking the server to keep RTP payloads within this number\n"+
+			"of bytes to avoid IP fragmentation, and report the largest payload received per track")
+	flag.Float64Var(&cfg.speed, "speed", 0,
+		"ask the server to deliver the stream at this multiple of real time, with the Speed header of PLAY,\n"+
+			"e.g. 4 to download recordings faster; timestamps are unchanged, unlike with Scale\n"+
+			"(default: real time)")
+	flag.Uint64Var(&cfg.minPackets, "min-packets", 0,
+		"exit with 1 unless every SETUP track received at least this many packets by the end of the\n"+
+			"capture; with -duration, a probe telling a stream which flows steadily from stray packets")
+	flag.BoolVar(&cfg.probeAll, "probe-all", false,
+		"DESCRIBE a built-in list of common stream paths on the server of the URL, whose path is ignored,\n"+
+			"print which ones return a valid SDP and exit; to discover the stream URL of a device")
+	flag.StringVar(&cfg.pathsFile, "paths-file", "",
+		"with -probe-all, file listing the paths to try instead, one per line")
+	flag.IntVar(&cfg.probeConcurrency, "probe-concurrency", 2,
+		"with -probe-all, maximum number of DESCRIBE requests in flight")
+	flag.DurationVar(&cfg.probeInterval, "probe-interval", 200*time.Millisecond,
+		"with -probe-all, minimum time between the starts of two DESCRIBE requests")
+	flag.BoolVar(&cfg.disableKeepalive, "disable-keepalive", false,
+		"don't send the periodic OPTIONS or GET_PARAMETER keepalives, for servers which close the session\n"+
+			"when they receive one; the session then relies on the media flow (not supported with rtsps://)")
+	flag.BoolVar(&cfg.sendNACK, "send-nack", false,
+		"on tracks whose SDP negotiates Generic NACK feedback (a=rtcp-fb nack), send RTCP NACKs\n"+
+			"requesting the retransmission of lost packets; the server must support it")
+	flag.DurationVar(&cfg.rtxReorderWait, "rtx-reorder-wait", 200*time.Millisecond,
+		"on tracks with retransmissions (RFC 4588), maximum time the packets following a gap are held\n"+
+			"so that the outputs get its retransmission in sequence order; this delays the outputs of these\n"+
+			"tracks by as much when packets are lost, and retransmissions arriving later are delivered out of order")
+	flag.BoolVar(&cfg.measureRTT, "measure-rtt", false,
+		"measure the round-trip time to the server by sending receiver reference times in RTCP Extended\n"+
+			"Reports (RFC 3611), and report its minimum, maximum and rolling average; the server must echo them")
+	flag.BoolVar(&cfg.dedup, "dedup", false,
+		"drop RTP packets whose (SSRC, sequence number) was already received")
+	flag.IntVar(&cfg.dedupWindow, "dedup-window", 512,
+		"number of recent packets per track remembered by -dedup")
+	flag.Float64Var(&cfg.simulateLoss, "simulate-loss", 0,
+		"drop this percentage of the RTP packets at random before they reach the outputs, to test how\n"+
+			"consumers handle gaps; reception and its statistics are not affected")
+	flag.Uint64Var(&cfg.lossSeed, "loss-seed", 1,
+		"seed of -simulate-loss: the same seed drops the same packets of the same stream")
+	flag.BoolVar(&cfg.dumpSDPOnError, "dump-sdp-on-error", false,
+		"when SETUP or PLAY fails, dump the raw SDP and the outcome of every SETUP, for bug reports")
+	flag.StringVar(&cfg.dumpSDPPath, "dump-sdp-path", "",
+		"file receiving the dump of -dump-sdp-on-error (default: stderr)")
+	flag.Var(&cfg.maxBitrate, "max-bitrate",
+		"warn when the bitrate of a track exceeds this number of bits per second, with an optional\n"+
+			"k, M or G suffix; the peak bitrate of every track is reported in any case (default: no limit)")
+	flag.DurationVar(&cfg.bitrateWindow, "bitrate-window", time.Second,
+		"sliding window over which bitrates are computed")
+	flag.DurationVar(&cfg.warmup, "warmup", 0,
+		"time after PLAY during which metrics are collected but excluded from the bitrate alerts and peaks\n"+
+			"and from the jitter estimate, so that startup transients don't trip alarms")
+	flag.BoolVar(&cfg.validateRTP, "validate-rtp", false,
+		"check the structure of every RTP packet, and log and count the malformed ones")
+	flag.IntVar(&cfg.sampleEvery, "sample-every", 1,
+		"output only every Nth packet of each track (all packets are still counted)")
+	flag.DurationVar(&cfg.duration, "duration", 0,
+		"stop streaming after this duration (default: run until interrupted)")
+	flag.BoolVar(&cfg.loop, "loop", false,
+		"with -duration, run captures indefinitely, with one final report each; the output files of every\n"+
+			"capture are numbered (rec.mp4 gives rec-0001.mp4, rec-0002.mp4...)")
+	flag.DurationVar(&cfg.loopInterval, "loop-interval", time.Minute,
+		"with -loop, time to wait between the end of a capture and the start of the next one")
+	flag.BoolVar(&cfg.summaryOnly, "summary-only", false,
+		"print no packet nor stats, only the final report as a single JSON object on stdout;\n"+
+			"logs go to stderr")
+	flag.BoolVar(&cfg.gopReport, "gop-report", false,
+		"report the I/P/B frame pattern and the GOP length of the H264 and H265 tracks,\n"+
+			"and warn when B-frames are present")
+	flag.BoolVar(&cfg.contentCheck, "content-check", false,
+		"warn when video tracks seem black or frozen, or G.711 tracks silent; these are heuristics\n"+
+			"on the sizes of the frames and on the audio samples, without decoding, which static\n"+
+			"scenes or quiet rooms can trigger")
+	flag.DurationVar(&cfg.contentWindow, "content-window", 5*time.Second,
+		"with -content-check, window over which the content is checked")
+	flag.IntVar(&cfg.blackFrameSize, "black-frame-size", 1500,
+		"with -content-check, size in bytes below which all the frames of a window, keyframes\n"+
+			"included, suggest a black picture")
+	flag.Float64Var(&cfg.silenceLevel, "silence-level", -60,
+		"with -content-check, peak level in dBFS below which a window of G.711 audio is silent")
+	flag.BoolVar(&cfg.measureLoudness, "measure-loudness", false,
+		"decode the audio tracks and report their integrated loudness (LUFS) and true peak (dBTP)\n"+
+			"over the capture, as measured by EBU R128, in the final report; only G.711 and L16/L24 are\n"+
+			"decoded: AAC and Opus tracks are skipped with a warning, as the program has no decoder for them")
+	flag.BoolVar(&cfg.logPackets, "log-packets", true,
+		"print every packet in the log")
+	flag.StringVar(&cfg.ndjsonOut, "ndjson-out", "",
+		"write every packet into this file, as one JSON object per line")
+	flag.StringVar(&cfg.outFormat, "out-format", outFormatJSON,
+		"format of the packets written into -ndjson-out: json (one object per line) or protobuf\n"+
+			"(PacketRecord messages of packet.proto, each one preceded by its size as a varint)")
+	flag.IntVar(&cfg.outputBufferSize, "output-buffer-size", 4096,
+		"size in bytes of the write buffer of -ndjson-out; larger buffers make fewer system calls:\n"+
+			"65536 or more suits high-rate streams (video of several Mbit/s, many tracks)")
+	flag.DurationVar(&cfg.flushInterval, "flush-interval", 0,
+		"flush the write buffer of -ndjson-out at this interval, so that the file follows low-rate\n"+
+			"streams closely, e.g. 1s for audio or metadata (default: only when the buffer is full);\n"+
+			"the buffer is always flushed on shutdown")
+	flag.BoolVar(&cfg.normalizeTimestamps, "normalize-timestamps", false,
+		"replace the RTP timestamp of every packet in the outputs with the index of its frame in the track,\n"+
+			"add the index of the packet in the track and drop wall-clock times, so that captures are\n"+
+			"reproducible and can be diffed")
+	flag.BoolVar(&cfg.keepOriginalTimestamps, "keep-original-timestamps", false,
+		"with -normalize-timestamps, keep the original RTP timestamp and wall-clock times in dedicated fields")
+	flag.IntVar(&cfg.sinkBuffer, "sink-buffer", 4096,
+		"number of writes queued by each output (log, NDJSON, MP4, metadata, WebVTT, raw payload,\n"+
+			"frame trace and decode error dump files)")
+	flag.StringVar(&cfg.writeOverflow, "write-overflow", writeOverflowDrop,
+		"what to do when the queue of an output is full: drop (drop the write, reception is never delayed)\n"+
+			"or block (wait for the output, at the risk of losing packets in the network)")
+	flag.StringVar(&cfg.mp4Out, "mp4-out", "",
+		"record the H264, H265 and AAC tracks into this fragmented MP4 file, or - for the standard output,\n"+
+			"to pipe it into another program such as ffmpeg -i -")
+	flag.BoolVar(&cfg.mp4VideoOnly, "mp4-video-only", false,
+		"with -mp4-out, only record the video tracks, for instance when the audio codec is not supported")
+	flag.BoolVar(&cfg.mp4AudioOnly, "mp4-audio-only", false,
+		"with -mp4-out, only record the audio tracks")
+	flag.BoolVar(&cfg.warmupDescribe, "warmup-describe", false,
+		"with -mp4-out or -cmaf-out, for cameras sending their parameter sets (SPS, PPS) in-band only:\n"+
+			"create the output once the parameters of every video track are known, buffering up to 10s of\n"+
+			"units meanwhile, so that it starts from the first keyframe they make decodable")
+	flag.DurationVar(&cfg.mp4Rotation.interval, "mp4-rotate-interval", 0,
+		"with -mp4-out, start a new file after this duration; files are numbered from the -mp4-out path,\n"+
+			"in a single series with the files of the sessions of -loop (rec-0001.mp4, rec-0002.mp4...)")
+	flag.Int64Var(&cfg.mp4Rotation.size, "mp4-rotate-size", 0,
+		"with -mp4-out, start a new file once this number of bytes was written")
+	flag.BoolVar(&cfg.mp4Rotation.onKeyframe, "rotate-on-keyframe", false,
+		"with -mp4-rotate-interval or -mp4-rotate-size, only start a new file on a keyframe,\n"+
+			"so that every file is playable on its own")
+	flag.StringVar(&cfg.cmafOut, "cmaf-out", "",
+		"segment the H264, H265 and AAC tracks into CMAF (fragmented MP4) files in this directory:\n"+
+			"init.mp4 and segment-NNNNN.m4s, starting on keyframes, listed by an HLS playlist, index.m3u8,\n"+
+			"updated after every segment; a change of parameters starts a new initialization segment")
+	flag.DurationVar(&cfg.cmafSegmentDuration, "cmaf-segment-duration", 2*time.Second,
+		"with -cmaf-out, minimum duration of the segments, which end on the next keyframe")
+	flag.StringVar(&cfg.onFormatChange, "on-format-change", formatChangeRotate,
+		"with -mp4-out, what to do when the parameters of a video track change (e.g. its resolution), which\n"+
+			"the samples written on would not match: rotate (complete the file and start a new one, numbered\n"+
+			"from the -mp4-out path, on the next keyframe), continue (write on, players may fail to decode them)\n"+
+			"or fail (stop the capture with an error); the standard output can't be rotated, and fails;\n"+
+			"with -loop, -follow-sdp-update, -reconnect or -schedule, every session starts a new file of\n"+
+			"the series anyway")
+	flag.StringVar(&cfg.traceFile, "trace-file", "",
+		"append every RTSP request and response to this file, as one JSON object per line,\n"+
+			"with the credentials masked")
+	flag.BoolVar(&cfg.traceNoRedact, "trace-no-redact", false,
+		"with -trace-file, keep the credentials in the trace")
+	flag.Int64Var(&cfg.traceRotateSize, "trace-rotate-size", 0,
+		"with -trace-file or -trace-frames, start a new file once this number of bytes was written, to\n"+
+			"bound the size of the files; files are numbered from the -trace-file or -trace-frames path, and\n"+
+			"every file of -trace-frames is a complete trace")
+	flag.StringVar(&cfg.traceFrames, "trace-frames", "",
+		"write the reassembly timeline of every frame of the H264, H265 and AAC tracks (first packet,\n"+
+			"last packet, output of the depacketizer) into this file, in the Chrome trace format\n"+
+			"opened by chrome://tracing and Perfetto")
+	flag.StringVar(&cfg.frameTimelineOut, "frame-timeline-out", "",
+		"write a CSV line per frame of the H264, H265 and AAC tracks into this file: its number, track,\n"+
+			"keyframe flag, RTP timestamp, NPT, NTP and reception times, and size in bytes")
+	flag.BoolVar(&cfg.verifyChecksums, "verify-checksums", false,
+		"hash every frame of the H264, H265 and AAC tracks, NAL units prefixed with their size, into a\n"+
+			"column of -frame-timeline-out and into -checksum-out, to compare two capture points of a stream")
+	flag.StringVar(&cfg.checksumAlgorithm, "checksum-algorithm", "sha256",
+		"with -verify-checksums, hash algorithm: "+strings.Join(frameHashNames(), ", "))
+	flag.StringVar(&cfg.checksumOut, "checksum-out", "",
+		"with -verify-checksums, write the hash of every frame into this CSV manifest, with its track,\n"+
+			"RTP timestamp and size")
+	flag.StringVar(&cfg.seiOut, "sei-out", "",
+		"write the SEI messages of the H264 and H265 tracks into this file, one JSON object per frame\n"+
+			"carrying some, with their payload type and the UUID and text of unregistered user data")
+	flag.StringVar(&cfg.onDecodeError, "on-decode-error", decodeErrorLog,
+		"what to do when the H264, H265 and AAC access units reassembled for an output (e.g. -mp4-out) fail\n"+
+			"to depacketize: log (log the error), ignore (only count it in the report of the track, and dump it\n"+
+			"with -decode-error-dump) or fail (stop the capture with an error); errors are counted in any case")
+	flag.StringVar(&cfg.decodeErrorDump, "decode-error-dump", "",
+		"write the packets of the H264, H265 and AAC tracks on which depacketization fails into this file,\n"+
+			"with their payload in hex and base64, one JSON object per line; at most one per track per second")
+	flag.IntVar(&cfg.decodeErrorDumpMax, "decode-error-dump-max", 10,
+		"with -decode-error-dump, maximum number of packets written")
+	flag.StringVar(&cfg.metadataOut, "metadata-out", "",
+		"write the XML documents of ONVIF metadata tracks into this file, one per line\n"+
+			"(default: in the metadata field of the packet completing them)")
+	flag.StringVar(&cfg.webvttOut, "webvtt-out", "",
+		"write the events and the objects detected by analytics of ONVIF metadata tracks into this\n"+
+			"WebVTT file, as captions timed on the media timeline from RTCP sender reports")
+	flag.StringVar(&cfg.pcapngOut, "pcapng-out", "",
+		"write the RTP packets of every track into this pcapng file for Wireshark, wrapped in IPv4/UDP\n"+
+			"headers on port 20000+2*track, one interface per track, each packet commented with its track,\n"+
+			"SSRC and H264/H265 NAL unit types; - writes it to the standard output (wireshark -k -i -)")
+	flag.StringVar(&cfg.rawPayloadOut, "raw-payload-out", "",
+		"write the raw RTP payloads of the track selected by -raw-payload-track into this file,\n"+
+			"concatenated in order of reception, without depacketization nor framing;\n"+
+			"for formats that can't be extracted otherwise; - writes them to the standard output")
+	flag.IntVar(&cfg.rawPayloadTrack, "raw-payload-track", 0,
+		"index of the track written by -raw-payload-out")
+	flag.BoolVar(&cfg.rawPayloadFramed, "raw-payload-framed", false,
+		"with -raw-payload-out, precede every payload with its length, as a 4-byte big-endian integer")
+	flag.BoolVar(&cfg.rawPayloadIndex, "raw-payload-index", false,
+		"with -raw-payload-out, also write the index of the frames into <file>.idx, one JSON object per\n"+
+			"frame with its offset and size in the file, RTP timestamp and keyframe flag, to seek into it")
+	flag.BoolVar(&cfg.noRTCP, "no-rtcp", false,
+		"ignore received RTCP packets; this also disables NTP timestamp mapping")
+	flag.Var(&cfg.rtcpTracks, "rtcp-track",
+		"comma-separated indexes of the tracks whose RTCP packets are processed (default: all tracks);\n"+
+			"other tracks get no NTP timestamp mapping")
+	flag.Float64Var(&cfg.maxClockDrift, "max-clock-drift", 100,
+		"warn when the RTP clocks of two tracks drift apart by more than this number of ppm, measured\n"+
+			"from RTCP sender reports over at least 30s (0: never warn)")
+	flag.BoolVar(&cfg.rtcpOptional, "rtcp-optional", false,
+		"with UDP, when the RTCP port of a track can't be bound but its RTP port can, go on without\n"+
+			"RTCP for the track instead of failing, which disables its NTP timestamp mapping")
+	flag.DurationVar(&cfg.statsInterval, "stats-interval", 0,
+		"log the stats of the tracks at this interval (default: disabled)")
+	flag.BoolVar(&cfg.statsOnChange, "stats-on-change", false,
+		"only log the stats when a counter advanced or a track stalled or recovered since the previous line")
+	flag.DurationVar(&cfg.statsHeartbeat, "stats-heartbeat", 5*time.Minute,
+		"with -stats-on-change, log the stats at least this often, even when nothing changed")
+	flag.StringVar(&cfg.statsdAddr, "statsd-addr", "",
+		"push the counters and gauges of the tracks to the StatsD server at this host:port,\n"+
+			"with DogStatsD tags (default: disabled)")
+	flag.DurationVar(&cfg.statsdInterval, "statsd-interval", 10*time.Second,
+		"with -statsd-addr, interval between pushes")
+	flag.StringVar(&cfg.statsdPrefix, "statsd-prefix", "rtsp",
+		"with -statsd-addr, prefix of the metric names")
+	flag.StringVar(&cfg.graphiteAddr, "graphite-addr", "",
+		"push the counters and gauges of the tracks to the Graphite server at this host:port,\n"+
+			"in the plaintext protocol over TCP (default: disabled)")
+	flag.DurationVar(&cfg.graphiteInterval, "graphite-interval", 10*time.Second,
+		"with -graphite-addr, interval between pushes")
+	flag.StringVar(&cfg.graphitePrefix, "graphite-prefix", "rtsp",
+		"with -graphite-addr, first node of the metric paths, followed by the stream and the track")
+	flag.StringVar(&cfg.grpcAddr, "grpc-addr", "",
+		"serve the access units of the tracks on this host:port, as the server-streaming\n"+
+			"rtspmeta.AccessUnits/Stream gRPC method of accessunit.proto, without TLS; up to -sink-buffer\n"+
+			"units are queued per client, then dropped for the client or waited for per -write-overflow\n"+
+			"(default: disabled)")
+	srtpKey := flag.String("srtp-key", "",
+		"base64 SRTP master key followed by the master salt, as in the inline parameter of SDES,\n"+
+			"decrypting every track (default: the keys of the a=crypto attributes of the SDP, if any)")
+	srtpSuite := flag.String("srtp-suite", "AES_CM_128_HMAC_SHA1_80",
+		"with -srtp-key, crypto suite of the key: AES_CM_128_HMAC_SHA1_80, AES_CM_128_HMAC_SHA1_32,\n"+
+			"AES_256_CM_HMAC_SHA1_80 or AES_256_CM_HMAC_SHA1_32")
+	bindAddr := flag.String("bind-addr", "",
+		"local IP address the RTSP connection and the UDP sockets originate from (default: chosen by the OS)")
+	flag.BoolVar(&cfg.resolveOnce, "resolve-once", false,
+		"resolve the host of the URL once at startup, and reuse that address for every reconnection\n"+
+			"(-loop captures, switch to TCP), to stay on one backend of a round-robin DNS")
+	flag.IntVar(&cfg.multicastTTL, "multicast-ttl", 0,
+		"TTL (hop limit) of the multicast packets sent by the client, 1-255, which are only the RTCP\n"+
+			"receiver reports of multicast tracks; 0 keeps the system default; no effect on Linux, where the\n"+
+			"RTSP library creates the multicast sockets itself, with a TTL of 16 (default 0)")
+	flag.IntVar(&cfg.dscp, "dscp", 0,
+		"DSCP value marking the UDP packets sent by the client, 0-63")
+	jsonPretty := flag.Bool("json-pretty", false,
+		"indent all the JSON output, or print it on single lines when false\n"+
+			"(default: packets on single lines, SDP, summaries, stats and reports indented)")
+	flag.BoolVar(&cfg.followSDPUpdate, "follow-sdp-update", false,
+		"when packets of a payload type that the SDP doesn't declare are received, DESCRIBE the stream\n"+
+			"again, and start a new session with the new SDP if it changed (at most one DESCRIBE every 10s);\n"+
+			"the output files of every session are numbered, as with -loop")
+	flag.BoolVar(&cfg.reconnect, "reconnect", false,
+		"when the server announces with Connection: close that it closes the connection while streaming,\n"+
+			"start a new session at once (at most one every second) instead of failing on the next request;\n"+
+			"the output files of every session are numbered, as with -loop")
+	flag.BoolVar(&cfg.interactive, "interactive", false,
+		"read commands from the standard input while running: mute stops printing packets, while\n"+
+			"reception and stats go on without pausing the RTSP session, unmute prints them again")
+	flag.BoolVar(&cfg.jsonFlatten, "json-flatten", false,
+		"print and write the packets as single-level JSON objects with dotted keys (e.g. extensions.0.id),\n"+
+			"for log ingestion systems which handle flat documents better than nested ones")
+	jsonFields := flag.String("json-fields", "",
+		"comma-separated packet fields printed and written, as named in the JSON output or shortened\n"+
+			"(e.g. seq,timestamp,marker); the NDJSON file keeps the track index (default: all fields)")
+	jsonExcludeFields := flag.String("json-exclude-fields", "",
+		"comma-separated packet fields left out of the log and the NDJSON file (e.g. csrc,extensions)")
+	outputTemplate := flag.String("output-template", "",
+		"print and write every packet as a line rendered by this Go text/template, evaluated against the\n"+
+			"fields of the packet record and Track, TrackName, Codec and Bytes (e.g. \"{{.Track}} {{.Seq}} {{.Bytes}}\")")
+	schedule := flag.String("schedule", "",
+		"capture only during these comma-separated windows of the day, in the [days ]HH:MM-HH:MM form\n"+
+			"(e.g. \"mon-fri 08:00-18:00,sat 10:00-14:00\"): the session is torn down when a window closes,\n"+
+			"and set up again when the next one opens; the output files of every session are numbered,\n"+
+			"as with -loop")
+	timezone := flag.String("timezone", "",
+		"time zone of the -schedule windows, as an IANA name (e.g. Europe/Paris) (default: local time)")
+	listCodecs := flag.Bool("list-codecs", false,
+		"print the codecs whose media can be extracted, with the outputs extracting them, and exit")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <rtsp-url>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *listCodecs {
+		printCodecs(os.Stdout)
+		os.Exit(0)
+	}
+
+	// Ensure RTSP URL is provided :
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg.url = flag.Arg(0)
+
+	cfg.packetJSONPretty = false
+	cfg.reportJSONPretty = true
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "json-pretty" {
+			cfg.packetJSONPretty = *jsonPretty
+			cfg.reportJSONPretty = *jsonPretty
+		}
+	})
+
+	if *bindAddr != "" {
+		cfg.bindAddr = net.ParseIP(*bindAddr)
+		if cfg.bindAddr == nil {
+			fmt.Fprintf(os.Stderr, "invalid -bind-addr %q: not an IP address\n", *bindAddr)
+			os.Exit(2)
+		}
+	}
+
+	if *jsonFields != "" || *jsonExcludeFields != "" {
+		if *jsonFields != "" && *jsonExcludeFields != "" {
+			fmt.Fprintln(os.Stderr, "-json-fields can't be used with -json-exclude-fields")
+			os.Exit(2)
+		}
+		projection, err := newFieldProjection(splitList(*jsonFields), splitList(*jsonExcludeFields))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid packet fields: %v\n", err)
+			os.Exit(2)
+		}
+		cfg.jsonFields = projection
+	}
+
+	if *outputTemplate != "" {
+		tmpl, err := parseOutputTemplate(*outputTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -output-template: %v\n", err)
+			os.Exit(2)
+		}
+		cfg.outputTemplate = tmpl
+	}
+
+	if *timezone != "" && *schedule == "" {
+		fmt.Fprintln(os.Stderr, "-timezone requires -schedule")
+		os.Exit(2)
+	}
+	if *schedule != "" {
+		location := time.Local
+		if *timezone != "" {
+			var err error
+			location, err = time.LoadLocation(*timezone)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid -timezone: %v\n", err)
+				os.Exit(2)
+			}
+		}
+		sched, err := parseSchedule(*schedule, location)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -schedule: %v\n", err)
+			os.Exit(2)
+		}
+		cfg.schedule = sched
+	}
+
+	if *srtpKey != "" {
+		key, err := parseSRTPKey(*srtpSuite, *srtpKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -srtp-key: %v\n", err)
+			os.Exit(2)
+		}
+		key.source = "-srtp-key"
+		cfg.srtpKey = key
+	}
+
+	if *controlBase != "" {
+		u, err := base.ParseURL(*controlBase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -control-base %q: %v\n", *controlBase, err)
+			os.Exit(2)
+		}
+		cfg.controlBase = u
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	return cfg
+}
+
+// validate checks the consistency of the options.
+func (c *config) validate() error {
+	if c.stallTimeout <= 0 {
+		return fmt.Errorf("-stall-timeout must be positive")
+	}
+	if c.readTimeout <= 0 {
+		return fmt.Errorf("-read-timeout must be positive")
+	}
+	if c.describeTimeout < 0 || c.setupTimeout < 0 {
+		return fmt.Errorf("-describe-timeout and -setup-timeout must not be negative")
+	}
+	if c.maxConcurrentSetups < 0 {
+		return fmt.Errorf("-max-concurrent-setups must not be negative")
+	}
+	if c.maxSDPSize <= 0 || c.maxSDPSize > sdpMaxSize {
+		return fmt.Errorf("-max-sdp-size must be between 1 and %d", sdpMaxSize)
+	}
+	if c.maxMedias <= 0 {
+		return fmt.Errorf("-max-medias must be positive")
+	}
+	switch c.controlMode {
+	case controlModeAppend, controlModeRelative, controlModeAbsolute:
+	default:
+		return fmt.Errorf("-control-mode must be append, relative or absolute")
+	}
+	if c.dedupWindow <= 0 {
+		return fmt.Errorf("-dedup-window must be positive")
+	}
+	if c.simulateLoss < 0 || c.simulateLoss > 100 {
+		return fmt.Errorf("-simulate-loss must be between 0 and 100")
+	}
+	if c.bitrateWindow <= 0 {
+		return fmt.Errorf("-bitrate-window must be positive")
+	}
+	if c.warmup < 0 {
+		return fmt.Errorf("-warmup must not be negative")
+	}
+	if c.sampleEvery <= 0 {
+		return fmt.Errorf("-sample-every must be positive")
+	}
+	if c.duration < 0 {
+		return fmt.Errorf("-duration must not be negative")
+	}
+	if c.loop && c.duration == 0 {
+		return fmt.Errorf("-loop requires -duration")
+	}
+	if c.schedule != nil && (c.loop || c.connectOnly || c.compareURL != "" || c.probeAll) {
+		return fmt.Errorf("-schedule can't be used with -loop, -connect-only, -compare nor -probe-all")
+	}
+	if c.sessions() && c.mp4Out == stdoutPath {
+		return fmt.Errorf("-loop, -follow-sdp-update, -reconnect and -schedule can't be used with -mp4-out -: " +
+			"the MP4 files of the sessions can't follow each other on the standard output")
+	}
+	if c.decodeErrorDumpMax <= 0 {
+		return fmt.Errorf("-decode-error-dump-max must be positive")
+	}
+	if c.mp4Rotation.interval < 0 || c.mp4Rotation.size < 0 {
+		return fmt.Errorf("-mp4-rotate-interval and -mp4-rotate-size must not be negative")
+	}
+	if (c.mp4VideoOnly || c.mp4AudioOnly) && c.mp4Out == "" {
+		return fmt.Errorf("-mp4-video-only and -mp4-audio-only require -mp4-out")
+	}
+	if c.mp4VideoOnly && c.mp4AudioOnly {
+		return fmt.Errorf("-mp4-video-only can't be used with -mp4-audio-only")
+	}
+	if c.mp4Rotation.enabled() && c.mp4Out == "" {
+		return fmt.Errorf("-mp4-rotate-interval and -mp4-rotate-size require -mp4-out")
+	}
+	if c.mp4Rotation.onKeyframe && !c.mp4Rotation.enabled() {
+		return fmt.Errorf("-rotate-on-keyframe requires -mp4-rotate-interval or -mp4-rotate-size")
+	}
+	stdoutOutputs := 0
+	for _, path := range []string{c.mp4Out, c.rawPayloadOut, c.pcapngOut} {
+		if path == stdoutPath {
+			stdoutOutputs++
+		}
+	}
+	if stdoutOutputs > 1 {
+		return fmt.Errorf("only one of -mp4-out, -raw-payload-out and -pcapng-out can write to the standard output")
+	}
+	if c.writesStdout() && c.summaryOnly {
+		return fmt.Errorf("-summary-only prints the final report on the standard output, " +
+			"which -mp4-out -, -raw-payload-out - or -pcapng-out - use")
+	}
+	if c.mp4Out == stdoutPath && c.mp4Rotation.enabled() {
+		return fmt.Errorf("-mp4-rotate-interval and -mp4-rotate-size can't be used with -mp4-out -")
+	}
+	if c.rawPayloadOut == stdoutPath && c.rawPayloadIndex {
+		return fmt.Errorf("-raw-payload-index can't be used with -raw-payload-out -")
+	}
+	if c.traceRotateSize < 0 {
+		return fmt.Errorf("-trace-rotate-size must not be negative")
+	}
+	if c.traceNoRedact && c.traceFile == "" {
+		return fmt.Errorf("-trace-no-redact requires -trace-file")
+	}
+	if c.traceRotateSize != 0 && c.traceFile == "" && c.traceFrames == "" {
+		return fmt.Errorf("-trace-rotate-size requires -trace-file or -trace-frames")
+	}
+	if c.rawPayloadTrack < 0 {
+		return fmt.Errorf("-raw-payload-track must not be negative")
+	}
+	if c.keepOriginalTimestamps && !c.normalizeTimestamps {
+		return fmt.Errorf("-keep-original-timestamps requires -normalize-timestamps")
+	}
+	if c.describeRetryLimit <= 0 || c.describeRetryDelay < 0 {
+		return fmt.Errorf("-describe-retry-limit must be positive and -describe-retry-delay must not be negative")
+	}
+	if c.serverErrorRetry.limit <= 0 || c.serverErrorRetry.delay < 0 {
+		return fmt.Errorf("-retry-5xx-limit must be positive and -retry-5xx-delay must not be negative")
+	}
+	if c.sinkBuffer <= 0 {
+		return fmt.Errorf("-sink-buffer must be positive")
+	}
+	if c.outputBufferSize <= 0 {
+		return fmt.Errorf("-output-buffer-size must be positive")
+	}
+	if c.flushInterval < 0 {
+		return fmt.Errorf("-flush-interval must not be negative")
+	}
+	if _, ok := frameHashes[c.checksumAlgorithm]; !ok {
+		return fmt.Errorf("invalid -checksum-algorithm %q: expected %s", c.checksumAlgorithm,
+			strings.Join(frameHashNames(), ", "))
+	}
+	if c.checksumOut != "" && !c.verifyChecksums {
+		return fmt.Errorf("-checksum-out requires -verify-checksums")
+	}
+	if c.verifyChecksums && c.frameTimelineOut == "" && c.checksumOut == "" {
+		return fmt.Errorf("-verify-checksums requires -frame-timeline-out or -checksum-out, which receive the hashes")
+	}
+	if c.outputTemplate != nil && (c.jsonFlatten || c.jsonFields != nil) {
+		return fmt.Errorf("-json-flatten, -json-fields and -json-exclude-fields can't be used with -output-template")
+	}
+	switch c.outFormat {
+	case outFormatJSON:
+	case outFormatProtobuf:
+		if c.ndjsonOut == "" {
+			return fmt.Errorf("-out-format protobuf requires -ndjson-out")
+		}
+		if c.jsonFlatten || c.jsonFields != nil {
+			return fmt.Errorf("-json-flatten, -json-fields and -json-exclude-fields can't be used with -out-format protobuf")
+		}
+		if c.outputTemplate != nil {
+			return fmt.Errorf("-output-template can't be used with -out-format protobuf")
+		}
+	default:
+		return fmt.Errorf("invalid -out-format %q: expected %s or %s", c.outFormat, outFormatJSON, outFormatProtobuf)
+	}
+	if c.cmafOut == stdoutPath {
+		return fmt.Errorf("-cmaf-out writes a directory, and can't write to the standard output")
+	}
+	if c.warmupDescribe && c.mp4Out == "" && c.cmafOut == "" {
+		return fmt.Errorf("-warmup-describe requires -mp4-out or -cmaf-out")
+	}
+	if c.cmafSegmentDuration <= 0 {
+		return fmt.Errorf("-cmaf-segment-duration must be positive")
+	}
+	switch c.onFormatChange {
+	case formatChangeRotate, formatChangeContinue, formatChangeFail:
+	default:
+		return fmt.Errorf("-on-format-change must be rotate, continue or fail")
+	}
+	switch c.onDecodeError {
+	case decodeErrorLog, decodeErrorIgnore, decodeErrorFail:
+	default:
+		return fmt.Errorf("-on-decode-error must be log, ignore or fail")
+	}
+	if c.writeOverflow != writeOverflowDrop && c.writeOverflow != writeOverflowBlock {
+		return fmt.Errorf("-write-overflow must be drop or block")
+	}
+	if c.loopInterval < 0 {
+		return fmt.Errorf("-loop-interval must not be negative")
+	}
+	if c.connectTimeout <= 0 {
+		return fmt.Errorf("-connect-timeout must be positive")
+	}
+	if c.connectOnly && (c.noPlay || c.loop || c.summaryOnly) {
+		return fmt.Errorf("-connect-only can't be used with -no-play, -loop nor -summary-only")
+	}
+	if c.failFast && c.followSDPUpdate {
+		return fmt.Errorf("-fail-fast can't be used with -follow-sdp-update, " +
+			"as undeclared payload types abort the capture instead of following the SDP")
+	}
+	if c.blocksize != 0 && (c.blocksize < minBlocksize || c.blocksize > maxBlocksize) {
+		return fmt.Errorf("-blocksize must be between %d and %d", minBlocksize, maxBlocksize)
+	}
+	if c.speed < 0 {
+		return fmt.Errorf("-speed must be positive")
+	}
+	if c.contentCheck && (c.contentWindow <= 0 || c.blackFrameSize <= 0) {
+		return fmt.Errorf("-content-window and -black-frame-size must be positive")
+	}
+	if c.contentCheck && c.silenceLevel >= 0 {
+		return fmt.Errorf("-silence-level must be negative")
+	}
+	if c.maxClockDrift < 0 {
+		return fmt.Errorf("-max-clock-drift must not be negative")
+	}
+	if c.rtxReorderWait <= 0 {
+		return fmt.Errorf("-rtx-reorder-wait must be positive")
+	}
+	if c.sendNACK && c.noRTCP {
+		return fmt.Errorf("-send-nack can't be used with -no-rtcp")
+	}
+	if c.disableKeepalive && strings.HasPrefix(strings.ToLower(c.url), "rtsps://") {
+		return fmt.Errorf("-disable-keepalive can't be used with rtsps:// URLs, whose requests are encrypted")
+	}
+	if c.measureRTT && c.noRTCP {
+		return fmt.Errorf("-measure-rtt can't be used with -no-rtcp")
+	}
+	if c.minPackets != 0 && (c.connectOnly || c.noPlay) {
+		return fmt.Errorf("-min-packets can't be used with -connect-only nor -no-play, which receive no packets")
+	}
+	if c.compareStrict && c.compareURL == "" {
+		return fmt.Errorf("-compare-strict requires -compare")
+	}
+	if c.compareURL != "" && (c.connectOnly || c.noPlay || c.loop || c.summaryOnly) {
+		return fmt.Errorf("-compare can't be used with -connect-only, -no-play, -loop nor -summary-only")
+	}
+	if c.pathsFile != "" && !c.probeAll {
+		return fmt.Errorf("-paths-file requires -probe-all")
+	}
+	if c.probeAll && (c.connectOnly || c.compareURL != "" || c.noPlay || c.loop || c.summaryOnly) {
+		return fmt.Errorf("-probe-all can't be used with -connect-only, -compare, -no-play, -loop nor -summary-only")
+	}
+	if c.probeConcurrency <= 0 {
+		return fmt.Errorf("-probe-concurrency must be positive")
+	}
+	if c.probeInterval < 0 {
+		return fmt.Errorf("-probe-interval must not be negative")
+	}
+	if c.summaryOnly && c.noPlay {
+		return fmt.Errorf("-summary-only can't be used with -no-play")
+	}
+	if c.summaryOnly && c.statsInterval != 0 {
+		return fmt.Errorf("-summary-only can't be used with -stats-interval")
+	}
+	if c.statsInterval < 0 {
+		return fmt.Errorf("-stats-interval must not be negative")
+	}
+	if c.statsOnChange && c.statsInterval == 0 {
+		return fmt.Errorf("-stats-on-change requires -stats-interval")
+	}
+	if c.statsHeartbeat <= 0 {
+		return fmt.Errorf("-stats-heartbeat must be positive")
+	}
+	if c.statsdInterval <= 0 {
+		return fmt.Errorf("-statsd-interval must be positive")
+	}
+	if c.graphiteInterval <= 0 {
+		return fmt.Errorf("-graphite-interval must be positive")
+	}
+	if c.grpcAddr != "" {
+		_, _, err := net.SplitHostPort(c.grpcAddr)
+		if err != nil {
+			return fmt.Errorf("invalid -grpc-addr: %w", err)
+		}
+		if c.connectOnly || c.noPlay || c.probeAll {
+			return fmt.Errorf("-grpc-addr can't be used with -connect-only, -no-play nor -probe-all")
+		}
+	}
+	if c.multicastTTL < 0 || c.multicastTTL > 255 {
+		return fmt.Errorf("-multicast-ttl must be between 0 (system default) and 255")
+	}
+	if c.dscp < 0 || c.dscp > 63 {
+		return fmt.Errorf("-dscp must be between 0 and 63")
+	}
+	if c.bindAddr != nil {
+		err := checkLocalAddr(c.bindAddr)
+		if err != nil {
+			return fmt.Errorf("invalid -bind-addr: %w", err)
+		}
+	}
+	return nil
+}
+
+// trackSelected returns whether the track with the given index must be SETUP.
+func (c *config) trackSelected(index int) bool {
+	return len(c.tracks) == 0 || containsIndex(c.tracks, index)
+}
+
+// rtcpEnabled returns whether the RTCP packets of the track with the given
+// index must be processed.
+func (c *config) rtcpEnabled(index int) bool {
+	return !c.noRTCP && (len(c.rtcpTracks) == 0 || containsIndex(c.rtcpTracks, index))
+}
+
+// containsIndex returns whether the list contains the given index.
+func containsIndex(list []int, index int) bool {
+	for _, i := range list {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// sessions returns whether the program runs several sessions in a row,
+// with -loop, -follow-sdp-update, -reconnect or -schedule.
+func (c *config) sessions() bool {
+	return c.loop || c.followSDPUpdate || c.reconnect || c.schedule != nil
+}
+
+// outputPath returns the path of an output file for the current session.
+// When the program runs several sessions, each one writes its own files,
+// numbered as the rotated ones: rec.mp4 gives rec-0001.mp4 for the first
+// session, rec-0002.mp4 for the next one... The standard output is shared
+// by the sessions.
+func (c *config) outputPath(path string) string {
+	if path == stdoutPath || !c.sessions() {
+		return path
+	}
+	return c.files.path(path)
+}
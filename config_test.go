@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestOutputPathSessions(t *testing.T) {
+	cfg := &config{loop: true}
+	for _, want := range []string{"rec-0001.ndjson", "rec-0002.ndjson", "rec-0003.ndjson"} {
+		got := cfg.outputPath("rec.ndjson")
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	if got := cfg.outputPath("out/"); got != "out-0001/" {
+		t.Errorf("got %s, want out-0001/", got)
+	}
+	if got := cfg.outputPath(stdoutPath); got != stdoutPath {
+		t.Errorf("got %s for the standard output", got)
+	}
+
+	single := &config{}
+	if got := single.outputPath("rec.ndjson"); got != "rec.ndjson" {
+		t.Errorf("got %s without sessions, want rec.ndjson", got)
+	}
+}
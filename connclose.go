@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/liberrors"
+)
+
+// minimum interval between the starts of two sessions, when -reconnect
+// starts a new one.
+const reconnectInterval = 1 * time.Second
+
+// connectionClose watches the responses of the server for a Connection: close
+// header, with which a server announces that it closes the connection after
+// the response. The requests that follow then fail with an unexpected EOF,
+// which is explained with the announcement, or avoided with -reconnect by
+// ending the session once closing is closed.
+type connectionClose struct {
+	// closed at the announcement :
+	closing chan struct{}
+
+	mutex sync.Mutex
+	// method of the request in progress, and of the request whose response
+	// announced the close :
+	method    base.Method
+	announced base.Method
+	explained bool
+}
+
+// watchConnectionClose chains the response callbacks of the client with the
+// detection of Connection: close.
+func watchConnectionClose(client *gortsplib.Client) *connectionClose {
+	c := &connectionClose{closing: make(chan struct{})}
+
+	onRequest := client.OnRequest
+	client.OnRequest = func(req *base.Request) {
+		if onRequest != nil {
+			onRequest(req)
+		}
+		c.mutex.Lock()
+		c.method = req.Method
+		c.mutex.Unlock()
+	}
+
+	onResponse := client.OnResponse
+	client.OnResponse = func(res *base.Response) {
+		if onResponse != nil {
+			onResponse(res)
+		}
+		if !headerHasToken(res.Header, "Connection", "close") {
+			return
+		}
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if c.announced == "" {
+			c.announced = c.method
+			close(c.closing)
+			log.Printf("WARNING: the server closes the connection after its %s response (Connection: close); "+
+				"the following requests will fail", c.method)
+		}
+	}
+
+	return c
+}
+
+// logHint explains the failure of a request or of the session with the
+// announced close, if any, once. Error statuses are explained by
+// logStatusHint.
+func (c *connectionClose) logHint(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var statusErr liberrors.ErrClientBadStatusCode
+	if err == nil || c.announced == "" || c.explained || errors.As(err, &statusErr) {
+		return
+	}
+	c.explained = true
+	log.Printf("Server closed the connection, as announced with Connection: close after its %s response: "+
+		"it doesn't keep RTSP connections alive", c.announced)
+}
+
+// headerHasToken returns whether a comma-separated header contains a token,
+// case-insensitively.
+func headerHasToken(h base.Header, key string, token string) bool {
+	for _, value := range h[key] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
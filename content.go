@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+)
+
+// contentChecker looks for streams which flow but show or play nothing, with
+// -content-check. The checks are heuristics on the RTP payloads, without
+// decoding the media, so they can both miss and raise false alarms:
+//   - on video tracks of any codec, frames are the payloads sharing an RTP
+//     timestamp; a window of frames which are all tiny, keyframes included,
+//     suggests a black picture, and a window of frames of the same size
+//     suggests a frozen picture repeated by the server. Static scenes also
+//     compress to small frames.
+//   - on G.711 audio tracks, the samples are decoded, and a window whose peak
+//     level stays below a threshold is reported as silent.
+//
+// A warning is logged when a window becomes suspicious, and a message when
+// the content looks normal again.
+type contentChecker struct {
+	track  *track
+	window time.Duration
+	// largest size in bytes of the frames of a black picture, and level in
+	// dBFS below which audio is silent :
+	blackFrameSize int
+	silenceLevel   float64
+
+	video bool
+	// law of the samples of G.711 tracks :
+	mulaw bool
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	// frame being received, and sizes of the frames of the window :
+	frameTimestamp uint32
+	frameSize      int
+	inFrame        bool
+	frames         int
+	minFrame       int
+	maxFrame       int
+	// peak absolute sample of the window, out of 32768 :
+	samples int
+	peak    int
+	// kind of the current suspicion, if any :
+	suspect string
+}
+
+// contentSupported returns whether the content of a track can be checked.
+func contentSupported(medi *description.Media) bool {
+	if medi.Type == description.MediaTypeVideo {
+		return true
+	}
+	_, ok := medi.Formats[0].(*format.G711)
+	return ok
+}
+
+// newContentChecker creates the checker of a track, which must be
+// supported.
+func newContentChecker(t *track, window time.Duration, blackFrameSize int, silenceLevel float64) *contentChecker {
+	c := &contentChecker{
+		track:          t,
+		window:         window,
+		blackFrameSize: blackFrameSize,
+		silenceLevel:   silenceLevel,
+		video:          t.media.Type == description.MediaTypeVideo,
+	}
+	if g711, ok := t.media.Formats[0].(*format.G711); ok {
+		c.mulaw = g711.MULaw
+	}
+	return c
+}
+
+// push accounts a packet received at now, and checks the window when it
+// is complete.
+func (c *contentChecker) push(timestamp uint32, payload []byte, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.windowStart.IsZero() {
+		c.windowStart = now
+	}
+
+	if c.video {
+		if c.inFrame && timestamp != c.frameTimestamp {
+			c.endFrame()
+		}
+		c.inFrame = true
+		c.frameTimestamp = timestamp
+		c.frameSize += len(payload)
+	} else {
+		for _, b := range payload {
+			s := g711ToLinear(b, c.mulaw)
+			if s < 0 {
+				s = -s
+			}
+			if s > c.peak {
+				c.peak = s
+			}
+		}
+		c.samples += len(payload)
+	}
+
+	if now.Sub(c.windowStart) >= c.window {
+		c.check()
+		c.windowStart = now
+		c.frames, c.minFrame, c.maxFrame = 0, 0, 0
+		c.samples, c.peak = 0, 0
+	}
+}
+
+// endFrame adds the frame being received to the window.
+func (c *contentChecker) endFrame() {
+	if c.frames == 0 || c.frameSize < c.minFrame {
+		c.minFrame = c.frameSize
+	}
+	if c.frameSize > c.maxFrame {
+		c.maxFrame = c.frameSize
+	}
+	c.frames++
+	c.frameSize = 0
+}
+
+// check applies the heuristics to the window, and logs the changes of
+// suspicion.
+func (c *contentChecker) check() {
+	var suspect, reason string
+	switch {
+	case c.video && c.frames > 1 && c.maxFrame < c.blackFrameSize:
+		suspect = "black"
+		reason = fmt.Sprintf("its %d frames are all smaller than %d bytes, possibly a black picture",
+			c.frames, c.blackFrameSize)
+
+	case c.video && c.frames > 1 && c.minFrame == c.maxFrame:
+		suspect = "frozen"
+		reason = fmt.Sprintf("its %d frames are all %d bytes, possibly a frozen picture", c.frames, c.maxFrame)
+
+	case !c.video && c.samples > 0:
+		if level := peakLevel(c.peak); level < c.silenceLevel {
+			suspect = "silent"
+			reason = fmt.Sprintf("its peak level is %.1f dBFS, possibly silence", level)
+		}
+	}
+
+	switch {
+	case suspect != "" && suspect != c.suspect:
+		log.Printf("WARNING: track %s: over the last %v, %s (content heuristic)", c.track, c.window, reason)
+	case suspect == "" && c.suspect != "":
+		log.Printf("Track %s: content no longer looks %s", c.track, c.suspect)
+	}
+	c.suspect = suspect
+}
+
+// peakLevel converts a peak absolute sample into dBFS.
+func peakLevel(peak int) float64 {
+	if peak == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(float64(peak)/32768)
+}
+
+// g711ToLinear decodes a G.711 sample (ITU-T G.711) into a 16-bit linear
+// sample.
+func g711ToLinear(b byte, mulaw bool) int {
+	if mulaw {
+		b = ^b
+		t := (int(b&0x0f)<<3 + 0x84) << ((b & 0x70) >> 4)
+		if b&0x80 != 0 {
+			return 0x84 - t
+		}
+		return t - 0x84
+	}
+
+	b ^= 0x55
+	t := int(b&0x0f) << 4
+	switch seg := (b & 0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (seg - 1)
+	}
+	if b&0x80 != 0 {
+		return t
+	}
+	return -t
+}
+
+// setupContentCheckers checks the content of the video and G.711 tracks,
+// with -content-check.
+func setupContentCheckers(cfg *config, tracks []*track) {
+	for _, t := range tracks {
+		if !t.setup {
+			continue
+		}
+		if !contentSupported(t.media) {
+			if t.media.Type == description.MediaTypeAudio {
+				log.Printf("WARNING: -content-check can't check track %s: only G.711 audio is supported", t)
+			}
+			continue
+		}
+		t.content = newContentChecker(t, cfg.contentWindow, cfg.blackFrameSize, cfg.silenceLevel)
+	}
+}
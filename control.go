@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+)
+
+// Strategies to resolve the control attribute of a media into its SETUP URL.
+// Control attributes holding an absolute URL are used as is by all of them.
+const (
+	// append the control to the base URL, as gortsplib does.
+	controlModeAppend = "append"
+	// resolve the control as a relative reference (RFC 3986), so that
+	// it replaces the last segment of the base URL.
+	controlModeRelative = "relative"
+	// resolve the control from the root of the server, ignoring the
+	// path of the base URL.
+	controlModeAbsolute = "absolute"
+)
+
+// resolveControl returns the URL of a media whose control attribute is
+// control, resolved against contentBase with the given strategy.
+func resolveControl(contentBase *base.URL, control string, mode string) (*base.URL, error) {
+	if control == "" {
+		return contentBase, nil
+	}
+	if strings.HasPrefix(control, "rtsp://") || strings.HasPrefix(control, "rtsps://") {
+		return description.Media{Control: control}.URL(contentBase)
+	}
+
+	switch mode {
+	case controlModeAppend:
+		return description.Media{Control: control}.URL(contentBase)
+
+	case controlModeRelative:
+		ref, err := url.Parse(control)
+		if err != nil {
+			return nil, err
+		}
+		return (*base.URL)((*url.URL)(contentBase).ResolveReference(ref)), nil
+
+	case controlModeAbsolute:
+		root := &url.URL{Scheme: contentBase.Scheme, User: contentBase.User, Host: contentBase.Host, Path: "/"}
+		ref, err := url.Parse(strings.TrimPrefix(control, "/"))
+		if err != nil {
+			return nil, err
+		}
+		return (*base.URL)(root.ResolveReference(ref)), nil
+	}
+	return nil, fmt.Errorf("unknown control mode %q", mode)
+}
+
+// overrideControls rewrites the control attribute of every media with its
+// URL resolved against controlBase, so that gortsplib uses it as is.
+func overrideControls(tracks []*track, controlBase *base.URL, mode string) error {
+	if controlBase == nil {
+		return fmt.Errorf("no base URL")
+	}
+	for _, t := range tracks {
+		u, err := resolveControl(controlBase, t.media.Control, mode)
+		if err != nil {
+			return fmt.Errorf("track %s: %w", t, err)
+		}
+		t.media.Control = u.String()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import "sort"
+
+// number of distinct contributing sources remembered per track, against
+// streams filling the CSRC list with random values.
+const csrcMax = 256
+
+// csrcSet tracks the contributing sources (CSRC) of a mixed track, and how
+// often the mix, the set of sources of a packet, changes.
+// It is not safe for concurrent use: the track mutex must be held.
+type csrcSet struct {
+	packets   map[uint32]uint64
+	truncated bool
+
+	started bool
+	last    []uint32
+	changes uint64
+}
+
+// push records the CSRC list of a packet.
+func (s *csrcSet) push(csrcs []uint32) {
+	if s.started && !sameSources(csrcs, s.last) {
+		s.changes++
+	}
+	s.started = true
+	s.last = append(s.last[:0], csrcs...)
+
+	for _, csrc := range csrcs {
+		if _, ok := s.packets[csrc]; !ok && len(s.packets) >= csrcMax {
+			s.truncated = true
+			continue
+		}
+		if s.packets == nil {
+			s.packets = make(map[uint32]uint64)
+		}
+		s.packets[csrc]++
+	}
+}
+
+// sameSources returns whether two CSRC lists hold the same sources, in any
+// order. Lists are short, at most 15 entries.
+func sameSources(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// csrcReport is a contributing source of a track.
+type csrcReport struct {
+	CSRC    uint32 `json:"csrc"`
+	Packets uint64 `json:"packets"`
+}
+
+// mixReport is the contributing-source section of the final report of a track.
+type mixReport struct {
+	Sources []csrcReport `json:"sources"`
+	// whether sources were ignored, once csrcMax were seen.
+	Truncated bool `json:"truncated,omitempty"`
+	// number of packets whose set of sources differs from the previous packet.
+	Changes uint64 `json:"changes"`
+	// sources of the last packet.
+	Current []uint32 `json:"current"`
+}
+
+// report returns the contributing sources, ordered by CSRC, or nil when the
+// track never carried any.
+func (s *csrcSet) report() *mixReport {
+	if len(s.packets) == 0 {
+		return nil
+	}
+
+	r := &mixReport{
+		Sources:   make([]csrcReport, 0, len(s.packets)),
+		Truncated: s.truncated,
+		Changes:   s.changes,
+		Current:   append([]uint32{}, s.last...),
+	}
+	for csrc, n := range s.packets {
+		r.Sources = append(r.Sources, csrcReport{CSRC: csrc, Packets: n})
+	}
+	sort.Slice(r.Sources, func(i, j int) bool {
+		return r.Sources[i].CSRC < r.Sources[j].CSRC
+	})
+	return r
+}
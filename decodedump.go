@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// Policies applied when the depacketizer of a track fails on a packet :
+const (
+	// The error is logged :
+	decodeErrorLog = "log"
+
+	// The error is only counted in the report of the track, and dumped
+	// with -decode-error-dump :
+	decodeErrorIgnore = "ignore"
+
+	// The capture ends with an error :
+	decodeErrorFail = "fail"
+)
+
+// decodeErrorHandler applies the -on-decode-error policy to the errors of
+// the depacketizers, and counts them per track.
+type decodeErrorHandler struct {
+	policy string
+	once   sync.Once
+	failed chan struct{}
+}
+
+// newDecodeErrorHandler creates the handler of a policy.
+func newDecodeErrorHandler(policy string) *decodeErrorHandler {
+	return &decodeErrorHandler{
+		policy: policy,
+		failed: make(chan struct{}),
+	}
+}
+
+// handle applies the policy to an error of the depacketizer of t. With the
+// fail policy, it closes failed at the first error, which ends the session.
+func (h *decodeErrorHandler) handle(t *track, err error) {
+	t.decodeErrors.Add(1)
+	switch h.policy {
+	case decodeErrorLog:
+		log.Printf("Error decoding track %s: %v", t, err)
+	case decodeErrorFail:
+		h.once.Do(func() {
+			log.Printf("Error decoding track %s: %v", t, err)
+			close(h.failed)
+		})
+	}
+}
+
+// minimum time between two dumps of the same track, so that a track
+// failing on every packet doesn't fill the dump file.
+const decodeErrorDumpInterval = 1 * time.Second
+
+// decodeErrorRecord is a line of the decode error dump: the packet which
+// made the depacketizer of a track fail, with its payload in hex and base64.
+type decodeErrorRecord struct {
+	Time        time.Time `json:"time"`
+	Track       int       `json:"track"`
+	Codec       string    `json:"codec"`
+	Error       string    `json:"error"`
+	Seq         uint16    `json:"sequence_number"`
+	Timestamp   uint32    `json:"timestamp"`
+	PayloadType uint8     `json:"payload_type"`
+	Marker      bool      `json:"marker"`
+	Size        int       `json:"payload_size"`
+	Hex         string    `json:"hex"`
+	Base64      string    `json:"base64"`
+}
+
+// decodeErrorDumper writes the packets on which depacketization failed into
+// a file, one JSON object per line, for at most max occurrences and at most
+// one per track every decodeErrorDumpInterval.
+type decodeErrorDumper struct {
+	max int
+
+	mutex    sync.Mutex
+	file     *os.File
+	w        *bufio.Writer
+	count    int
+	last     map[*track]time.Time
+	skipped  uint64
+	exceeded bool
+}
+
+// newDecodeErrorDumper creates the dump file at path.
+func newDecodeErrorDumper(path string, maxDumps int) (*decodeErrorDumper, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &decodeErrorDumper{
+		max:  maxDumps,
+		file: f,
+		w:    bufio.NewWriter(f),
+		last: make(map[*track]time.Time),
+	}, nil
+}
+
+// record returns the dump of a packet, or nil when it must not be dumped
+// because of the limits. It is called from the packet callback, so that
+// writing the returned record can be deferred.
+func (d *decodeErrorDumper) record(t *track, pkt *rtp.Packet, decodeErr error) *decodeErrorRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := time.Now()
+	if d.count >= d.max {
+		if !d.exceeded {
+			d.exceeded = true
+			log.Printf("WARNING: %d decode errors dumped, the following ones are not dumped", d.max)
+		}
+		return nil
+	}
+	if last, ok := d.last[t]; ok && now.Sub(last) < decodeErrorDumpInterval {
+		d.skipped++
+		return nil
+	}
+	d.last[t] = now
+	d.count++
+
+	return &decodeErrorRecord{
+		Time:        now,
+		Track:       t.index,
+		Codec:       t.media.Formats[0].Codec(),
+		Error:       decodeErr.Error(),
+		Seq:         pkt.SequenceNumber,
+		Timestamp:   pkt.Timestamp,
+		PayloadType: pkt.PayloadType,
+		Marker:      pkt.Marker,
+		Size:        len(pkt.Payload),
+		Hex:         hex.EncodeToString(pkt.Payload),
+		Base64:      base64.StdEncoding.EncodeToString(pkt.Payload),
+	}
+}
+
+// write appends a record to the file.
+func (d *decodeErrorDumper) write(rec *decodeErrorRecord) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	_, err = d.w.Write(append(buf, '\n'))
+	return err
+}
+
+// close closes the file, and logs how many errors were dumped.
+func (d *decodeErrorDumper) close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.count != 0 {
+		log.Printf("%d decode errors dumped into %s (%d more skipped by rate limiting)",
+			d.count, d.file.Name(), d.skipped)
+	}
+
+	err := d.w.Flush()
+	cerr := d.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openDecodeErrorDump dumps the packets on which depacketization fails into
+// the -decode-error-dump file.
+func (o *outputs) openDecodeErrorDump(tracks []*track) error {
+	setupDepacketizers(tracks, nil)
+	dump, err := newDecodeErrorDumper(o.cfg.outputPath(o.cfg.decodeErrorDump), o.cfg.decodeErrorDumpMax)
+	if err != nil {
+		return fmt.Errorf("creating decode error dump file: %w", err)
+	}
+	o.onClose(func() {
+		err := dump.close()
+		if err != nil {
+			log.Printf("Error closing decode error dump file: %v", err)
+		}
+	})
+	queue := o.newQueue("decode error dump")
+
+	o.decodeDump = func(t *track, pkt *rtp.Packet, err error) {
+		if rec := dump.record(t, pkt, err); rec != nil {
+			queue.push(func() error {
+				return dump.write(rec)
+			})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+// dedupKey identifies an RTP packet within a redundant stream.
+type dedupKey struct {
+	ssrc uint32
+	seq  uint16
+}
+
+// dedupFilter detects RTP packets whose (SSRC, sequence number) pair was
+// already seen among the last packets of a track, as sent by servers which
+// duplicate RTP for redundancy (SMPTE 2022-7 style or simple resend).
+// It is not safe for concurrent use: every track owns its own filter.
+type dedupFilter struct {
+	seen   map[dedupKey]struct{}
+	window []dedupKey
+	next   int
+	filled bool
+}
+
+// newDedupFilter allocates a filter remembering the last size packets.
+func newDedupFilter(size int) *dedupFilter {
+	return &dedupFilter{
+		seen:   make(map[dedupKey]struct{}, size),
+		window: make([]dedupKey, size),
+	}
+}
+
+// duplicate returns whether the packet was already seen, and remembers it
+// otherwise. The oldest remembered packet is forgotten once the window is full.
+func (f *dedupFilter) duplicate(ssrc uint32, seq uint16) bool {
+	key := dedupKey{ssrc: ssrc, seq: seq}
+	if _, ok := f.seen[key]; ok {
+		return true
+	}
+
+	if f.filled {
+		delete(f.seen, f.window[f.next])
+	}
+	f.window[f.next] = key
+	f.seen[key] = struct{}{}
+
+	f.next++
+	if f.next == len(f.window) {
+		f.next = 0
+		f.filled = true
+	}
+	return false
+}
+
+// setupDedupFilters drops the packets received twice on every track, with
+// -dedup.
+func setupDedupFilters(tracks []*track, window int) {
+	for _, t := range tracks {
+		t.dedup = newDedupFilter(window)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/gortsplib/v4/pkg/format/rtph264"
+	"github.com/bluenviron/gortsplib/v4/pkg/format/rtph265"
+	"github.com/bluenviron/gortsplib/v4/pkg/format/rtpmpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/pion/rtp"
+)
+
+// accessUnit is a media unit reassembled from RTP packets: the NAL units
+// of a video frame, or a single audio frame.
+type accessUnit struct {
+	track *track
+
+	// Presentation timestamp, in clock rate units, unwrapped from
+	// the RTP timestamps of the track :
+	pts int64
+
+	// Absolute time of the unit, derived from RTCP sender reports.
+	// It is zero until the server sent a sender report :
+	ntp time.Time
+
+	// Local time at which the last packet of the unit was received :
+	received time.Time
+
+	// Local time at which the first packet of the unit was received, and
+	// number of packets the unit was reassembled from :
+	firstReceived time.Time
+	packets       int
+
+	// Local time at which the decoder output the unit :
+	emitted time.Time
+
+	// Whether decoding can start from this unit :
+	keyframe bool
+
+	// NAL units for video, a single frame for audio :
+	units [][]byte
+}
+
+// depacketizer reassembles the access units of a track from its RTP packets.
+// It is not safe for concurrent use: it must only be fed by the packet
+// callback of its track.
+type depacketizer struct {
+	track     *track
+	format    format.Format
+	clockRate int64
+	ts        tsUnwrapper
+
+	// decodes a packet into zero or more units; returns errMorePackets
+	// while a unit is incomplete.
+	decode func(pkt *rtp.Packet) ([][]byte, error)
+	// tells whether the units form a keyframe (video),
+	// or nil when every unit is decodable on its own (audio).
+	keyframe func(units [][]byte) bool
+	// spacing between units decoded from the same packet,
+	// or zero when a packet decodes into a single unit.
+	unitDuration int64
+
+	// reception time of the first packet of the current unit, and number
+	// of packets received for it.
+	pendingSince   time.Time
+	pendingPackets int
+}
+
+// errMorePackets is returned by the decode function of a depacketizer
+// while the current unit is incomplete.
+var errMorePackets = errors.New("more packets needed")
+
+// depacketizerSupported returns whether the access units of a format can be
+// reassembled.
+func depacketizerSupported(forma format.Format) bool {
+	switch forma.(type) {
+	case *format.H264, *format.H265, *format.MPEG4Audio:
+		return true
+	}
+	return false
+}
+
+// setupDepacketizers creates the depacketizer of the SETUP tracks selected
+// by want, or of all of them when want is nil, for the outputs which need
+// their access units. Outputs share the depacketizer of a track, created
+// once. Tracks whose codec is not supported are skipped, as reported by
+// warnUnsupportedTracks, and the other errors are logged once.
+func setupDepacketizers(tracks []*track, want func(t *track) bool) {
+	for _, t := range tracks {
+		if !t.setup || t.depacketizer != nil || t.depacketizerErr != nil ||
+			!depacketizerSupported(t.media.Formats[0]) {
+			continue
+		}
+		if want != nil && !want(t) {
+			continue
+		}
+		d, err := newDepacketizer(t, t.media.Formats[0])
+		if err != nil {
+			t.depacketizerErr = err
+			log.Printf("Error creating the depacketizer of track %s, its access units won't be output: %v", t, err)
+			continue
+		}
+		t.depacketizer = d
+	}
+}
+
+// newDepacketizer creates a depacketizer for the given format of a track.
+// It fails when the codec is not supported.
+func newDepacketizer(t *track, forma format.Format) (*depacketizer, error) {
+	d := &depacketizer{
+		track:     t,
+		format:    forma,
+		clockRate: int64(forma.ClockRate()),
+	}
+
+	switch forma := forma.(type) {
+	case *format.H264:
+		dec, err := forma.CreateDecoder()
+		if err != nil {
+			return nil, err
+		}
+		d.decode = func(pkt *rtp.Packet) ([][]byte, error) {
+			au, err := dec.Decode(pkt)
+			if errors.Is(err, rtph264.ErrMorePacketsNeeded) ||
+				errors.Is(err, rtph264.ErrNonStartingPacketAndNoPrevious) {
+				return nil, errMorePackets
+			}
+			return au, err
+		}
+		d.keyframe = h264.IDRPresent
+
+	case *format.H265:
+		dec, err := forma.CreateDecoder()
+		if err != nil {
+			return nil, err
+		}
+		d.decode = func(pkt *rtp.Packet) ([][]byte, error) {
+			au, err := dec.Decode(pkt)
+			if errors.Is(err, rtph265.ErrMorePacketsNeeded) ||
+				errors.Is(err, rtph265.ErrNonStartingPacketAndNoPrevious) {
+				return nil, errMorePackets
+			}
+			return au, err
+		}
+		d.keyframe = h265.IsRandomAccess
+
+	case *format.MPEG4Audio:
+		dec, err := forma.CreateDecoder()
+		if err != nil {
+			return nil, err
+		}
+		d.decode = func(pkt *rtp.Packet) ([][]byte, error) {
+			aus, err := dec.Decode(pkt)
+			if errors.Is(err, rtpmpeg4audio.ErrMorePacketsNeeded) {
+				return nil, errMorePackets
+			}
+			return aus, err
+		}
+		d.unitDuration = mpeg4audio.SamplesPerAccessUnit
+
+	default:
+		return nil, fmt.Errorf("codec %s is not supported", forma.Codec())
+	}
+
+	return d, nil
+}
+
+// push decodes a packet and returns the access units it completes.
+// ntp is the absolute time of the packet, or zero when unknown.
+func (d *depacketizer) push(pkt *rtp.Packet, ntp time.Time) ([]*accessUnit, error) {
+	// Unwrap every timestamp, even of incomplete units, to follow wraparounds :
+	pts := d.ts.unwrap(pkt.Timestamp)
+
+	received := time.Now()
+	if d.pendingPackets == 0 {
+		d.pendingSince = received
+	}
+	d.pendingPackets++
+
+	units, err := d.decode(pkt)
+	if err != nil {
+		if errors.Is(err, errMorePackets) {
+			return nil, nil
+		}
+		d.pendingPackets = 0
+		return nil, err
+	}
+
+	firstReceived, packets := d.pendingSince, d.pendingPackets
+	d.pendingPackets = 0
+	emitted := time.Now()
+
+	// Video: all the NAL units form a single access unit :
+	if d.keyframe != nil {
+		return []*accessUnit{{
+			track:         d.track,
+			pts:           pts,
+			ntp:           ntp,
+			received:      received,
+			firstReceived: firstReceived,
+			packets:       packets,
+			emitted:       emitted,
+			keyframe:      d.keyframe(units),
+			units:         units,
+		}}, nil
+	}
+
+	// Audio: every unit is a frame of its own, following the previous one :
+	aus := make([]*accessUnit, len(units))
+	for i, unit := range units {
+		offset := int64(i) * d.unitDuration
+		au := &accessUnit{
+			track:         d.track,
+			pts:           pts + offset,
+			received:      received,
+			firstReceived: firstReceived,
+			packets:       packets,
+			emitted:       emitted,
+			keyframe:      true,
+			units:         [][]byte{unit},
+		}
+		if !ntp.IsZero() {
+			au.ntp = ntp.Add(ticksToDuration(offset, d.clockRate))
+		}
+		aus[i] = au
+	}
+	return aus, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/liberrors"
+)
+
+// commonPaths are the stream paths tried by -probe-all, covering the
+// defaults of widespread cameras, encoders and servers.
+var commonPaths = []string{
+	"/",
+	"/live",
+	"/live.sdp",
+	"/live/main",
+	"/live/ch00_0",
+	"/stream",
+	"/stream1",
+	"/stream2",
+	"/h264",
+	"/h264.sdp",
+	"/video",
+	"/video1",
+	"/media/video1",
+	"/onvif1",
+	"/profile1",
+	"/11",
+	"/12",
+	"/Streaming/Channels/101",
+	"/Streaming/Channels/102",
+	"/cam/realmonitor?channel=1&subtype=0",
+	"/cam/realmonitor?channel=1&subtype=1",
+	"/axis-media/media.amp",
+	"/videoMain",
+	"/MediaInput/h264",
+	"/mpeg4",
+	"/ch0_0.h264",
+}
+
+// pathResult is the outcome of the DESCRIBE of a path.
+type pathResult struct {
+	path   string
+	valid  bool
+	result string
+	medias string
+}
+
+// readPathsFile returns the paths listed in a file, one per line. Blank
+// lines and lines starting with # are ignored.
+func readPathsFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			line = "/" + line
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no path in %s", name)
+	}
+	return paths, nil
+}
+
+// probePaths DESCRIBEs a list of paths on the server of the URL, and prints
+// a table of the outcome of each one. At most cfg.probeConcurrency requests
+// are in flight, and requests start at least cfg.probeInterval apart, not to
+// hammer the server. It returns 0 when at least one path returned a valid SDP.
+func probePaths(cfg *config) int {
+	u, err := base.ParseURL(cfg.url)
+	if err != nil {
+		log.Printf("Cannot parse RTSP URL : %v", err)
+		return 1
+	}
+
+	paths := commonPaths
+	if cfg.pathsFile != "" {
+		paths, err = readPathsFile(cfg.pathsFile)
+		if err != nil {
+			log.Printf("Error reading paths file: %v", err)
+			return 1
+		}
+	}
+
+	log.Printf("Probing %d paths on %s", len(paths), u.Host)
+
+	results := make([]pathResult, len(paths))
+	slots := make(chan struct{}, cfg.probeConcurrency)
+	var wg sync.WaitGroup
+
+	for i, path := range paths {
+		if i != 0 {
+			time.Sleep(cfg.probeInterval)
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = describePath(cfg, u, path)
+		}()
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tRESULT\tMEDIAS")
+	found := 0
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.path, r.result, r.medias)
+		if r.valid {
+			found++
+		}
+	}
+	w.Flush()
+
+	log.Printf("%d of %d paths returned a valid SDP", found, len(paths))
+	if found == 0 {
+		return 1
+	}
+	return 0
+}
+
+// describePath DESCRIBEs a path on the server of u, with its credentials.
+func describePath(cfg *config, u *base.URL, path string) pathResult {
+	r := pathResult{path: path}
+
+	rawPath, rawQuery, _ := strings.Cut(path, "?")
+	pu := u.Clone()
+	pu.Path = rawPath
+	pu.RawPath = ""
+	pu.RawQuery = rawQuery
+
+	desc, _, err := describeOnly(cfg, pu.String())
+	if err != nil {
+		var statusErr liberrors.ErrClientBadStatusCode
+		if errors.As(err, &statusErr) {
+			r.result = strconv.Itoa(int(statusErr.Code)) + " " + statusErr.Message
+		} else {
+			r.result = err.Error()
+		}
+		return r
+	}
+
+	r.valid = true
+	r.result = "valid SDP"
+	medias := make([]string, len(desc.Medias))
+	for i, medi := range desc.Medias {
+		medias[i] = mediaSummary(medi)
+	}
+	r.medias = strings.Join(medias, ", ")
+	return r
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+// minimum span of the sender reports over which the drift of a track is
+// measured; over shorter spans, the jitter of the reports dominates.
+const driftMinSpan = 30 * time.Second
+
+// clockDrift measures the rate of the RTP clock of a track against the NTP
+// clock of the server, from the first and the last RTCP sender reports.
+// Tracks sharing a reference clock drift alike: a difference between their
+// drifts means that their timelines move apart, and so do audio and video.
+type clockDrift struct {
+	mutex    sync.Mutex
+	ssrc     uint32
+	ts       tsUnwrapper
+	reports  uint64
+	firstNTP uint64
+	lastNTP  uint64
+	firstRTP int64
+	lastRTP  int64
+}
+
+// onSenderReport records a sender report. The measure starts over when the
+// SSRC changes.
+func (d *clockDrift) onSenderReport(sr *rtcp.SenderReport) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.reports == 0 || sr.SSRC != d.ssrc {
+		d.ssrc = sr.SSRC
+		d.ts = tsUnwrapper{}
+		d.reports = 0
+		d.firstNTP = sr.NTPTime
+		d.firstRTP = d.ts.unwrap(sr.RTPTime)
+	}
+	d.reports++
+	d.lastNTP = sr.NTPTime
+	d.lastRTP = d.ts.unwrap(sr.RTPTime)
+}
+
+// ppm returns the drift of the RTP clock from its nominal rate, in parts per
+// million, once the reports span driftMinSpan.
+func (d *clockDrift) ppm(clockRate int) (float64, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	// NTP times are in seconds since 1900, with a 32-bit fractional part :
+	span := float64(d.lastNTP-d.firstNTP) / (1 << 32)
+	if clockRate <= 0 || d.reports < 2 || span < driftMinSpan.Seconds() {
+		return 0, false
+	}
+	elapsed := float64(d.lastRTP-d.firstRTP) / float64(clockRate)
+	return math.Round((elapsed/span-1)*1e7) / 10, true
+}
+
+// driftPair is the relative drift between the clocks of two tracks, in the
+// final report.
+type driftPair struct {
+	Tracks   [2]int  `json:"tracks"`
+	PPM      float64 `json:"ppm"`
+	Exceeded bool    `json:"exceeded,omitempty"`
+}
+
+// driftMonitor compares the drifts of the tracks whose RTCP is processed,
+// and warns once per pair of tracks when their relative drift exceeds
+// -max-clock-drift.
+type driftMonitor struct {
+	tracks []*track
+	// maximum relative drift in ppm, or zero to never warn.
+	max float64
+
+	mutex  sync.Mutex
+	warned map[[2]int]bool
+}
+
+// newDriftMonitor creates the monitor of the tracks.
+func newDriftMonitor(tracks []*track, max float64) *driftMonitor {
+	return &driftMonitor{
+		tracks: tracks,
+		max:    max,
+		warned: make(map[[2]int]bool),
+	}
+}
+
+// pairs returns the relative drift of every pair of tracks whose drift is
+// known.
+func (m *driftMonitor) pairs() []driftPair {
+	type measure struct {
+		t   *track
+		ppm float64
+	}
+	var measures []measure
+	for _, t := range m.tracks {
+		if !t.rtcp {
+			continue
+		}
+		if ppm, ok := t.drift.ppm(t.media.Formats[0].ClockRate()); ok {
+			measures = append(measures, measure{t, ppm})
+		}
+	}
+
+	var pairs []driftPair
+	for i, a := range measures {
+		for _, b := range measures[i+1:] {
+			ppm := math.Round((a.ppm-b.ppm)*10) / 10
+			pairs = append(pairs, driftPair{
+				Tracks:   [2]int{a.t.index, b.t.index},
+				PPM:      ppm,
+				Exceeded: m.max != 0 && math.Abs(ppm) > m.max,
+			})
+		}
+	}
+	return pairs
+}
+
+// check warns about the pairs of tracks drifting apart beyond the maximum.
+// It is called on every sender report.
+func (m *driftMonitor) check() {
+	if m.max == 0 {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, p := range m.pairs() {
+		if !p.Exceeded || m.warned[p.Tracks] {
+			continue
+		}
+		m.warned[p.Tracks] = true
+		// a drift of 1 ppm amounts to 3.6ms per hour :
+		perHour := time.Duration(math.Abs(p.PPM) * 3.6 * float64(time.Millisecond))
+		log.Printf("WARNING: clocks of tracks #%d and #%d drift apart by %.1f ppm, beyond -max-clock-drift %v ppm: "+
+			"they move %v apart per hour", p.Tracks[0], p.Tracks[1], p.PPM, m.max, perHour.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// failureDump is the context of a failed SETUP or PLAY, meant to be
+// attached to bug reports.
+type failureDump struct {
+	URL    string        `json:"url"`
+	Step   string        `json:"step"`
+	Error  string        `json:"error,omitempty"`
+	SDP    string        `json:"sdp"`
+	Tracks []setupReport `json:"tracks"`
+}
+
+// dumpFailure writes the raw SDP and the outcome of the SETUP of every
+// track, after the given step failed, into the -dump-sdp-path file or
+// on stderr.
+func dumpFailure(cfg *config, step string, err error, rawSDP []byte, tracks []*track) {
+	d := &failureDump{
+		URL:    cfg.url,
+		Step:   step,
+		SDP:    string(rawSDP),
+		Tracks: newSetupSummary(cfg, tracks).Tracks,
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+
+	if cfg.dumpSDPPath == "" {
+		err = printJSON(os.Stderr, d, true)
+		if err != nil {
+			log.Printf("Error dumping failure context: %v", err)
+		}
+		return
+	}
+
+	f, err := os.Create(cfg.dumpSDPPath)
+	if err != nil {
+		log.Printf("Error dumping failure context: %v", err)
+		return
+	}
+	defer f.Close()
+
+	err = printJSON(f, d, true)
+	if err != nil {
+		log.Printf("Error dumping failure context: %v", err)
+		return
+	}
+	log.Printf("Failure context dumped into %s", cfg.dumpSDPPath)
+}
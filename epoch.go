@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/pion/rtp"
+)
+
+// rtpEpoch anchors the RTP timestamps of a track to an absolute time given by
+// the user: timestamp ts was sampled at time at.
+type rtpEpoch struct {
+	ts uint32
+	at time.Time
+
+	// last mapped time, from which the next timestamp is unwrapped :
+	mutex sync.Mutex
+	last  time.Time
+}
+
+// absoluteTime maps an RTP timestamp to an absolute time. Since RTP
+// timestamps wrap around, the time closest to the previous one is chosen,
+// starting from the anchor.
+func (e *rtpEpoch) absoluteTime(ts uint32, clockRate int) (time.Time, bool) {
+	if clockRate <= 0 {
+		return time.Time{}, false
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	ref := e.last
+	if ref.IsZero() {
+		ref = e.at
+	}
+
+	period := ticksToDuration(1<<32, int64(clockRate))
+	elapsed := ticksToDuration(int64(ts-e.ts), int64(clockRate))
+
+	// Number of whole periods between the anchor and the reference :
+	periods := ref.Sub(e.at) / period
+	t := e.at.Add(periods*period + elapsed)
+	if d := t.Sub(ref); d > period/2 {
+		t = t.Add(-period)
+	} else if d < -period/2 {
+		t = t.Add(period)
+	}
+	e.last = t
+	return t, true
+}
+
+// rtpEpochFlag is a flag holding the user-supplied epochs of -rtp-epoch, by
+// track index, in the track=rtp_ts@RFC3339 form.
+type rtpEpochFlag map[int]*rtpEpoch
+
+// String implements flag.Value.
+func (m *rtpEpochFlag) String() string {
+	indexes := make([]int, 0, len(*m))
+	for i := range *m {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	entries := make([]string, len(indexes))
+	for j, i := range indexes {
+		e := (*m)[i]
+		entries[j] = fmt.Sprintf("%d=%d@%s", i, e.ts, e.at.Format(time.RFC3339Nano))
+	}
+	return strings.Join(entries, ",")
+}
+
+// Set implements flag.Value. It can be given several times, or with a
+// comma-separated list.
+func (m *rtpEpochFlag) Set(s string) error {
+	if *m == nil {
+		*m = make(rtpEpochFlag)
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		indexStr, anchor, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("invalid RTP epoch %q: expected track=rtp_ts@time", entry)
+		}
+
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid track index %q", indexStr)
+		}
+
+		tsStr, atStr, ok := strings.Cut(anchor, "@")
+		if !ok {
+			return fmt.Errorf("invalid RTP epoch %q: expected track=rtp_ts@time", entry)
+		}
+
+		ts, err := strconv.ParseUint(tsStr, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid RTP timestamp %q", tsStr)
+		}
+
+		at, err := time.Parse(time.RFC3339Nano, atStr)
+		if err != nil {
+			return fmt.Errorf("invalid time %q: expected RFC 3339, like 2006-01-02T15:04:05.999Z", atStr)
+		}
+
+		(*m)[index] = &rtpEpoch{ts: uint32(ts), at: at}
+	}
+	return nil
+}
+
+// packetTime returns the absolute time of a packet of the track, from the
+// epoch given with -rtp-epoch, unwrapped from packet to packet so that it is
+// applied to all of them. Else, when mapped is set because an output needs
+// it, the time comes from the RTCP sender reports, through packetNTP, or
+// else from the media clock declared in the SDP. It returns the zero time
+// when there is none.
+func (t *track) packetTime(pkt *rtp.Packet, clockRate int, mapped bool,
+	packetNTP func(*description.Media, *rtp.Packet) (time.Time, bool),
+) time.Time {
+	switch {
+	case t.epoch != nil:
+		if at, ok := t.epoch.absoluteTime(pkt.Timestamp, clockRate); ok {
+			t.logTimeSource("the epoch given with -rtp-epoch")
+			return at
+		}
+
+	case mapped:
+		if t.rtcp {
+			if at, ok := packetNTP(t.media, pkt); ok {
+				t.logTimeSource("RTCP sender reports")
+				return at
+			}
+		}
+		if t.clock != nil {
+			if at, ok := t.clock.absoluteTime(pkt.Timestamp, clockRate, time.Now()); ok {
+				t.logTimeSource("the media clock declared in the SDP")
+				return at
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// logTimeSource logs, once per source, where the absolute times of the
+// packets of a track come from. It must be called from the packet callback
+// of the track.
+func (t *track) logTimeSource(source string) {
+	if t.timeSource == source {
+		return
+	}
+	t.timeSource = source
+	log.Printf("Track %s: absolute packet times derived from %s", t, source)
+}
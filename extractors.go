@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+)
+
+// mediaExtractor is an output which extracts the media of the tracks, and
+// can only do so for some codecs.
+type mediaExtractor struct {
+	flag      string
+	enabled   func(cfg *config) bool
+	supported func(forma format.Format) bool
+}
+
+// mediaExtractors is the registry of the outputs extracting media, checked
+// before streaming starts to warn about the tracks they will skip.
+var mediaExtractors = []mediaExtractor{
+	{
+		flag:      "-mp4-out",
+		enabled:   func(cfg *config) bool { return cfg.mp4Out != "" },
+		supported: mp4Supported,
+	},
+	{
+		flag:      "-cmaf-out",
+		enabled:   func(cfg *config) bool { return cfg.cmafOut != "" },
+		supported: mp4Supported,
+	},
+	{
+		flag:      "-gop-report",
+		enabled:   func(cfg *config) bool { return cfg.gopReport },
+		supported: gopSupported,
+	},
+	{
+		flag:      "-trace-frames",
+		enabled:   func(cfg *config) bool { return cfg.traceFrames != "" },
+		supported: depacketizerSupported,
+	},
+	{
+		flag:      "-decode-error-dump",
+		enabled:   func(cfg *config) bool { return cfg.decodeErrorDump != "" },
+		supported: depacketizerSupported,
+	},
+	{
+		flag:      "-frame-timeline-out",
+		enabled:   func(cfg *config) bool { return cfg.frameTimelineOut != "" },
+		supported: depacketizerSupported,
+	},
+	{
+		flag:      "-verify-checksums",
+		enabled:   func(cfg *config) bool { return cfg.verifyChecksums },
+		supported: depacketizerSupported,
+	},
+	{
+		flag:      "-grpc-addr",
+		enabled:   func(cfg *config) bool { return cfg.grpcAddr != "" },
+		supported: depacketizerSupported,
+	},
+	{
+		flag:      "-sei-out",
+		enabled:   func(cfg *config) bool { return cfg.seiOut != "" },
+		supported: seiSupported,
+	},
+	{
+		flag:      "-measure-loudness",
+		enabled:   func(cfg *config) bool { return cfg.measureLoudness },
+		supported: loudnessSupported,
+	},
+}
+
+// warnUnsupportedTracks warns once about every SETUP track whose codec
+// can't be extracted by some of the enabled outputs, naming them. It
+// returns whether it warned.
+func warnUnsupportedTracks(cfg *config, tracks []*track) bool {
+	warned := false
+	for _, t := range tracks {
+		if !t.setup {
+			continue
+		}
+
+		forma := t.media.Formats[0]
+		var flags []string
+		for _, e := range mediaExtractors {
+			if e.enabled(cfg) && !e.supported(forma) {
+				flags = append(flags, e.flag)
+			}
+		}
+		if len(flags) == 0 {
+			continue
+		}
+
+		log.Printf("WARNING: codec %s of track %s is not supported by %s, which will skip the track",
+			t.codec(), t, strings.Join(flags, ", "))
+		warned = true
+	}
+	return warned
+}
+
+// knownFormats are the formats recognized by the RTSP library, one per
+// codec, against which -list-codecs checks the extractors.
+var knownFormats = []format.Format{
+	&format.H264{},
+	&format.H265{},
+	&format.AV1{},
+	&format.VP8{},
+	&format.VP9{},
+	&format.MJPEG{},
+	&format.MPEG1Video{},
+	&format.MPEG4Video{},
+	&format.MPEG4Audio{},
+	&format.MPEG1Audio{},
+	&format.AC3{},
+	&format.Opus{},
+	&format.G711{},
+	&format.G722{},
+	&format.G726{},
+	&format.LPCM{BitDepth: 16},
+	&format.Speex{},
+	&format.MPEGTS{},
+}
+
+// printCodecs prints the codecs that some output extracts, each with the
+// outputs extracting it, followed by the codecs which are only analyzed at
+// the RTP level.
+func printCodecs(w io.Writer) {
+	var others []string
+	for _, forma := range knownFormats {
+		var flags []string
+		for _, e := range mediaExtractors {
+			if e.supported(forma) {
+				flags = append(flags, e.flag)
+			}
+		}
+		if len(flags) == 0 {
+			others = append(others, forma.Codec())
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", forma.Codec(), strings.Join(flags, ", "))
+	}
+	if len(others) != 0 {
+		fmt.Fprintf(w, "RTP level only: %s\n", strings.Join(others, ", "))
+	}
+}
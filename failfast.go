@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// failFast ends the capture with an error at the first warning which
+// -fail-fast makes fatal, so that CI can treat a capture as a contract:
+//   - a selected track which can't be SETUP;
+//   - an RTCP port which can't be bound with -rtcp-optional;
+//   - a codec which an enabled output can't extract;
+//   - packets of a payload type which the SDP doesn't declare;
+//   - a SETUP track receiving no packet within -stall-timeout.
+//
+// The warnings of the first three are raised before PLAY, and abort the
+// capture at once. The others are raised while streaming, and close
+// tripped, which ends the session.
+type failFast struct {
+	enabled bool
+	once    sync.Once
+	tripped chan struct{}
+}
+
+// newFailFast creates the trigger of -fail-fast, which does nothing unless
+// enabled.
+func newFailFast(enabled bool) *failFast {
+	return &failFast{
+		enabled: enabled,
+		tripped: make(chan struct{}),
+	}
+}
+
+// trip closes tripped after a fatal warning, when enabled. It may be called
+// from any goroutine.
+func (f *failFast) trip(warning string) {
+	if !f.enabled {
+		return
+	}
+	f.once.Do(func() {
+		log.Printf("Error: %s, aborting (-fail-fast)", warning)
+		close(f.tripped)
+	})
+}
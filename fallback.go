@@ -0,0 +1,56 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// transportSwitch is a switch of the client from UDP to TCP, as it appears
+// in the final report.
+type transportSwitch struct {
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+	// time UDP was attempted before switching :
+	UDPAttempted string `json:"udp_attempted"`
+}
+
+// fallbackRecorder records the switches of the client from UDP to TCP,
+// which happen when the server refuses UDP or when no UDP packet arrives.
+type fallbackRecorder struct {
+	mutex    sync.Mutex
+	udpSince time.Time
+	switches []transportSwitch
+}
+
+// start marks the beginning of an attempt to use UDP, at SETUP.
+func (r *fallbackRecorder) start() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.udpSince = time.Now()
+}
+
+// onTransportSwitch implements gortsplib.ClientOnTransportSwitchFunc.
+func (r *fallbackRecorder) onTransportSwitch(err error) {
+	log.Println(err.Error())
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	s := transportSwitch{
+		At:     now,
+		Reason: err.Error(),
+	}
+	if !r.udpSince.IsZero() {
+		s.UDPAttempted = now.Sub(r.udpSince).Round(time.Millisecond).String()
+	}
+	r.switches = append(r.switches, s)
+}
+
+// report returns the switches recorded so far.
+func (r *fallbackRecorder) report() []transportSwitch {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]transportSwitch(nil), r.switches...)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// flatField is a field of a flattened JSON object: a dotted key and the
+// JSON encoding of its value.
+type flatField struct {
+	key   string
+	value []byte
+}
+
+// marshalFlatJSON returns the JSON encoding of v as a single-level object,
+// whose keys are the dotted paths of the values in the nested encoding, as
+// in extensions.0.id. The order of the fields is kept. Empty objects and
+// arrays are kept as values, so that no key disappears.
+func marshalFlatJSON(v any, pretty bool) ([]byte, error) {
+	nested, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(nested))
+	dec.UseNumber()
+	var fields []flatField
+	err = flattenJSONValue(dec, "", &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range fields {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(f.value)
+	}
+	buf.WriteByte('}')
+
+	if !pretty {
+		return buf.Bytes(), nil
+	}
+	var indented bytes.Buffer
+	err = json.Indent(&indented, buf.Bytes(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// flattenJSONValue reads the next value of the decoder, and appends its
+// fields under prefix.
+func flattenJSONValue(dec *json.Decoder, prefix string, fields *[]flatField) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		value, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		*fields = append(*fields, flatField{key: prefix, value: value})
+		return nil
+	}
+
+	n := 0
+	for ; dec.More(); n++ {
+		key := strconv.Itoa(n)
+		if delim == '{' {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok = keyTok.(string)
+			if !ok {
+				return fmt.Errorf("unexpected JSON token %v", keyTok)
+			}
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		err = flattenJSONValue(dec, key, fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	// consume the closing delimiter :
+	_, err = dec.Token()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 && prefix != "" {
+		empty := "[]"
+		if delim == '{' {
+			empty = "{}"
+		}
+		*fields = append(*fields, flatField{key: prefix, value: []byte(empty)})
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+)
+
+// formatDump describes a format of a media, with its fmtp parameters
+// parsed into structured values.
+type formatDump struct {
+	Media       int            `json:"media"`
+	PayloadType uint8          `json:"payload_type"`
+	Codec       string         `json:"codec"`
+	RTPMap      string         `json:"rtpmap,omitempty"`
+	FMTP        map[string]any `json:"fmtp,omitempty"`
+}
+
+// parameterSet is a decoded H264 or H265 parameter set.
+type parameterSet struct {
+	Type string `json:"type"`
+	Hex  string `json:"hex"`
+}
+
+// h264ProfileLevel is the decoded profile-level-id of H264.
+type h264ProfileLevel struct {
+	ProfileIDC  int    `json:"profile_idc"`
+	Constraints string `json:"constraints"`
+	LevelIDC    int    `json:"level_idc"`
+}
+
+// aacConfig is the decoded AudioSpecificConfig of AAC.
+type aacConfig struct {
+	ObjectType   int    `json:"object_type"`
+	SampleRate   int    `json:"sample_rate"`
+	ChannelCount int    `json:"channel_count"`
+	Hex          string `json:"hex"`
+}
+
+// newFormatDumps describes every format of the session description.
+func newFormatDumps(desc *description.Session) []formatDump {
+	dumps := []formatDump{}
+	for i, medi := range desc.Medias {
+		for _, forma := range medi.Formats {
+			dumps = append(dumps, formatDump{
+				Media:       i,
+				PayloadType: forma.PayloadType(),
+				Codec:       forma.Codec(),
+				RTPMap:      forma.RTPMap(),
+				FMTP:        parseFMTP(forma),
+			})
+		}
+	}
+	return dumps
+}
+
+// parseFMTP returns the fmtp parameters of a format as structured values:
+// parameter sets and codec configurations are decoded, numbers are parsed,
+// and other values are kept as strings. Values which can't be decoded are
+// kept as strings too.
+func parseFMTP(forma format.Format) map[string]any {
+	params := forma.FMTP()
+	if len(params) == 0 {
+		return nil
+	}
+
+	out := make(map[string]any, len(params))
+	for key, value := range params {
+		if v := parseFMTPValue(forma, strings.ToLower(key), value); v != nil {
+			out[key] = v
+			continue
+		}
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			out[key] = n
+			continue
+		}
+		out[key] = value
+	}
+	return out
+}
+
+// parseFMTPValue decodes a codec-specific fmtp parameter, or returns nil.
+func parseFMTPValue(forma format.Format, key string, value string) any {
+	switch forma.(type) {
+	case *format.H264:
+		switch key {
+		case "sprop-parameter-sets":
+			return decodeParameterSets(value, func(nalu []byte) string {
+				return h264.NALUType(nalu[0] & 0x1F).String()
+			})
+
+		case "profile-level-id":
+			buf, err := hex.DecodeString(value)
+			if err != nil || len(buf) != 3 {
+				return nil
+			}
+			return &h264ProfileLevel{
+				ProfileIDC:  int(buf[0]),
+				Constraints: fmt.Sprintf("%08b", buf[1]),
+				LevelIDC:    int(buf[2]),
+			}
+		}
+
+	case *format.H265:
+		switch key {
+		case "sprop-vps", "sprop-sps", "sprop-pps":
+			return decodeParameterSets(value, func(nalu []byte) string {
+				return h265.NALUType((nalu[0] >> 1) & 0x3F).String()
+			})
+		}
+
+	case *format.MPEG4Audio:
+		if key == "config" {
+			buf, err := hex.DecodeString(value)
+			if err != nil {
+				return nil
+			}
+			var conf mpeg4audio.AudioSpecificConfig
+			if conf.Unmarshal(buf) != nil {
+				return nil
+			}
+			return &aacConfig{
+				ObjectType:   int(conf.Type),
+				SampleRate:   conf.SampleRate,
+				ChannelCount: conf.ChannelCount,
+				Hex:          value,
+			}
+		}
+	}
+	return nil
+}
+
+// decodeParameterSets decodes a comma-separated list of base64 NAL units.
+func decodeParameterSets(value string, naluType func([]byte) string) []parameterSet {
+	var sets []parameterSet
+	for _, part := range strings.Split(value, ",") {
+		nalu, err := base64.StdEncoding.DecodeString(strings.TrimSpace(part))
+		if err != nil || len(nalu) == 0 {
+			return nil
+		}
+		sets = append(sets, parameterSet{
+			Type: naluType(nalu),
+			Hex:  hex.EncodeToString(nalu),
+		})
+	}
+	return sets
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pion/rtp"
+)
+
+// udpForwardFlag is a flag holding the destinations of -forward-udp: a
+// host:port receiving the packets of every track, and host:port receiving
+// the packets of some tracks, by track index, in the index=host:port form.
+type udpForwardFlag struct {
+	all    string
+	tracks map[int]string
+}
+
+// String implements flag.Value.
+func (f *udpForwardFlag) String() string {
+	var entries []string
+	if f.all != "" {
+		entries = append(entries, f.all)
+	}
+	indexes := make([]int, 0, len(f.tracks))
+	for i := range f.tracks {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		entries = append(entries, strconv.Itoa(i)+"="+f.tracks[i])
+	}
+	return strings.Join(entries, ",")
+}
+
+// Set implements flag.Value. It can be given several times, or with a
+// comma-separated list.
+func (f *udpForwardFlag) Set(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		indexStr, addr, perTrack := strings.Cut(entry, "=")
+		if !perTrack {
+			addr = entry
+		}
+
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || host == "" {
+			return fmt.Errorf("invalid UDP destination %q: expected host:port or track=host:port", entry)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("invalid port %q", port)
+		}
+
+		if !perTrack {
+			f.all = addr
+			continue
+		}
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid track index %q", indexStr)
+		}
+		if f.tracks == nil {
+			f.tracks = make(map[int]string)
+		}
+		f.tracks[index] = addr
+	}
+	return nil
+}
+
+// isSet returns whether a destination was given.
+func (f *udpForwardFlag) isSet() bool {
+	return f.all != "" || len(f.tracks) != 0
+}
+
+// udpForwarder sends every RTP packet, as received, to the UDP destination of
+// its track, with -forward-udp, for ffmpeg's udp:// input or any receiver of
+// plain RTP. Send errors, such as the ICMP port unreachable of a receiver
+// which isn't started yet, are counted and logged without interrupting the
+// forwarding.
+type udpForwarder struct {
+	// sockets by track index, nil for the tracks which are not forwarded.
+	conns []*net.UDPConn
+
+	sent     atomic.Uint64
+	failures atomic.Uint64
+}
+
+// newUDPForwarder opens the sockets of the tracks forwarded by dests.
+// Per-track destinations take precedence over the destination of every
+// track.
+func newUDPForwarder(dests *udpForwardFlag, tracks []*track) (*udpForwarder, error) {
+	for i := range dests.tracks {
+		if i >= len(tracks) {
+			return nil, fmt.Errorf("there is no track #%d", i)
+		}
+	}
+
+	f := &udpForwarder{conns: make([]*net.UDPConn, len(tracks))}
+	for _, t := range tracks {
+		addr := dests.all
+		if a, ok := dests.tracks[t.index]; ok {
+			addr = a
+		}
+		if addr == "" {
+			continue
+		}
+
+		raddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			f.closeSockets()
+			return nil, err
+		}
+		conn, err := net.DialUDP("udp", nil, raddr)
+		if err != nil {
+			f.closeSockets()
+			return nil, err
+		}
+		f.conns[t.index] = conn
+		log.Printf("Forwarding the RTP packets of track %s to udp://%s", t, raddr)
+	}
+	return f, nil
+}
+
+// write sends a packet of t to its destination, if any.
+func (f *udpForwarder) write(t *track, pkt *rtp.Packet) {
+	conn := f.conns[t.index]
+	if conn == nil {
+		return
+	}
+
+	buf, err := pkt.Marshal()
+	if err == nil {
+		_, err = conn.Write(buf)
+	}
+	if err != nil {
+		if n := f.failures.Add(1); n == 1 || n%100 == 0 {
+			log.Printf("WARNING: can't forward packet of track %s: %v (%d failures so far)", t, err, n)
+		}
+		return
+	}
+	f.sent.Add(1)
+}
+
+// closeSockets closes the sockets, and returns whether there were some.
+func (f *udpForwarder) closeSockets() bool {
+	closed := false
+	for _, conn := range f.conns {
+		if conn != nil {
+			conn.Close()
+			closed = true
+		}
+	}
+	return closed
+}
+
+// close closes the sockets, and logs the number of packets forwarded.
+func (f *udpForwarder) close() {
+	if f.closeSockets() {
+		log.Printf("Forwarded %d RTP packets over UDP, %d could not be sent", f.sent.Load(), f.failures.Load())
+	}
+}
+
+// openUDPForward forwards the RTP packets to the destinations of
+// -forward-udp.
+func (o *outputs) openUDPForward(tracks []*track) error {
+	forwarder, err := newUDPForwarder(&o.cfg.forwardUDP, tracks)
+	if err != nil {
+		return fmt.Errorf("setting up -forward-udp: %w", err)
+	}
+	o.onClose(forwarder.close)
+	queue := o.newQueue("UDP forward")
+
+	o.onRTP(func(t *track, pkt *rtp.Packet) {
+		queue.push(func() error {
+			forwarder.write(t, pkt)
+			return nil
+		})
+	})
+	return nil
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// traceEvent is an event of the Chrome trace event format, which can be
+// opened in chrome://tracing or Perfetto. Timestamps and durations are in
+// microseconds.
+type traceEvent struct {
+	Name     string         `json:"name"`
+	Category string         `json:"cat,omitempty"`
+	Phase    string         `json:"ph"`
+	TS       float64        `json:"ts"`
+	Duration float64        `json:"dur,omitempty"`
+	PID      int            `json:"pid"`
+	TID      int            `json:"tid"`
+	Args     map[string]any `json:"args,omitempty"`
+}
+
+// frameTracer writes the reassembly timeline of every access unit into a
+// trace file, with one row per track: each frame spans from the reception
+// of its first packet to its output by the depacketizer, and is split into
+// the reception of its packets, up to the last one (the one with the marker
+// bit for video), and its decoding. With rotation, a new file is started
+// once rotateSize bytes were written, so that the size of the files stays
+// bounded; every file is a complete trace, numbered like MP4 files.
+type frameTracer struct {
+	path       string
+	numbers    *fileNumbers
+	numbered   bool
+	tracks     []*track
+	rotateSize int64
+	start      time.Time
+
+	file    *os.File
+	w       *bufio.Writer
+	current string
+	// events and bytes written into the current file :
+	events  int
+	written int64
+}
+
+// newFrameTracer creates the trace file at path, naming the rows of the
+// given tracks. The files are numbered with numbers, from the first one
+// when numbered is set, else from the second one of a rotation.
+func newFrameTracer(path string, numbers *fileNumbers, numbered bool, tracks []*track,
+	rotateSize int64,
+) (*frameTracer, error) {
+	ft := &frameTracer{
+		path:       path,
+		numbers:    numbers,
+		numbered:   numbered,
+		tracks:     tracks,
+		rotateSize: rotateSize,
+		start:      time.Now(),
+	}
+	err := ft.createFile()
+	if err != nil {
+		return nil, err
+	}
+	return ft, nil
+}
+
+// createFile creates the next file, starting with the names of the
+// process and of the rows.
+func (ft *frameTracer) createFile() error {
+	ft.current = ft.path
+	numbered := ft.numbers.path(ft.path)
+	if ft.numbered || ft.file != nil {
+		ft.current = numbered
+	}
+
+	f, err := os.Create(ft.current)
+	if err != nil {
+		return err
+	}
+	ft.file = f
+	ft.w = bufio.NewWriter(f)
+	ft.events = 0
+	ft.written = 0
+
+	// The trace is a JSON array of events :
+	err = ft.writeString("[\n")
+	if err != nil {
+		return err
+	}
+
+	err = ft.writeEvent(traceEvent{
+		Name:  "process_name",
+		Phase: "M",
+		Args:  map[string]any{"name": "rtsp frames"},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, t := range ft.tracks {
+		if t.depacketizer == nil {
+			continue
+		}
+		err = ft.writeEvent(traceEvent{
+			Name:  "thread_name",
+			Phase: "M",
+			TID:   t.index,
+			Args:  map[string]any{"name": t.String()},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeFile terminates the array and closes the current file.
+func (ft *frameTracer) closeFile() error {
+	err := ft.writeString("\n]\n")
+	if err == nil {
+		err = ft.w.Flush()
+	}
+	cerr := ft.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// micros returns the offset of a time from the start of the trace.
+func (ft *frameTracer) micros(at time.Time) float64 {
+	return float64(at.Sub(ft.start).Nanoseconds()) / 1e3
+}
+
+// writeString appends s to the current file.
+func (ft *frameTracer) writeString(s string) error {
+	n, err := ft.w.WriteString(s)
+	ft.written += int64(n)
+	return err
+}
+
+// writeEvent appends an event to the array.
+func (ft *frameTracer) writeEvent(e traceEvent) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// Every event but the first one follows a separator :
+	if ft.events != 0 {
+		err = ft.writeString(",\n")
+		if err != nil {
+			return err
+		}
+	}
+	err = ft.writeString(string(buf))
+	if err != nil {
+		return err
+	}
+	ft.events++
+	return nil
+}
+
+// write appends the timeline of an access unit, after starting a new file
+// when the current one reached rotateSize.
+func (ft *frameTracer) write(au *accessUnit) error {
+	if ft.rotateSize > 0 && ft.written >= ft.rotateSize {
+		err := ft.closeFile()
+		if err == nil {
+			err = ft.createFile()
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Frame trace continues into %s", ft.current)
+	}
+
+	t := au.track
+	first := ft.micros(au.firstReceived)
+	last := ft.micros(au.received)
+	emitted := ft.micros(au.emitted)
+
+	events := []traceEvent{
+		{
+			Name:     "frame",
+			Category: t.media.Formats[0].Codec(),
+			Phase:    "X",
+			TS:       first,
+			Duration: emitted - first,
+			TID:      t.index,
+			Args: map[string]any{
+				"pts":      au.pts,
+				"packets":  au.packets,
+				"keyframe": au.keyframe,
+			},
+		},
+		{
+			Name:     "packets",
+			Phase:    "X",
+			TS:       first,
+			Duration: last - first,
+			TID:      t.index,
+		},
+		{
+			Name:     "decode",
+			Phase:    "X",
+			TS:       last,
+			Duration: emitted - last,
+			TID:      t.index,
+		},
+	}
+	for _, e := range events {
+		err := ft.writeEvent(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close terminates the array and closes the current file.
+func (ft *frameTracer) close() error {
+	return ft.closeFile()
+}
+
+// openFrameTrace traces the reassembly of the frames of the supported
+// tracks into the -trace-frames file.
+func (o *outputs) openFrameTrace(tracks []*track) error {
+	setupDepacketizers(tracks, nil)
+	frameTrace, err := newFrameTracer(o.cfg.traceFrames, &o.cfg.files, o.cfg.sessions(), tracks,
+		o.cfg.traceRotateSize)
+	if err != nil {
+		return fmt.Errorf("creating frame trace file: %w", err)
+	}
+	o.onClose(func() {
+		err := frameTrace.close()
+		if err != nil {
+			log.Printf("Error closing frame trace file: %v", err)
+		}
+	})
+	queue := o.newQueue("frame trace")
+
+	o.onAccessUnit(func(au *accessUnit) {
+		queue.push(func() error {
+			return frameTrace.write(au)
+		})
+	})
+	return nil
+}
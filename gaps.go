@@ -0,0 +1,46 @@
+package main
+
+// number of missing sequence numbers remembered per track.
+const gapWindow = 1024
+
+// gapSet remembers the sequence numbers detected as missing on a track, so
+// that the late and retransmitted packets filling them are recognized.
+// Once the window is full, the oldest sequence numbers are forgotten.
+type gapSet struct {
+	missing map[uint16]struct{}
+	window  []uint16
+	next    int
+	filled  bool
+}
+
+// newGapSet allocates a set remembering the last size missing packets.
+func newGapSet(size int) *gapSet {
+	return &gapSet{
+		missing: make(map[uint16]struct{}, size),
+		window:  make([]uint16, size),
+	}
+}
+
+// add remembers a missing sequence number.
+func (g *gapSet) add(seq uint16) {
+	if g.filled {
+		delete(g.missing, g.window[g.next])
+	}
+	g.missing[seq] = struct{}{}
+	g.window[g.next] = seq
+
+	g.next++
+	if g.next == len(g.window) {
+		g.next = 0
+		g.filled = true
+	}
+}
+
+// fill returns whether the sequence number was missing, and forgets it.
+func (g *gapSet) fill(seq uint16) bool {
+	if _, ok := g.missing[seq]; !ok {
+		return false
+	}
+	delete(g.missing, seq)
+	return true
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/bits"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+)
+
+// Frame types, as they appear in GOP patterns :
+const (
+	frameTypeI = 'I'
+	frameTypeP = 'P'
+	frameTypeB = 'B'
+)
+
+// maximum length of the GOP pattern kept for the report.
+const gopPatternMaxLength = 120
+
+// gopSupported returns whether the GOP structure of a format can be analyzed.
+func gopSupported(forma format.Format) bool {
+	switch forma.(type) {
+	case *format.H264, *format.H265:
+		return true
+	}
+	return false
+}
+
+// gopAnalyzer classifies the access units of a video track into I, P and B
+// frames, from their slice headers, and measures the length of its GOPs
+// (groups of pictures, each starting with a keyframe).
+type gopAnalyzer struct {
+	track *track
+
+	mutex sync.Mutex
+	// H265 picture parameter set, needed to parse slice headers.
+	pps *h265.PPS
+
+	frames  map[byte]uint64
+	gops    uint64
+	length  uint64
+	lengths uint64
+	// frame types of the first complete GOP, in decoding order.
+	pattern    []byte
+	patternSet bool
+	warned     bool
+}
+
+// newGOPAnalyzer creates an analyzer for a H264 or H265 track.
+func newGOPAnalyzer(t *track) *gopAnalyzer {
+	a := &gopAnalyzer{
+		track:  t,
+		frames: make(map[byte]uint64),
+	}
+	if forma, ok := t.media.Formats[0].(*format.H265); ok {
+		_, _, pps := forma.SafeParams()
+		a.updatePPS(pps)
+	}
+	return a
+}
+
+// updatePPS stores a H265 picture parameter set.
+func (a *gopAnalyzer) updatePPS(nalu []byte) {
+	if nalu == nil {
+		return
+	}
+	var pps h265.PPS
+	if pps.Unmarshal(nalu) == nil {
+		a.pps = &pps
+	}
+}
+
+// push classifies an access unit.
+func (a *gopAnalyzer) push(au *accessUnit) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var typ byte
+	var ok bool
+	switch a.track.media.Formats[0].(type) {
+	case *format.H264:
+		typ, ok = h264FrameType(au.units)
+	case *format.H265:
+		for _, nalu := range au.units {
+			if len(nalu) != 0 && h265.NALUType((nalu[0]>>1)&0x3F) == h265.NALUType_PPS_NUT {
+				a.updatePPS(nalu)
+			}
+		}
+		typ, ok = h265FrameType(au.units, a.pps)
+	}
+	if !ok {
+		return
+	}
+	if au.keyframe {
+		typ = frameTypeI
+	}
+
+	if au.keyframe {
+		if a.length != 0 && a.gops != 0 {
+			a.lengths += a.length
+			a.patternSet = true
+		}
+		a.gops++
+		a.length = 0
+	}
+	if a.gops == 0 {
+		// wait for the first keyframe.
+		return
+	}
+
+	a.frames[typ]++
+	a.length++
+	if !a.patternSet && len(a.pattern) < gopPatternMaxLength {
+		a.pattern = append(a.pattern, typ)
+	}
+
+	if typ == frameTypeB && !a.warned {
+		a.warned = true
+		log.Printf("WARNING: track %s carries B-frames, which delay decoding "+
+			"and are unsuitable for low-latency use", a.track)
+	}
+}
+
+// gopReport describes the GOP structure of a video track.
+type gopReport struct {
+	IFrames uint64 `json:"i_frames"`
+	PFrames uint64 `json:"p_frames"`
+	BFrames uint64 `json:"b_frames"`
+	GOPs    uint64 `json:"gops"`
+	// average length of the complete GOPs, in frames.
+	AverageLength float64 `json:"average_length"`
+	// frame types of the first complete GOP, in decoding order.
+	Pattern string `json:"pattern"`
+}
+
+// report returns the GOP structure observed so far.
+func (a *gopAnalyzer) report() *gopReport {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	r := &gopReport{
+		IFrames: a.frames[frameTypeI],
+		PFrames: a.frames[frameTypeP],
+		BFrames: a.frames[frameTypeB],
+		GOPs:    a.gops,
+		Pattern: string(a.pattern),
+	}
+	// the last GOP is still in progress :
+	if a.gops > 1 {
+		r.AverageLength = float64(a.lengths) / float64(a.gops-1)
+	}
+	return r
+}
+
+// h264FrameType returns the type of a H264 frame, from the slice type of
+// its first slice.
+// Specification: ITU-T Rec. H.264, 7.3.3
+func h264FrameType(units [][]byte) (byte, bool) {
+	for _, nalu := range units {
+		if len(nalu) < 2 {
+			continue
+		}
+		switch h264.NALUType(nalu[0] & 0x1F) {
+		case h264.NALUTypeIDR:
+			return frameTypeI, true
+
+		case h264.NALUTypeNonIDR:
+			buf := h264.EmulationPreventionRemove(nalu[1:min(len(nalu), 16)])
+			pos := 0
+			// first_mb_in_slice
+			_, err := bits.ReadGolombUnsigned(buf, &pos)
+			if err != nil {
+				return 0, false
+			}
+			sliceType, err := bits.ReadGolombUnsigned(buf, &pos)
+			if err != nil {
+				return 0, false
+			}
+			switch sliceType % 5 {
+			case 0, 3: // P, SP
+				return frameTypeP, true
+			case 1:
+				return frameTypeB, true
+			default: // I, SI
+				return frameTypeI, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// h265FrameType returns the type of a H265 frame, from the slice type of
+// its first slice segment. The picture parameter set is needed to parse
+// non-IRAP slice headers.
+// Specification: ITU-T Rec. H.265, 7.3.6.1
+func h265FrameType(units [][]byte, pps *h265.PPS) (byte, bool) {
+	for _, nalu := range units {
+		if len(nalu) < 3 {
+			continue
+		}
+		typ := h265.NALUType((nalu[0] >> 1) & 0x3F)
+		switch {
+		case typ >= h265.NALUType_BLA_W_LP && typ <= h265.NALUType_CRA_NUT:
+			return frameTypeI, true
+
+		case typ <= h265.NALUType_RASL_R:
+			if pps == nil {
+				return 0, false
+			}
+			buf := h264.EmulationPreventionRemove(nalu[2:min(len(nalu), 16)])
+			pos := 0
+			firstSliceSegment, err := bits.ReadFlag(buf, &pos)
+			if err != nil || !firstSliceSegment {
+				continue
+			}
+			// slice_pic_parameter_set_id
+			_, err = bits.ReadGolombUnsigned(buf, &pos)
+			if err != nil {
+				return 0, false
+			}
+			// slice_reserved_flag
+			pos += int(pps.NumExtraSliceHeaderBits)
+			sliceType, err := bits.ReadGolombUnsigned(buf, &pos)
+			if err != nil {
+				return 0, false
+			}
+			switch sliceType {
+			case 0:
+				return frameTypeB, true
+			case 1:
+				return frameTypeP, true
+			default:
+				return frameTypeI, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// setupGOPAnalyzers analyzes the GOP structure of the video tracks, with
+// -gop-report.
+func setupGOPAnalyzers(tracks []*track) {
+	for _, t := range tracks {
+		if !t.setup || !gopSupported(t.media.Formats[0]) {
+			continue
+		}
+		t.gop = newGOPAnalyzer(t)
+	}
+	setupDepacketizers(tracks, func(t *track) bool {
+		return t.gop != nil
+	})
+}
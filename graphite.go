@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maximum time to connect to the Graphite server, and to send the metrics
+// of a push.
+const graphiteTimeout = 5 * time.Second
+
+// graphitePusher periodically pushes the counters and gauges of the SETUP
+// tracks to a Graphite server (Carbon), in the plaintext protocol over TCP,
+// under prefix.<stream>.<track>.<metric>, the stream being the host and
+// path of the URL. Counters are sent as totals since the start of the
+// capture, as Graphite expects. The connection is opened again after a
+// failure, at the next push, so that the capture goes on when the server
+// is unreachable.
+type graphitePusher struct {
+	tracks       []*track
+	addr         string
+	prefix       string
+	interval     time.Duration
+	stallTimeout time.Duration
+
+	startedAt time.Time
+	conn      net.Conn
+	failing   bool
+}
+
+// newGraphitePusher creates a pusher for the SETUP tracks. It connects at
+// the first push.
+func newGraphitePusher(cfg *config, tracks []*track) *graphitePusher {
+	p := &graphitePusher{
+		addr:         cfg.graphiteAddr,
+		interval:     cfg.graphiteInterval,
+		stallTimeout: cfg.stallTimeout,
+	}
+
+	// The credentials of the URL are left out :
+	p.prefix = cfg.graphitePrefix + "." + graphiteNode(streamName(cfg.url))
+
+	for _, t := range tracks {
+		if t.setup {
+			p.tracks = append(p.tracks, t)
+		}
+	}
+	return p
+}
+
+// run pushes the metrics every interval, until ctx is done, and closes
+// the connection.
+func (p *graphitePusher) run(ctx context.Context) {
+	defer func() {
+		if p.conn != nil {
+			p.conn.Close()
+		}
+	}()
+
+	p.startedAt = time.Now()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.push(now)
+		}
+	}
+}
+
+// push sends the metrics of every track, stamped with now.
+func (p *graphitePusher) push(now time.Time) {
+	var buf strings.Builder
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	for _, t := range p.tracks {
+		s := t.stats(p.startedAt, p.stallTimeout)
+
+		node := "track" + strconv.Itoa(t.index)
+		if t.name != "" {
+			node = graphiteNode(t.name)
+		}
+		metric := func(name string, v float64) {
+			fmt.Fprintf(&buf, "%s.%s.%s %s %s\n", p.prefix, node, name,
+				strconv.FormatFloat(v, 'f', -1, 64), timestamp)
+		}
+
+		metric("packets", float64(s.Packets))
+		metric("bytes", float64(s.Bytes))
+		metric("duplicates", float64(s.Duplicates))
+		metric("lost", float64(s.Lost))
+		metric("recovered", float64(s.Recovered))
+		metric("jitter_ms", s.JitterMS)
+		stalled := 0.0
+		if s.State == trackStateStalled {
+			stalled = 1
+		}
+		metric("stalled", stalled)
+	}
+
+	err := p.send(buf.String())
+	switch {
+	case err != nil && !p.failing:
+		p.failing = true
+		log.Printf("WARNING: can't push metrics to Graphite, will keep trying: %v", err)
+	case err == nil && p.failing:
+		p.failing = false
+		log.Println("Pushing metrics to Graphite again")
+	}
+}
+
+// send writes the lines, connecting first when needed. The connection is
+// dropped after an error.
+func (p *graphitePusher) send(lines string) error {
+	if p.conn == nil {
+		conn, err := net.DialTimeout("tcp", p.addr, graphiteTimeout)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+
+	p.conn.SetWriteDeadline(time.Now().Add(graphiteTimeout))
+	_, err := p.conn.Write([]byte(lines))
+	if err != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+	return err
+}
+
+// graphiteNode turns a value into a single node of a metric path, replacing
+// the dots, which separate nodes, and the characters that Graphite doesn't
+// accept.
+func graphiteNode(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(v, "/"))
+}
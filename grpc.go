@@ -0,0 +1,309 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=module=rtspMeta --go-grpc_out=. --go-grpc_opt=module=rtspMeta accessunit.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"rtspMeta/pb"
+)
+
+// time given to the clients to receive the units queued for them when the
+// session ends, before their streams are reset.
+const grpcShutdownTimeout = 2 * time.Second
+
+// Idle time after which the server pings a client, and time it waits for the
+// answer before closing the connection, so that the streams of the clients
+// which went away end :
+const (
+	grpcKeepaliveTime    = 30 * time.Second
+	grpcKeepaliveTimeout = 10 * time.Second
+)
+
+// grpcServer serves the access units of the tracks with -grpc-addr, over
+// gRPC without TLS, as the server-streaming Stream method of
+// accessunit.proto.
+//
+// Units are converted once, and queued for every client. HTTP/2 flow control
+// blocks the sends to a client which doesn't read, and its queue fills up:
+// the units are then dropped for that client only, and counted in the next
+// unit it receives, rather than slowing down the capture; or the capture
+// waits for the client with the block policy, until its call ends.
+type grpcServer struct {
+	pb.UnimplementedAccessUnitsServer
+
+	server   *grpc.Server
+	addr     net.Addr
+	tracks   []*track
+	queueLen int
+	block    bool
+
+	// closed when the session ends, to end the streams.
+	done chan struct{}
+
+	mutex   sync.Mutex
+	clients map[*grpcClient]struct{}
+}
+
+// grpcClient is a call of the Stream method.
+type grpcClient struct {
+	addr string
+	ctx  context.Context
+	// tracks streamed to the client by index, every track when nil.
+	tracks map[int]bool
+	queue  chan *pb.AccessUnit
+
+	// units dropped since the last one sent, and in total.
+	mutex        sync.Mutex
+	dropped      uint64
+	totalDropped uint64
+}
+
+// newGRPCServer listens on addr and serves the units of tracks, queuing up
+// to queueLen units per client, and dropping or waiting beyond depending on
+// the overflow policy.
+func newGRPCServer(addr string, tracks []*track, queueLen int, overflow string) (*grpcServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &grpcServer{
+		server: grpc.NewServer(grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    grpcKeepaliveTime,
+			Timeout: grpcKeepaliveTimeout,
+		})),
+		addr:     ln.Addr(),
+		tracks:   tracks,
+		queueLen: queueLen,
+		block:    overflow == writeOverflowBlock,
+		done:     make(chan struct{}),
+		clients:  make(map[*grpcClient]struct{}),
+	}
+	pb.RegisterAccessUnitsServer(s.server, s)
+
+	go func() {
+		err := s.server.Serve(ln)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Printf("Error serving gRPC: %v", err)
+		}
+	}()
+	log.Printf("Serving the access units over gRPC on %s (%s)", ln.Addr(), pb.AccessUnits_Stream_FullMethodName)
+	return s, nil
+}
+
+// Stream implements pb.AccessUnitsServer.
+func (s *grpcServer) Stream(req *pb.StreamRequest, stream grpc.ServerStreamingServer[pb.AccessUnit]) error {
+	tracks, err := s.selectTracks(req.GetTracks())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	c := &grpcClient{
+		addr:   "unknown",
+		ctx:    stream.Context(),
+		tracks: tracks,
+		queue:  make(chan *pb.AccessUnit, s.queueLen),
+	}
+	if p, ok := peer.FromContext(c.ctx); ok {
+		c.addr = p.Addr.String()
+	}
+
+	s.mutex.Lock()
+	select {
+	case <-s.done:
+		s.mutex.Unlock()
+		return nil
+	default:
+	}
+	s.clients[c] = struct{}{}
+	s.mutex.Unlock()
+	log.Printf("gRPC client %s connected", c.addr)
+
+	sent, err := c.stream(stream, s.done)
+
+	s.mutex.Lock()
+	delete(s.clients, c)
+	s.mutex.Unlock()
+	c.mutex.Lock()
+	dropped := c.totalDropped
+	c.mutex.Unlock()
+	if err != nil {
+		log.Printf("gRPC client %s disconnected: %v (%d units sent, %d dropped)", c.addr, err, sent, dropped)
+		return err
+	}
+	log.Printf("gRPC client %s done (%d units sent, %d dropped)", c.addr, sent, dropped)
+	return nil
+}
+
+// selectTracks returns the tracks selected by the indexes of a request.
+func (s *grpcServer) selectTracks(indexes []uint32) (map[int]bool, error) {
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	tracks := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		if uint64(i) >= uint64(len(s.tracks)) || !s.tracks[i].setup {
+			return nil, fmt.Errorf("there is no track #%d in the session", i)
+		}
+		tracks[int(i)] = true
+	}
+	return tracks, nil
+}
+
+// stream sends the units queued for the client until the session ends,
+// then those still queued. It returns the number of units sent, and an
+// error when the call ended before, because the client went away, canceled
+// it or its deadline expired.
+func (c *grpcClient) stream(stream grpc.ServerStreamingServer[pb.AccessUnit], done <-chan struct{}) (int, error) {
+	sent := 0
+	send := func(msg *pb.AccessUnit) error {
+		// The units dropped for the client since the previous one are
+		// counted in the dropped field of a copy of the shared message :
+		c.mutex.Lock()
+		dropped := c.dropped
+		c.dropped = 0
+		c.mutex.Unlock()
+		if dropped != 0 {
+			msg = proto.Clone(msg).(*pb.AccessUnit)
+			msg.Dropped = dropped
+		}
+
+		// Sends block here while the flow control window of the client is
+		// exhausted :
+		err := stream.Send(msg)
+		if err != nil {
+			return err
+		}
+		sent++
+		return nil
+	}
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return sent, status.FromContextError(c.ctx.Err()).Err()
+
+		case msg := <-c.queue:
+			err := send(msg)
+			if err != nil {
+				return sent, err
+			}
+
+		case <-done:
+			for {
+				select {
+				case msg := <-c.queue:
+					err := send(msg)
+					if err != nil {
+						return sent, err
+					}
+				default:
+					return sent, nil
+				}
+			}
+		}
+	}
+}
+
+// write queues an access unit for the clients streaming its track. Units
+// are dropped for the clients whose queue is full, or write waits for them
+// with the block policy.
+func (s *grpcServer) write(au *accessUnit) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var msg *pb.AccessUnit
+	for c := range s.clients {
+		if c.tracks != nil && !c.tracks[au.track.index] {
+			continue
+		}
+		if msg == nil {
+			msg = accessUnitProto(au)
+		}
+		if s.block {
+			select {
+			case c.queue <- msg:
+			case <-c.ctx.Done():
+			}
+			continue
+		}
+		select {
+		case c.queue <- msg:
+		default:
+			c.mutex.Lock()
+			c.dropped++
+			c.totalDropped++
+			if c.totalDropped == 1 {
+				log.Printf("WARNING: gRPC client %s doesn't keep up, dropping units", c.addr)
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// accessUnitProto converts an access unit to an AccessUnit message of
+// accessunit.proto, without the dropped field.
+func accessUnitProto(au *accessUnit) *pb.AccessUnit {
+	t := au.track
+	msg := &pb.AccessUnit{
+		Track:            uint32(t.index),
+		TrackName:        t.name,
+		MediaType:        string(t.media.Type),
+		Codec:            t.codec(),
+		ClockRate:        uint32(t.media.Formats[0].ClockRate()),
+		Pts:              au.pts,
+		Keyframe:         au.keyframe,
+		ReceivedUnixNano: au.received.UnixNano(),
+		Units:            au.units,
+	}
+	if !au.ntp.IsZero() {
+		msg.NtpTimeUnixNano = proto.Int64(au.ntp.UnixNano())
+	}
+	return msg
+}
+
+// close ends the streams of the clients, once they received the units
+// queued for them, and stops the server.
+func (s *grpcServer) close() {
+	s.mutex.Lock()
+	close(s.done)
+	s.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(grpcShutdownTimeout):
+		log.Printf("WARNING: gRPC clients didn't receive all their units in %v", grpcShutdownTimeout)
+		s.server.Stop()
+	}
+}
+
+// openGRPC serves the access units of the supported tracks over gRPC, on
+// -grpc-addr.
+func (o *outputs) openGRPC(tracks []*track) error {
+	setupDepacketizers(tracks, nil)
+	server, err := newGRPCServer(o.cfg.grpcAddr, tracks, o.cfg.sinkBuffer, o.cfg.writeOverflow)
+	if err != nil {
+		return fmt.Errorf("starting gRPC server: %w", err)
+	}
+	o.onClose(server.close)
+	o.onAccessUnit(server.write)
+	return nil
+}
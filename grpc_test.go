@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"rtspMeta/pb"
+)
+
+// startTestGRPC serves the tracks of muxedSDP, and returns a client of the
+// server.
+func startTestGRPC(t *testing.T) (*grpcServer, pb.AccessUnitsClient) {
+	tracks := parseTestSDP(t, muxedSDP)
+	tracks[0].setup = true
+	tracks[0].name = "front"
+
+	s, err := newGRPCServer("127.0.0.1:0", tracks, 16, writeOverflowDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.NewClient(s.addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, pb.NewAccessUnitsClient(conn)
+}
+
+// waitGRPCClients waits until n clients are streaming.
+func waitGRPCClients(t *testing.T, s *grpcServer, n int) {
+	for range 100 {
+		s.mutex.Lock()
+		count := len(s.clients)
+		s.mutex.Unlock()
+		if count == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%d clients never connected", n)
+}
+
+func TestGRPCStream(t *testing.T) {
+	s, client := startTestGRPC(t)
+
+	stream, err := client.Stream(context.Background(), &pb.StreamRequest{Tracks: []uint32{0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitGRPCClients(t, s, 1)
+
+	ntp := time.Unix(1700000000, 0)
+	received := time.Unix(1700000001, 0)
+	s.write(&accessUnit{
+		track:    s.tracks[0],
+		pts:      3000,
+		ntp:      ntp,
+		received: received,
+		keyframe: true,
+		units:    [][]byte{{0x65, 1}, {0x65, 2}},
+	})
+	// Units of the other tracks are not streamed :
+	s.write(&accessUnit{track: s.tracks[1], pts: 160, received: received})
+	s.close()
+
+	au, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if au.Track != 0 || au.TrackName != "front" || au.MediaType != "video" || au.Codec != "H264" ||
+		au.ClockRate != 90000 || au.Pts != 3000 || !au.Keyframe || au.GetNtpTimeUnixNano() != ntp.UnixNano() ||
+		au.ReceivedUnixNano != received.UnixNano() || len(au.Units) != 2 || au.Dropped != 0 {
+		t.Errorf("unexpected unit %v", au)
+	}
+
+	// The stream ends with status OK at the end of the session :
+	_, err = stream.Recv()
+	if err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestGRPCStreamInvalidTracks(t *testing.T) {
+	s, client := startTestGRPC(t)
+	defer s.close()
+
+	for _, tracks := range [][]uint32{
+		// not setup :
+		{1},
+		{2},
+		// would be negative as an int32 :
+		{0xFFFFFFFF},
+	} {
+		stream, err := client.Stream(context.Background(), &pb.StreamRequest{Tracks: tracks})
+		if err == nil {
+			_, err = stream.Recv()
+		}
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("tracks %v: got %v, want InvalidArgument", tracks, err)
+		}
+	}
+}
+
+func TestGRPCStreamDeadline(t *testing.T) {
+	s, client := startTestGRPC(t)
+	defer s.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	stream, err := client.Stream(ctx, &pb.StreamRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("got %v, want DeadlineExceeded", err)
+	}
+
+	// The call ended on the server too :
+	waitGRPCClients(t, s, 0)
+}
+
+func TestGRPCStreamDropped(t *testing.T) {
+	s, client := startTestGRPC(t)
+
+	stream, err := client.Stream(context.Background(), &pb.StreamRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitGRPCClients(t, s, 1)
+
+	// The client doesn't read while units far larger than its flow control
+	// window come in, which fills its queue :
+	const count = 200
+	for range count {
+		s.write(&accessUnit{track: s.tracks[0], units: [][]byte{make([]byte, 64*1024)}})
+	}
+	go s.close()
+
+	// Every unit is either received or counted as dropped :
+	received, dropped := 0, uint64(0)
+	for {
+		au, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		received++
+		dropped += au.Dropped
+	}
+	if dropped == 0 {
+		t.Errorf("no unit was dropped")
+	}
+	if uint64(received)+dropped > count || uint64(received)+dropped < count-uint64(s.queueLen) {
+		t.Errorf("got %d units and %d dropped, want %d in total", received, dropped, count)
+	}
+}
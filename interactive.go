@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"strings"
+)
+
+// readCommands reads the commands of -interactive from the standard input,
+// one per line, until it is closed:
+//   - mute stops printing packets, while the session goes on and the stats
+//     keep being updated; it is not an RTSP PAUSE
+//   - unmute prints them again
+func readCommands(cfg *config) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch command := strings.ToLower(strings.TrimSpace(scanner.Text())); command {
+		case "":
+		case "mute":
+			if !cfg.muted.Swap(true) {
+				log.Println("Packet output muted, reception and stats go on (unmute to resume)")
+			}
+		case "unmute":
+			if cfg.muted.Swap(false) {
+				log.Println("Packet output unmuted")
+			}
+		case "help":
+			log.Println("Commands: mute (stop printing packets), unmute (print them again)")
+		default:
+			log.Printf("Unknown command %q, expected mute or unmute", command)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/headers"
+)
+
+// session timeout of servers which don't give one (RFC 2326, section 12.37).
+const defaultSessionTimeout = 60 * time.Second
+
+// keepaliveFilter suppresses the keepalives of the client, with
+// -disable-keepalive, for servers which close the session when they receive
+// one. The client sends an OPTIONS or GET_PARAMETER request periodically
+// while playing, without waiting for its response, and can't be told not to:
+// the request is dropped instead when it is written to the connection.
+// The session then relies on the media flow and RTCP receiver reports.
+//
+// Once PLAY is answered, the client only sends these methods from its
+// keepalive timer: its Options method is refused while playing. The filter
+// still matches the CSeq of the request, so that no other write is dropped.
+type keepaliveFilter struct {
+	mutex   sync.Mutex
+	playing bool
+	// CSeq of the keepalive to drop, empty when there is none.
+	dropCSeq string
+	warned   bool
+	dropped  uint64
+}
+
+// disableKeepalive chains the request and response callbacks of the client
+// with the filter, and returns dial wrapped to filter the connections.
+func disableKeepalive(client *gortsplib.Client,
+	dial func(ctx context.Context, network, address string) (net.Conn, error),
+) func(ctx context.Context, network, address string) (net.Conn, error) {
+	f := &keepaliveFilter{}
+	log.Printf("Keepalives are disabled (-disable-keepalive)")
+
+	var method base.Method
+	onRequest := client.OnRequest
+	client.OnRequest = func(req *base.Request) {
+		if onRequest != nil {
+			onRequest(req)
+		}
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		method = req.Method
+		switch req.Method {
+		case base.Pause, base.Teardown:
+			f.playing = false
+		case base.Options, base.GetParameter:
+			if f.playing && len(req.Header["CSeq"]) == 1 {
+				f.dropCSeq = req.Header["CSeq"][0]
+			}
+		}
+	}
+
+	onResponse := client.OnResponse
+	client.OnResponse = func(res *base.Response) {
+		if onResponse != nil {
+			onResponse(res)
+		}
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if method == base.Play && res.StatusCode == base.StatusOK {
+			f.playing = true
+		}
+		if method != base.Setup || f.warned || res.StatusCode != base.StatusOK {
+			return
+		}
+		f.warned = true
+		timeout := defaultSessionTimeout
+		var session headers.Session
+		if session.Unmarshal(res.Header["Session"]) == nil && session.Timeout != nil {
+			timeout = time.Duration(*session.Timeout) * time.Second
+		}
+		log.Printf("WARNING: without keepalives, the server may end the session after its timeout of %v "+
+			"if it doesn't count the media flow and RTCP receiver reports as activity", timeout)
+	}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		return &keepaliveConn{Conn: conn, filter: f}, nil
+	}
+}
+
+// keepaliveConn is a connection dropping the keepalive requests.
+type keepaliveConn struct {
+	net.Conn
+	filter *keepaliveFilter
+}
+
+// Write implements net.Conn. gortsplib marshals every request before
+// writing it in a single call, so that the keepalive is a whole write,
+// recognized by its method and CSeq.
+func (c *keepaliveConn) Write(b []byte) (int, error) {
+	f := c.filter
+	f.mutex.Lock()
+	drop := f.dropCSeq != "" &&
+		(bytes.HasPrefix(b, []byte(base.Options+" ")) || bytes.HasPrefix(b, []byte(base.GetParameter+" "))) &&
+		bytes.Contains(b, []byte("\r\nCSeq: "+f.dropCSeq+"\r\n"))
+	if drop {
+		f.dropCSeq = ""
+		f.dropped++
+		if f.dropped == 1 {
+			log.Printf("Suppressed the keepalive of the client (-disable-keepalive), and the following ones")
+		}
+	}
+	f.mutex.Unlock()
+
+	if drop {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
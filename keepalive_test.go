@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// writeRecorder is a connection recording its writes.
+type writeRecorder struct {
+	net.Conn
+	writes []string
+}
+
+func (c *writeRecorder) Write(b []byte) (int, error) {
+	c.writes = append(c.writes, string(b))
+	return len(b), nil
+}
+
+// keepaliveTest is a client with -disable-keepalive, whose requests are
+// written to a writeRecorder.
+type keepaliveTest struct {
+	t      *testing.T
+	client *gortsplib.Client
+	conn   net.Conn
+	rec    *writeRecorder
+}
+
+func newKeepaliveTest(t *testing.T) *keepaliveTest {
+	kt := &keepaliveTest{t: t, client: &gortsplib.Client{}, rec: &writeRecorder{}}
+	dial := disableKeepalive(kt.client, func(context.Context, string, string) (net.Conn, error) {
+		return kt.rec, nil
+	})
+	conn, err := dial(context.Background(), "tcp", "127.0.0.1:554")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kt.conn = conn
+	return kt
+}
+
+// request sends a request the way the client does, and returns whether it
+// reached the connection.
+func (kt *keepaliveTest) request(method base.Method, cseq string) bool {
+	req := &base.Request{
+		Method: method,
+		URL:    mustParseURL(kt.t, "rtsp://127.0.0.1:554/stream"),
+		Header: base.Header{"CSeq": base.HeaderValue{cseq}},
+	}
+	kt.client.OnRequest(req)
+	buf, err := req.Marshal()
+	if err != nil {
+		kt.t.Fatal(err)
+	}
+
+	before := len(kt.rec.writes)
+	n, err := kt.conn.Write(buf)
+	if err != nil || n != len(buf) {
+		kt.t.Fatalf("Write returned %d, %v", n, err)
+	}
+	return len(kt.rec.writes) != before
+}
+
+// respond answers the last request.
+func (kt *keepaliveTest) respond(code base.StatusCode) {
+	kt.client.OnResponse(&base.Response{StatusCode: code, Header: base.Header{}})
+}
+
+func mustParseURL(t *testing.T, raw string) *base.URL {
+	u, err := base.ParseURL(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestKeepaliveFilter(t *testing.T) {
+	kt := newKeepaliveTest(t)
+
+	// Before PLAY, OPTIONS and GET_PARAMETER are requests of the user :
+	if !kt.request(base.Options, "1") {
+		t.Error("OPTIONS before PLAY was dropped")
+	}
+	kt.respond(base.StatusOK)
+	if !kt.request(base.Play, "2") {
+		t.Error("PLAY was dropped")
+	}
+	kt.respond(base.StatusOK)
+
+	// While playing, the keepalives are dropped, whatever their method :
+	if kt.request(base.Options, "3") {
+		t.Error("OPTIONS keepalive was written")
+	}
+	if kt.request(base.GetParameter, "4") {
+		t.Error("GET_PARAMETER keepalive was written")
+	}
+
+	// Other writes go through, including interleaved RTCP :
+	rtcp := []byte{'$', 1, 0, 4, 0x81, 0xc9, 0, 0}
+	if n, err := kt.conn.Write(rtcp); err != nil || n != len(rtcp) {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if len(kt.rec.writes) != 3 || kt.rec.writes[2] != string(rtcp) {
+		t.Errorf("interleaved data was not written: %q", kt.rec.writes)
+	}
+
+	// After TEARDOWN, nothing is dropped anymore :
+	if !kt.request(base.Teardown, "5") {
+		t.Error("TEARDOWN was dropped")
+	}
+	if !kt.request(base.Options, "6") {
+		t.Error("OPTIONS after TEARDOWN was dropped")
+	}
+}
+
+func TestKeepaliveFilterCSeq(t *testing.T) {
+	kt := newKeepaliveTest(t)
+	kt.request(base.Play, "1")
+	kt.respond(base.StatusOK)
+
+	// Only the write of the request with the recorded CSeq is dropped :
+	kt.client.OnRequest(&base.Request{
+		Method: base.GetParameter,
+		Header: base.Header{"CSeq": base.HeaderValue{"2"}},
+	})
+	other := "GET_PARAMETER rtsp://127.0.0.1:554/stream RTSP/1.0\r\nCSeq: 3\r\n\r\n"
+	if _, err := kt.conn.Write([]byte(other)); err != nil {
+		t.Fatal(err)
+	}
+	if len(kt.rec.writes) != 2 || kt.rec.writes[1] != other {
+		t.Errorf("request with another CSeq was dropped: %q", kt.rec.writes)
+	}
+	if kt.request(base.GetParameter, "2") {
+		t.Error("keepalive was written")
+	}
+}
+
+func TestKeepaliveFilterPlayFailed(t *testing.T) {
+	kt := newKeepaliveTest(t)
+	kt.request(base.Play, "1")
+	kt.respond(base.StatusNotFound)
+
+	if !kt.request(base.Options, "2") {
+		t.Error("OPTIONS after a failed PLAY was dropped")
+	}
+}
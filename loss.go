@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// lossSimulator drops packets of a track at random, with -simulate-loss,
+// before they reach any output, so that consumers can be tested against
+// gaps. The reception and its statistics are not affected. Every track
+// draws from its own generator, seeded with -loss-seed and its index, so
+// that the same packets are dropped from run to run whatever the order in
+// which the tracks receive them.
+// It is not safe for concurrent use: every track owns its own simulator.
+type lossSimulator struct {
+	rand        *rand.Rand
+	probability float64
+	dropped     atomic.Uint64
+}
+
+// newLossSimulator creates the simulator of the track of the given index,
+// dropping percent percent of its packets.
+func newLossSimulator(percent float64, seed uint64, index int) *lossSimulator {
+	return &lossSimulator{
+		rand:        rand.New(rand.NewPCG(seed, uint64(index))),
+		probability: percent / 100,
+	}
+}
+
+// drop returns whether the next packet is to be dropped, and counts it.
+func (s *lossSimulator) drop() bool {
+	if s.rand.Float64() >= s.probability {
+		return false
+	}
+	s.dropped.Add(1)
+	return true
+}
+
+// setupLossSimulators drops packets of every track at random, with
+// -simulate-loss.
+func setupLossSimulators(tracks []*track, percent float64, seed uint64) {
+	for _, t := range tracks {
+		t.loss = newLossSimulator(percent, seed, t.index)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"math"
+	"sync"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+)
+
+// EBU R128 targets, with the tolerance allowed for live programmes :
+const (
+	r128TargetLUFS  = -23
+	r128ToleranceLU = 1
+	r128MaxTruePeak = -1
+)
+
+// gates of the blocks of ITU-R BS.1770-4: absolute in LUFS, and relative to
+// the loudness of the blocks passing the absolute one in LU.
+const (
+	loudnessAbsGate = -70
+	loudnessRelGate = -10
+)
+
+// oversampling factor of the true-peak measure.
+const truePeakOversamp = 4
+
+// loudnessSupported returns whether the audio of a format can be decoded to
+// measure its loudness. AAC and Opus would need decoders that the program
+// doesn't have.
+func loudnessSupported(forma format.Format) bool {
+	switch forma := forma.(type) {
+	case *format.G711:
+		return true
+	case *format.LPCM:
+		return forma.BitDepth == 16 || forma.BitDepth == 24
+	}
+	return false
+}
+
+// biquad is a second-order IIR filter, in transposed direct form II.
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+// filter returns the filtered sample.
+func (f *biquad) filter(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+// kWeighting returns the two stages of the K-weighting filter of ITU-R
+// BS.1770-4 at a sample rate: a high shelf modelling the head, followed by a
+// high-pass filter. The coefficients given by the recommendation for 48kHz
+// are derived again for other rates.
+func kWeighting(sampleRate int) [2]biquad {
+	fs := float64(sampleRate)
+
+	// High shelf :
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	// High-pass :
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highPass := biquad{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return [2]biquad{shelf, highPass}
+}
+
+// truePeakTaps are the coefficients of the interpolation filter oversampling
+// by truePeakOversamp: a Hann-windowed sinc, whose phase 0 passes the
+// samples through.
+var truePeakTaps = func() []float64 {
+	const n = 12*truePeakOversamp + 1
+	taps := make([]float64, n)
+	for i := range taps {
+		x := float64(i-n/2) / truePeakOversamp
+		sinc := 1.0
+		if x != 0 {
+			sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+		}
+		window := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		taps[i] = sinc * window
+	}
+	return taps
+}()
+
+// loudnessChannel is the state of a channel of a loudness meter.
+type loudnessChannel struct {
+	weighting [2]biquad
+	// last samples, the most recent first, for the true-peak interpolation.
+	history []float64
+}
+
+// loudnessMeter measures the integrated loudness and the true peak of an
+// audio track over the whole capture, with -measure-loudness, following
+// ITU-R BS.1770-4 as EBU R128 requires: the K-weighted energy is measured
+// over blocks of 400ms overlapping by 75%, and averaged over the blocks
+// passing an absolute gate at -70 LUFS and a gate 10 LU below their mean.
+// Channels are all weighted alike, as the SDP doesn't tell surround ones.
+// Lost packets are skipped, as if their samples were not part of the
+// programme.
+type loudnessMeter struct {
+	// decodes a payload into interleaved samples, full scale being 1.
+	decode     func(payload []byte) []float64
+	sampleRate int
+
+	mutex    sync.Mutex
+	channels []loudnessChannel
+	// next channel of the interleaved samples.
+	channel int
+	// samples per channel of the 100ms steps between blocks.
+	step int
+	// energy and samples of the current step, and energies of the last
+	// steps, which form a block once there are four of them.
+	stepEnergy  float64
+	stepSamples int
+	steps       []float64
+	// mean square of the channels of every block.
+	blocks []float64
+	// samples measured per channel, and highest interpolated sample.
+	samples  int64
+	truePeak float64
+}
+
+// newLoudnessMeter creates the meter of a format, which must be supported.
+func newLoudnessMeter(forma format.Format) *loudnessMeter {
+	m := &loudnessMeter{}
+	channels := 1
+	switch forma := forma.(type) {
+	case *format.G711:
+		m.sampleRate = forma.SampleRate
+		channels = forma.ChannelCount
+		mulaw := forma.MULaw
+		m.decode = func(payload []byte) []float64 {
+			samples := make([]float64, len(payload))
+			for i, b := range payload {
+				samples[i] = float64(g711ToLinear(b, mulaw)) / 32768
+			}
+			return samples
+		}
+
+	case *format.LPCM:
+		m.sampleRate = forma.SampleRate
+		channels = forma.ChannelCount
+		size := forma.BitDepth / 8
+		m.decode = func(payload []byte) []float64 {
+			samples := make([]float64, 0, len(payload)/size)
+			for i := 0; i+size <= len(payload); i += size {
+				// Samples are big-endian, signed :
+				var v int32
+				for _, b := range payload[i : i+size] {
+					v = v<<8 | int32(b)
+				}
+				v <<= 32 - 8*size
+				samples = append(samples, float64(v)/(1<<31))
+			}
+			return samples
+		}
+	}
+	if m.sampleRate <= 0 {
+		m.sampleRate = 8000
+	}
+	if channels <= 0 {
+		channels = 1
+	}
+
+	m.channels = make([]loudnessChannel, channels)
+	for i := range m.channels {
+		m.channels[i] = loudnessChannel{
+			weighting: kWeighting(m.sampleRate),
+			history:   make([]float64, len(truePeakTaps)/truePeakOversamp+1),
+		}
+	}
+	m.step = m.sampleRate / 10
+	return m
+}
+
+// push measures the samples of a payload.
+func (m *loudnessMeter) push(payload []byte) {
+	samples := m.decode(payload)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, s := range samples {
+		c := &m.channels[m.channel]
+		m.truePeakSample(c, s)
+
+		y := c.weighting[1].filter(c.weighting[0].filter(s))
+		m.stepEnergy += y * y
+
+		m.channel++
+		if m.channel < len(m.channels) {
+			continue
+		}
+		m.channel = 0
+		m.samples++
+
+		m.stepSamples++
+		if m.stepSamples < m.step {
+			continue
+		}
+		m.steps = append(m.steps, m.stepEnergy)
+		m.stepEnergy, m.stepSamples = 0, 0
+		if len(m.steps) < 4 {
+			continue
+		}
+		m.blocks = append(m.blocks, (m.steps[0]+m.steps[1]+m.steps[2]+m.steps[3])/float64(4*m.step))
+		m.steps = m.steps[1:]
+	}
+}
+
+// truePeakSample adds a sample to the history of a channel, and updates the
+// true peak with the samples interpolated up to it.
+func (m *loudnessMeter) truePeakSample(c *loudnessChannel, s float64) {
+	copy(c.history[1:], c.history)
+	c.history[0] = s
+	for phase := 0; phase < truePeakOversamp; phase++ {
+		var y float64
+		for k, x := range c.history {
+			if i := k*truePeakOversamp + phase; i < len(truePeakTaps) {
+				y += x * truePeakTaps[i]
+			}
+		}
+		if y = math.Abs(y); y > m.truePeak {
+			m.truePeak = y
+		}
+	}
+}
+
+// loudnessReport is the loudness of a track in the final report. Loudness
+// is missing when the capture was shorter than a block or silent, and the
+// true peak when all the samples were zero.
+type loudnessReport struct {
+	IntegratedLUFS  *float64 `json:"integrated_lufs,omitempty"`
+	TruePeakDBTP    *float64 `json:"true_peak_dbtp,omitempty"`
+	MeasuredSeconds float64  `json:"measured_seconds"`
+	// whether the loudness is -23 LUFS ±1 LU, and the true peak at most
+	// -1 dBTP.
+	WithinR128 bool `json:"within_r128"`
+}
+
+// report returns the measures of the capture.
+func (m *loudnessMeter) report() *loudnessReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r := &loudnessReport{
+		MeasuredSeconds: math.Round(float64(m.samples)/float64(m.sampleRate)*10) / 10,
+	}
+	if m.truePeak > 0 {
+		peak := math.Round(20*math.Log10(m.truePeak)*10) / 10
+		r.TruePeakDBTP = &peak
+	}
+
+	loudness := func(z float64) float64 {
+		return -0.691 + 10*math.Log10(z)
+	}
+	gated := func(gate float64) (float64, int) {
+		var sum float64
+		var n int
+		for _, z := range m.blocks {
+			if z > 0 && loudness(z) > gate {
+				sum += z
+				n++
+			}
+		}
+		if n == 0 {
+			return 0, 0
+		}
+		return sum / float64(n), n
+	}
+	if mean, n := gated(loudnessAbsGate); n != 0 {
+		if mean, n = gated(max(loudness(mean)+loudnessRelGate, loudnessAbsGate)); n != 0 {
+			lufs := math.Round(loudness(mean)*10) / 10
+			r.IntegratedLUFS = &lufs
+		}
+	}
+
+	r.WithinR128 = r.IntegratedLUFS != nil && math.Abs(*r.IntegratedLUFS-r128TargetLUFS) <= r128ToleranceLU &&
+		r.TruePeakDBTP != nil && *r.TruePeakDBTP <= r128MaxTruePeak
+	return r
+}
+
+// setupLoudnessMeters measures the loudness of the audio tracks which can
+// be decoded, with -measure-loudness.
+func setupLoudnessMeters(tracks []*track) {
+	for _, t := range tracks {
+		if t.setup && loudnessSupported(t.media.Formats[0]) {
+			t.loudness = newLoudnessMeter(t.media.Formats[0])
+		}
+	}
+}
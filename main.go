@@ -1,57 +1,271 @@
 // This program connects to an RTSP source using the gortsplib library,
 // prints the SDP (in JSON format) and metadata about the media tracks,
 // and listens for RTP packets. Each received RTP packet is printed in JSON.
+// Received RTCP packets are printed too, unless -no-rtcp is given.
+// On exit, a final report with the status of every track is printed.
+// With -no-play, the program stops after SETUP and only reports the
+// negotiated transport of every track; the exit code tells whether all
+// selected tracks could be SETUP. With -mp4-out, the H264, H265 and AAC
+// tracks are also recorded into a fragmented MP4 file. With -summary-only,
+// nothing but the final report is printed, on stdout. With -loop and
+// -duration, bounded captures are repeated until interrupted. With
+// -connect-only, the program only checks that the server answers OPTIONS.
+// With -compare, it prints the differences between the SDPs of two URLs.
 
 // To run this program:
-//   go run main.go <rtsp-url>
+//   go run . [flags] <rtsp-url>
 // For example:
-//   go run main.go rtsp://localhost:8554/mystream
+//   go run . -stall-timeout 5s rtsp://localhost:8554/mystream
 
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"log"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v4"
 	"github.com/bluenviron/gortsplib/v4/pkg/base"
 	"github.com/bluenviron/gortsplib/v4/pkg/description"
 	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 )
 
 func main() {
-	// Ensure RTSP URL is provided :
-	if len(os.Args) < 2 {
-		log.Fatalln("Usage:", os.Args[0], "<rtsp-url>")
+	os.Exit(start(parseFlags()))
+}
+
+// start runs a capture, or runs captures repeatedly with -loop or during
+// the windows of -schedule, until interrupted. It returns the exit code of
+// the program, which is the one of the last capture.
+func start(cfg *config) int {
+	// Resolve the server once, so that reconnections reach the same one :
+	if cfg.resolveOnce {
+		u, err := base.ParseURL(cfg.url)
+		if err != nil {
+			log.Printf("Cannot parse RTSP URL : %v", err)
+			return 1
+		}
+		if net.ParseIP(u.Hostname()) == nil {
+			cfg.pinnedHost, err = resolveHost(context.Background(), u.Hostname())
+			if err != nil {
+				log.Printf("Error resolving %s: %v", u.Hostname(), err)
+				return 1
+			}
+		}
+	}
+
+	if cfg.connectOnly {
+		return probe(cfg)
+	}
+	if cfg.compareURL != "" {
+		return compareStreams(cfg)
+	}
+	if cfg.probeAll {
+		return probePaths(cfg)
 	}
 
+	if cfg.interactive {
+		go readCommands(cfg)
+	}
+
+	// Get an error instead of being killed when the reader of the standard
+	// output goes away :
+	if cfg.writesStdout() {
+		signal.Ignore(syscall.SIGPIPE)
+	}
+
+	// Stop on Ctrl+C or termination request :
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.schedule != nil {
+		return captureScheduled(ctx, cfg)
+	}
+	if !cfg.loop {
+		return capture(ctx, cfg)
+	}
+
+	for iteration := 1; ; iteration++ {
+		log.Printf("Starting capture #%d", iteration)
+		code := capture(ctx, cfg)
+		if ctx.Err() != nil {
+			return code
+		}
+
+		// Wait before the next capture, unless interrupted :
+		log.Printf("Capture #%d done, next one in %v", iteration, cfg.loopInterval)
+		timer := time.NewTimer(cfg.loopInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return code
+		case <-timer.C:
+		}
+	}
+}
+
+// capture runs a session, and new ones as long as -follow-sdp-update ends
+// them to follow a change of the SDP, or -reconnect because the server
+// closes the connection. It returns the exit code of the last session.
+func capture(ctx context.Context, cfg *config) int {
+	for {
+		startedAt := time.Now()
+		code := run(ctx, cfg)
+		sdpChanged := cfg.sdpChanged.Swap(false)
+		reconnecting := cfg.reconnecting.Swap(false)
+		if ctx.Err() != nil {
+			return code
+		}
+
+		switch {
+		case sdpChanged:
+			log.Println("Starting a new session with the new SDP")
+
+		case reconnecting:
+			// Don't hammer a server closing the connection at once :
+			timer := time.NewTimer(time.Until(startedAt.Add(reconnectInterval)))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return code
+			case <-timer.C:
+			}
+			log.Println("Starting a new session on a new connection")
+
+		default:
+			return code
+		}
+	}
+}
+
+// run performs the whole RTSP session and returns its exit code.
+// It returns once ctx is done, the duration elapsed or the session ended.
+func run(ctx context.Context, cfg *config) int {
 	// Parsing RTSP URL :
-	rtspURL := os.Args[1]
-	parsedURL, err := base.ParseURL(rtspURL)
+	parsedURL, err := base.ParseURL(cfg.url)
 	if err != nil {
-		log.Fatalf("Cannot parse RTSP URL : %v", err)
+		log.Printf("Cannot parse RTSP URL : %v", err)
+		return 1
 	}
 
-	log.Println("Starting RTSP client for URL :", rtspURL)
+	log.Println("Starting RTSP client for URL :", cfg.url)
+	startedAt := time.Now()
 
 	// Create a new RTSP client with timeouts and enabling any port. :
 	// The client will be used to connect, describe, setup, and play the stream.
 	client := &gortsplib.Client{
-		ReadTimeout:   5 * time.Second,
-		WriteTimeout:  5 * time.Second,
+		ReadTimeout:   cfg.readTimeout,
+		WriteTimeout:  cfg.readTimeout,
 		AnyPortEnable: true,
 	}
 
-	// ---------------------------------
-	// Step 0: CONNECT to the RTSP Server
-	// ---------------------------------
+	// Record the switches from UDP to TCP for the final report :
+	fallbacks := &fallbackRecorder{}
+	client.OnTransportSwitch = fallbacks.onTransportSwitch
+
+	// Egress through the requested interface, and record the addresses
+	// of the connection :
+	conn := &connRecorder{dial: (&net.Dialer{}).DialContext}
+	if cfg.bindAddr != nil {
+		conn.dial = bindDialContext(cfg.bindAddr)
+	}
+	if cfg.pinnedHost != nil {
+		conn.dial = cfg.pinnedHost.wrap(conn.dial)
+	}
+	client.DialContext = conn.dialContext
+	// and drop the keepalives for the servers which reject them :
+	if cfg.disableKeepalive {
+		client.DialContext = disableKeepalive(client, client.DialContext)
+	}
+	// Create the UDP sockets with the requested address and options :
+	// RTCP multiplexed with RTP getting its own socket all the same :
+	demux := &rtcpDemux{}
+	client.ListenPacket = newListenPacket(cfg, demux)
+	// and go on without RTCP when its port can't be bound :
+	var rtcpFallback *optionalRTCP
+	if cfg.rtcpOptional {
+		rtcpFallback = &optionalRTCP{listen: client.ListenPacket}
+		client.ListenPacket = rtcpFallback.listenPacket
+	}
+	// Recognize RTCP packets multiplexed with RTP among decode errors :
+	muxedRTCP := &muxedRTCPFilter{}
+	// and the packets of a new source :
+	ssrcs := &ssrcFilter{next: muxedRTCP.onDecodeError}
+	// and packets which don't match the SDP anymore, fatal with -fail-fast :
+	strict := newFailFast(cfg.failFast)
+	follower := newSDPFollower(cfg, strict, ssrcs.onDecodeError)
+	client.OnDecodeError = follower.onDecodeError
+
+	// Record the RTSP exchange :
+	if cfg.traceFile != "" {
+		trace, err := newTraceWriter(cfg.traceFile, !cfg.traceNoRedact, cfg.traceRotateSize)
+		if err != nil {
+			log.Printf("Error opening trace file: %v", err)
+			return 1
+		}
+		defer func() {
+			err := trace.close()
+			if err != nil {
+				log.Printf("Error closing trace file: %v", err)
+			}
+		}()
+		trace.attach(client)
+	}
+
+	// Keep the DESCRIBE response, to decode its SDP again with the payload
+	// types mapped by -payload-map, or to tell an empty SDP apart. The client
+	// doesn't return the response when its SDP can't be decoded :
+	var describeRes *base.Response
+	if len(cfg.payloadMap) != 0 || cfg.describeRetryOnEmptySDP {
+		var describing bool
+		onRequest := client.OnRequest
+		client.OnRequest = func(req *base.Request) {
+			if onRequest != nil {
+				onRequest(req)
+			}
+			describing = req.Method == base.Describe
+		}
+		onResponse := client.OnResponse
+		client.OnResponse = func(res *base.Response) {
+			if onResponse != nil {
+				onResponse(res)
+			}
+			if describing {
+				describeRes = res
+			}
+		}
+	}
+
+	// Wait for the responses to some methods longer or shorter :
+	applyMethodTimeouts(client, cfg.methodTimeouts, cfg.readTimeout)
+
+	// Explain the failures which follow a Connection: close response :
+	closing := watchConnectionClose(client)
+
+	// Compare the server clock with the local clock :
+	serverClock := watchServerClock(client)
+
+	// Request the size of the payloads in SETUP :
+	if cfg.blocksize != 0 {
+		requestBlocksize(client, cfg.blocksize)
+	}
+
+	// Request the delivery speed in PLAY :
+	if cfg.speed > 0 {
+		requestSpeed(client, cfg.speed)
+	}
+
 	// The client.Start method connects to the RTSP server.
 	err = client.Start(parsedURL.Scheme, parsedURL.Host)
 	if err != nil {
-		log.Fatalf("Error connecting to server: %v", err)
+		log.Printf("Error connecting to server: %v", err)
+		return 1
 	}
 	// Ensure the client connection is closed on exit.
 	defer client.Close()
@@ -60,67 +274,544 @@ func main() {
 	// Step 1: DESCRIBE Request
 	// ----------------------------
 	// The DESCRIBE request retrieves the session description (SDP) and media tracks.
-	desc, _, err := client.Describe(parsedURL)
+	describeTimer := startPhaseTimer(client, cfg.describeTimeout)
+	var desc *description.Session
+	res, err := cfg.serverErrorRetry.do(ctx, "DESCRIBE", func() (*base.Response, error) {
+		var res *base.Response
+		var err error
+		desc, res, err = client.Describe(parsedURL)
+		return res, err
+	})
+
+	// Some devices answer with an empty SDP while they are starting :
+	for retry := 1; cfg.describeRetryOnEmptySDP && emptySDPResponse(describeRes); retry++ {
+		if retry > cfg.describeRetryLimit {
+			log.Printf("DESCRIBE still returns an empty SDP after %d retries, giving up", cfg.describeRetryLimit)
+			break
+		}
+		log.Printf("DESCRIBE returned an empty SDP, retrying in %v (%d/%d)",
+			cfg.describeRetryDelay, retry, cfg.describeRetryLimit)
+
+		select {
+		case <-ctx.Done():
+			return 1
+		case <-time.After(cfg.describeRetryDelay):
+		}
+
+		describeRes = nil
+		res, err = cfg.serverErrorRetry.do(ctx, "DESCRIBE", func() (*base.Response, error) {
+			var res *base.Response
+			var err error
+			desc, res, err = client.Describe(parsedURL)
+			return res, err
+		})
+	}
+
+	if describeTimer.stop() {
+		log.Printf("Error during DESCRIBE: not completed within %v", cfg.describeTimeout)
+		return 1
+	}
+
+	var rawSDP []byte
+	if res != nil {
+		rawSDP = res.Body
+	}
+
+	if len(cfg.payloadMap) != 0 && describeRes != nil && describeRes.StatusCode == base.StatusOK {
+		desc, rawSDP, err = applyPayloadMap(describeRes, parsedURL, cfg.payloadMap)
+		res = describeRes
+	}
+
 	if err != nil {
-		log.Fatalf("Error during DESCRIBE: %v", err)
+		log.Printf("Error during DESCRIBE: %v", err)
+		logStatusHint(res, err)
+		closing.logHint(err)
+		return 1
 	}
 
-	// Convert the SDP description to JSON format :
-	descJSON, err := json.MarshalIndent(desc, "", " ")
+	// Refuse oversized descriptions before processing them further :
+	err = checkSDPLimits(rawSDP, desc, cfg.maxSDPSize, cfg.maxMedias)
 	if err != nil {
-		log.Printf("Error marshaling SDP description to JSON: %v", err)
-	} else {
-		log.Println("SDP in JSON:")
-		log.Println(string(descJSON))
+		log.Printf("Error during DESCRIBE: %v", err)
+		return 1
+	}
+	if len(desc.Medias) == 0 {
+		log.Println("Error during DESCRIBE: the SDP declares no media")
+		if !cfg.describeRetryOnEmptySDP {
+			log.Println("Devices which are still starting may return an empty SDP, see -describe-retry-on-empty-sdp")
+		}
+		return 1
+	}
+
+	// Convert the SDP description to JSON format, keeping the attributes
+	// which are not mapped to known fields :
+	logJSON("SDP in JSON", newSDPDump(desc, rawSDP), cfg.reportJSONPretty)
+
+	follower.desc = desc
+	tracks := newTracks(desc)
+	for _, t := range tracks {
+		t.bitrate.window = cfg.bitrateWindow
+		t.maxBitrate = float64(cfg.maxBitrate)
+	}
+	ssrcs.tracks = tracks
+	markRTCPMux(tracks, rawSDP)
+	for _, c := range mediaClocks(rawSDP) {
+		if c.Media < len(tracks) {
+			tracks[c.Media].clock = c
+		}
+	}
+	for _, i := range cfg.tracks {
+		if i < 0 || i >= len(tracks) {
+			log.Printf("Invalid track index %d: the SDP declares %d tracks", i, len(tracks))
+			return 1
+		}
+	}
+	for i, name := range cfg.trackNames {
+		if i >= len(tracks) {
+			log.Printf("Invalid track index %d in -track-name: the SDP declares %d tracks", i, len(tracks))
+			return 1
+		}
+		tracks[i].name = name
+	}
+	for i, e := range cfg.rtpEpochs {
+		if i >= len(tracks) {
+			log.Printf("Invalid track index %d in -rtp-epoch: the SDP declares %d tracks", i, len(tracks))
+			return 1
+		}
+		tracks[i].epoch = e
+	}
+	trackByMedia := make(map[*description.Media]*track, len(tracks))
+	for _, t := range tracks {
+		trackByMedia[t.media] = t
 	}
 
 	// ----------------------------
 	// Step 2: SETUP Media
 	// ----------------------------
-	// Setup all medias :
-	err = client.SetupAll(desc.BaseURL, desc.Medias)
+	// Work around servers whose control attributes don't resolve against
+	// their base URL :
+	if cfg.controlBase != nil || cfg.controlMode != controlModeAppend {
+		controlBase := desc.BaseURL
+		if cfg.controlBase != nil {
+			controlBase = cfg.controlBase
+		}
+		err = overrideControls(tracks, controlBase, cfg.controlMode)
+		if err != nil {
+			log.Printf("Error resolving control attributes: %v", err)
+			return 1
+		}
+	}
+
+	// Setup selected medias one by one, in order to know which ones succeeded.
+	// Requests are never sent concurrently, whatever the number of tracks :
+	setupStart := time.Now()
+	setupTimer := startPhaseTimer(client, cfg.setupTimeout)
+	fallbacks.start()
+	for _, t := range tracks {
+		if !cfg.trackSelected(t.index) || setupTimer.expired() {
+			continue
+		}
+		if u, err := t.media.URL(desc.BaseURL); err == nil {
+			log.Printf("SETUP URL of track %s: %s", t, u)
+		}
+		demux.expect(t.rtcpMux)
+		res, err := cfg.serverErrorRetry.do(ctx, "SETUP", func() (*base.Response, error) {
+			return client.Setup(desc.BaseURL, t.media, 0, 0)
+		})
+		if done, derr := demux.take(); done && err == nil {
+			t.rtcpDemuxed = true
+		} else if derr != nil && err == nil {
+			log.Printf("WARNING: RTCP of track %s is multiplexed with RTP (rtcp-mux) but can't be "+
+				"demultiplexed (%v); it is dropped", t, derr)
+		}
+		if err != nil {
+			log.Printf("Error setting up track %s: %v", t, err)
+			logStatusHint(res, err)
+			closing.logHint(err)
+			if strict.enabled {
+				strict.trip("a selected track can't be SETUP")
+				return 1
+			}
+		}
+		if rtcpFallback != nil {
+			if port, rerr := rtcpFallback.take(); rerr != nil && err == nil {
+				log.Printf("WARNING: RTCP port %d of track %s can't be bound (%v), going on without RTCP: "+
+					"NTP mapping and the other RTCP features are disabled for the track", port, t, rerr)
+				t.rtcpUnavailable = true
+				if strict.enabled {
+					strict.trip("an RTCP port can't be bound")
+					return 1
+				}
+			}
+		}
+		t.onSetup(res, err)
+		if cfg.blocksize != 0 && err == nil {
+			t.blocksize = cfg.blocksize
+			t.blocksizeAccepted = acceptedBlocksize(res)
+		}
+	}
+	setupTimedOut := setupTimer.stop()
+
+	summary := newSetupSummary(cfg, tracks)
+	summary.DurationMS = durationMS(time.Since(setupStart))
+	logJSON("Setup summary", summary, cfg.reportJSONPretty)
+
+	// Keep the context of failures for bug reports :
+	if cfg.dumpSDPOnError && (setupTimedOut || !summary.succeeded()) {
+		dumpFailure(cfg, "SETUP", nil, rawSDP, tracks)
+	}
+
+	// The client is closed once the SETUP phase timed out :
+	if setupTimedOut {
+		log.Printf("Error during SETUP: not completed within %v", cfg.setupTimeout)
+		return 1
+	}
+
+	// Tear down without playing when only SETUP must be verified :
+	if cfg.noPlay {
+		if !summary.succeeded() {
+			log.Println("SETUP failed for at least one selected track")
+			return 1
+		}
+		log.Println("SETUP succeeded for all selected tracks, tearing down without PLAY")
+		return 0
+	}
+
+	// Decrypt the tracks protected by SRTP :
+	err = setupSRTP(tracks, rawSDP, cfg.srtpKey)
 	if err != nil {
-		log.Printf("Error setting up medias: %v", err)
+		log.Printf("Error setting up SRTP: %v", err)
+		return 1
 	}
 
 	// ---------------------------------------
 	// Step 3: Register RTP Packet Callback
 	// ---------------------------------------
-	// The OnPacketRTP callback is called whenever an RTP packet is received :
+	// Set up the analyses of the packets of the tracks :
+	if cfg.dedup {
+		setupDedupFilters(tracks, cfg.dedupWindow)
+	}
+	if cfg.simulateLoss > 0 {
+		setupLossSimulators(tracks, cfg.simulateLoss, cfg.lossSeed)
+	}
+	if cfg.normalizeTimestamps {
+		setupNormalizers(tracks)
+	}
+	if cfg.gopReport {
+		setupGOPAnalyzers(tracks)
+	}
+	if cfg.contentCheck {
+		setupContentCheckers(cfg, tracks)
+	}
+	if cfg.measureLoudness {
+		setupLoudnessMeters(tracks)
+	}
+	setupMetadataTracks(tracks)
+
+	// Warn about the tracks that the outputs can't extract, before streaming :
+	if warnUnsupportedTracks(cfg, tracks) && strict.enabled {
+		strict.trip("a codec can't be extracted by an enabled output")
+		return 1
+	}
+
+	// Forward the packets to every output, each one buffered on its own.
+	// Files are written from the queues of their outputs, never from the
+	// packet callback :
+	outs, err := openOutputs(cfg, tracks, client.PacketNTP)
+	if err != nil {
+		log.Printf("Error opening the outputs: %v", err)
+		return 1
+	}
+	defer outs.close()
+	logMediaClocks(tracks)
+	logChannels(tracks)
+
+	// Check the structure of packets :
+	var validator *rtpValidator
+	if cfg.validateRTP {
+		validator = &rtpValidator{}
+	}
+
+	// The OnPacketRTP callback is called whenever an RTP packet is received.
+	// The packet is shared by every consumer below, and none of them may
+	// modify it: gortsplib allocates a new buffer for every packet, so that
+	// it isn't copied. Only SRTP decryption replaces the payload, before
+	// any consumer sees it. Packets are dropped (unauthenticated, duplicate)
+	// before reaching any of them. The outputs of tracks with
+	// retransmissions get the packets in sequence order :
+	for _, t := range tracks {
+		if t.reorder != nil {
+			t.reorder.start(cfg.rtxReorderWait, func(p orderedPacket) {
+				outs.deliver(t, p)
+			})
+		}
+	}
+
 	client.OnPacketRTPAny(func(medi *description.Media, forma format.Format, pkt *rtp.Packet) {
-		packetInfo := map[string]any{
-			"version":           pkt.Version,
-			"sequence_number":   pkt.SequenceNumber,
-			"timestamp":         pkt.Timestamp,
-			"extension":         pkt.Extension,
-			"padding":           pkt.Padding,
-			"marker":            pkt.Marker,
-			"payload_type":      pkt.PayloadType,
-			"ssrc":              pkt.SSRC,
-			"csrc":              pkt.CSRC,
-			"extensions":        pkt.Extensions,
-			"extension_profile": pkt.ExtensionProfile,
-		}
-
-		packetJSON, err := json.MarshalIndent(packetInfo, "", "  ")
-		if err != nil {
-			log.Printf("Error marshaling RTP packet to JSON: %v", err)
+		t := trackByMedia[medi]
+
+		// Decrypt SRTP packets, dropping the ones which can't be authenticated :
+		if t.srtp != nil {
+			err := t.srtp.decrypt(pkt)
+			if err != nil {
+				if n := t.srtp.authFailures.Load(); n == 1 || n%100 == 0 {
+					log.Printf("WARNING: track %s: %v (%d failures so far)", t, err, n)
+				}
+				return
+			}
+		}
+
+		// Restore retransmitted packets (RFC 4588), which only fill gaps.
+		// They are counted as recovered instead of received :
+		retransmitted := false
+		if apt, ok := t.rtx[pkt.PayloadType]; ok {
+			orig, err := unwrapRTX(pkt, apt, t.originalSSRC())
+			if err != nil {
+				log.Printf("WARNING: track %s: %v", t, err)
+				return
+			}
+			if !t.onRetransmission(orig.SequenceNumber) {
+				return
+			}
+			pkt, retransmitted = orig, true
+			if f := aptFormat(medi, apt); f != nil {
+				forma = f
+			}
+		}
+
+		if validator != nil {
+			validator.check(t, pkt)
+		}
+
+		// Drop packets already received through a redundant path :
+		if t.dedup != nil && t.dedup.duplicate(pkt.SSRC, pkt.SequenceNumber) {
+			t.onDuplicate()
 			return
 		}
-		log.Println("Received RTP packet:")
-		log.Println(string(packetJSON))
+
+		var n uint64
+		if !retransmitted {
+			n = t.onPacket(pkt)
+			if t.nack != nil {
+				if nack := t.nack.take(pkt.SSRC); nack != nil {
+					if err := client.WritePacketRTCP(medi, nack); err != nil {
+						log.Printf("Error sending NACK for track %s: %v", t, err)
+					}
+				}
+			}
+			if t.content != nil {
+				t.content.push(pkt.Timestamp, pkt.Payload, time.Now())
+			}
+			if t.loudness != nil {
+				t.loudness.push(pkt.Payload)
+			}
+		}
+
+		p := orderedPacket{pkt: pkt, forma: forma, n: n, retransmitted: retransmitted}
+		if t.reorder == nil {
+			outs.deliver(t, p)
+			return
+		}
+		t.reorder.push(p)
 	})
 
+	// The OnPacketRTCP callbacks are called whenever an RTCP packet is received,
+	// unless RTCP is disabled for the track. Sender reports measure the drift
+	// of the clocks of the tracks :
+	drifts := newDriftMonitor(tracks, cfg.maxClockDrift)
+	for _, t := range tracks {
+		t.rtcp = t.setup && cfg.rtcpEnabled(t.index) && t.srtp == nil && !t.rtcpUnavailable
+		if t.rtcp {
+			client.OnPacketRTCP(t.media, func(pkt rtcp.Packet) {
+				if sr, ok := pkt.(*rtcp.SenderReport); ok {
+					t.onSenderReportSSRC(sr.SSRC)
+					t.drift.onSenderReport(sr)
+					drifts.check()
+				}
+				if t.rtt != nil {
+					t.rtt.onPacket(pkt, time.Now())
+				}
+				if !cfg.summaryOnly {
+					logRTCPPacket(t, pkt, cfg.packetJSONPretty)
+				}
+			})
+		}
+	}
+
+	// Measure the round-trip time to the server, and request the
+	// retransmission of lost packets, on the tracks whose RTCP is
+	// processed :
+	if cfg.measureRTT {
+		setupRTTMeters(tracks)
+	}
+	if cfg.sendNACK {
+		setupNACKSenders(tracks, rawSDP)
+	}
+
 	// -----------------------------------
 	// Step 4: Start the RTSP stream
 	// -----------------------------------
-	// Start playing to trigger the OnPacketRTPAny callback function :
-	_, err = client.Play(nil)
+	// Start playing to trigger the OnPacketRTPAny callback function, after
+	// starting the warmup of the metrics :
+	if cfg.warmup > 0 {
+		for _, t := range tracks {
+			t.startWarmup(cfg.warmup)
+		}
+		warmupTimer := time.AfterFunc(cfg.warmup, func() {
+			log.Printf("Warmup of %v complete, bitrate alerts and peaks are enabled", cfg.warmup)
+		})
+		defer warmupTimer.Stop()
+	}
+	res, err = client.Play(nil)
 	if err != nil {
 		log.Printf("Error during PLAY: %v\n", err)
+		logStatusHint(res, err)
+		closing.logHint(err)
+		if cfg.dumpSDPOnError {
+			dumpFailure(cfg, "PLAY", err, rawSDP, tracks)
+		}
+	} else {
+		// Anchor the normal play time of every track :
+		anchorNPT(res, desc.BaseURL, tracks)
+		if cfg.speed > 0 {
+			checkSpeed(res, cfg.speed)
+		}
+	}
+
+	// Warn about tracks which stay silent once the stall timeout elapsed :
+	deadTracksTimer := time.AfterFunc(cfg.stallTimeout, func() {
+		if warnDeadTracks(tracks, cfg.stallTimeout) {
+			strict.trip("a SETUP track received no packet")
+		}
+	})
+	defer deadTracksTimer.Stop()
+
+	// Stop the periodic tasks with the session :
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Log the stats of the tracks periodically :
+	if cfg.statsInterval > 0 {
+		go newStatsLogger(cfg, tracks, conn).run(ctx)
+	}
+
+	// Push the metrics of the tracks periodically :
+	if cfg.statsdAddr != "" {
+		pusher, err := newStatsdPusher(cfg, tracks)
+		if err != nil {
+			log.Printf("WARNING: can't push metrics to StatsD: %v", err)
+		} else {
+			go pusher.run(ctx)
+		}
+	}
+	if cfg.graphiteAddr != "" {
+		go newGraphitePusher(cfg, tracks).run(ctx)
+	}
+
+	// Send the reference times from which the round-trip times are measured :
+	if cfg.measureRTT {
+		go sendReferenceTimes(ctx, client, tracks)
 	}
 
-	// Run for infinity until explicit exit :
+	// Run until explicit exit, until the duration elapses
+	// or until the session terminates :
 	log.Println("Streaming... Press Ctrl+C to exit.")
-	select {}
+	clientErr := make(chan error, 1)
+	go func() {
+		clientErr <- client.Wait()
+	}()
+
+	var durationElapsed <-chan time.Time
+	if cfg.duration > 0 {
+		durationTimer := time.NewTimer(cfg.duration)
+		defer durationTimer.Stop()
+		durationElapsed = durationTimer.C
+	}
+
+	// End when the server announces that it closes the connection, to start
+	// a new session with -reconnect :
+	var reconnect <-chan struct{}
+	if cfg.reconnect {
+		reconnect = closing.closing
+	}
+
+	failed := false
+
+	select {
+	case <-ctx.Done():
+		if context.Cause(ctx) == errWindowClosed {
+			log.Println("Capture window closed, shutting down...")
+		} else {
+			log.Println("Interrupted, shutting down...")
+		}
+	case <-durationElapsed:
+		log.Printf("Duration of %v elapsed, shutting down...", cfg.duration)
+	case err = <-clientErr:
+		log.Printf("Session terminated: %v", err)
+		closing.logHint(err)
+	case <-pipeClosed:
+		log.Println("Output pipe closed by its reader, shutting down...")
+	case <-outs.formatChanged():
+		log.Println("Format of a recorded track changed, shutting down (-on-format-change fail)...")
+		failed = true
+	case <-outs.decodeErrors.failed:
+		log.Println("Shutting down after a decode error (-on-decode-error fail)...")
+		failed = true
+	case <-strict.tripped:
+		log.Println("Shutting down after a fatal warning (-fail-fast)...")
+		failed = true
+	case <-follower.changed:
+		log.Println("SDP changed, ending the session to start a new one")
+		cfg.sdpChanged.Store(true)
+	case <-reconnect:
+		log.Printf("Server closes the connection after its %s response, ending the session to start a new one",
+			closing.announced)
+		cfg.reconnecting.Store(true)
+	}
+
+	// Write the buffered packets before reporting, including the packets
+	// held while waiting for retransmissions :
+	for _, t := range tracks {
+		if t.reorder != nil {
+			t.reorder.close()
+		}
+	}
+	outs.drain()
+
+	report := newFinalReport(cfg.url, startedAt, tracks, conn, fallbacks)
+	report.MuxedRTCPDropped = muxedRTCP.dropped.Load()
+	report.ServerClock = serverClock.report()
+	report.ClockDrift = drifts.pairs()
+	if cfg.blocksize != 0 {
+		checkBlocksize(report.Tracks)
+	}
+	if cfg.measureRTT {
+		checkRTT(report.Tracks)
+	}
+	checkPadding(report.Tracks)
+	report.Outputs = outs.reports()
+
+	// The capture fails when a track received too few packets :
+	code := 0
+	if failed {
+		code = 1
+	}
+	if cfg.minPackets != 0 {
+		report.MinPackets = checkMinPackets(report, tracks, cfg.minPackets)
+		if !report.MinPackets.Met {
+			code = 1
+		}
+	}
+
+	if cfg.summaryOnly {
+		// stdout carries nothing else, for scripts :
+		err = printJSON(os.Stdout, report, cfg.reportJSONPretty)
+		if err != nil {
+			log.Printf("Error printing final report: %v", err)
+			return 1
+		}
+		return code
+	}
+	logJSON("Final report", report, cfg.reportJSONPretty)
+	return code
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/pion/rtp"
+)
+
+// maximum size of a metadata document; bigger documents are dropped.
+const metadataMaxSize = 1024 * 1024
+
+// isMetadataTrack returns whether the media carries ONVIF metadata, an XML
+// stream of events and analytics (application/vnd.onvif.metadata).
+func isMetadataTrack(medi *description.Media) bool {
+	if medi.Type != description.MediaTypeApplication {
+		return false
+	}
+	for _, forma := range medi.Formats {
+		if g, ok := forma.(*format.Generic); ok &&
+			strings.HasPrefix(strings.ToLower(g.RTPMap()), "vnd.onvif.metadata") {
+			return true
+		}
+	}
+	return false
+}
+
+// metadataAssembler reassembles the XML documents of a metadata track.
+// A document can be fragmented across several packets sharing the same
+// timestamp; the marker bit is set on its last packet.
+// It is not safe for concurrent use: it must only be fed by the packet
+// callback of its track.
+type metadataAssembler struct {
+	initialized bool
+	nextSeq     uint16
+
+	// whether a document is in progress, and its timestamp.
+	inDocument bool
+	timestamp  uint32
+	buf        []byte
+	// whether fragments of the current document were lost.
+	discarding bool
+}
+
+// push adds a packet to the current document, and returns the document
+// once its last packet is received. Documents with missing fragments are
+// dropped, and an error is returned.
+func (a *metadataAssembler) push(pkt *rtp.Packet) ([]byte, error) {
+	var err error
+
+	gap := a.initialized && pkt.SequenceNumber != a.nextSeq
+	a.initialized = true
+	a.nextSeq = pkt.SequenceNumber + 1
+
+	if gap || (a.inDocument && pkt.Timestamp != a.timestamp) {
+		if len(a.buf) != 0 {
+			err = fmt.Errorf("metadata document incomplete, dropping %d bytes", len(a.buf))
+		}
+		a.buf = a.buf[:0]
+		// after a gap, the packet may be in the middle of a document :
+		a.discarding = gap
+	}
+	a.inDocument = true
+	a.timestamp = pkt.Timestamp
+
+	if !a.discarding {
+		if len(a.buf)+len(pkt.Payload) > metadataMaxSize {
+			err = fmt.Errorf("metadata document exceeds %d bytes, dropping it", metadataMaxSize)
+			a.buf = a.buf[:0]
+			a.discarding = true
+		} else {
+			a.buf = append(a.buf, pkt.Payload...)
+		}
+	}
+
+	if !pkt.Marker {
+		return nil, err
+	}
+
+	// the next packet starts a new document :
+	var doc []byte
+	if !a.discarding && len(a.buf) != 0 {
+		doc = make([]byte, len(a.buf))
+		copy(doc, a.buf)
+	}
+	a.buf = a.buf[:0]
+	a.inDocument = false
+	a.discarding = false
+	return doc, err
+}
+
+// metadataWriter appends the metadata documents of every track to a file,
+// one document per line.
+type metadataWriter struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// newMetadataWriter creates the metadata file at path.
+func newMetadataWriter(path string) (*metadataWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &metadataWriter{file: f}, nil
+}
+
+// write appends a document to the file. Line breaks inside the document
+// are replaced by spaces, to keep one document per line.
+func (w *metadataWriter) write(doc []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	line := strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(string(doc))
+	_, err := w.file.WriteString(line + "\n")
+	return err
+}
+
+// close closes the file.
+func (w *metadataWriter) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.file.Close()
+}
+
+// setupMetadataTracks reassembles the XML documents of the ONVIF metadata
+// tracks that were SETUP.
+func setupMetadataTracks(tracks []*track) {
+	for _, t := range tracks {
+		if t.setup && isMetadataTrack(t.media) {
+			t.metadata = &metadataAssembler{}
+			log.Printf("Track %s carries ONVIF metadata, reassembling its XML documents", t)
+		}
+	}
+}
+
+// openMetadata writes the documents of the metadata tracks into the
+// -metadata-out file, instead of the packet records.
+func (o *outputs) openMetadata([]*track) error {
+	metadataOut, err := newMetadataWriter(o.cfg.outputPath(o.cfg.metadataOut))
+	if err != nil {
+		return fmt.Errorf("creating metadata file: %w", err)
+	}
+	o.onClose(func() {
+		err := metadataOut.close()
+		if err != nil {
+			log.Printf("Error closing metadata file: %v", err)
+		}
+	})
+	queue := o.newQueue("metadata")
+
+	o.documentsOut = true
+	o.onDocument(func(t *track, doc []byte, _ time.Time) {
+		queue.push(func() error {
+			err := metadataOut.write(doc)
+			if err != nil {
+				log.Printf("Error writing metadata of track %s: %v", t, err)
+			}
+			return nil
+		})
+	})
+	return nil
+}
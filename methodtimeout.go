@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// methodTimeoutFlag is a flag holding the time to wait for the responses to
+// some methods, in the METHOD=duration form, overriding -read-timeout.
+type methodTimeoutFlag map[base.Method]time.Duration
+
+// methodTimeoutMethods are the methods whose response the client waits for.
+// The keepalives (OPTIONS or GET_PARAMETER) and TEARDOWN, sent after PLAY,
+// are not answered within a timeout.
+var methodTimeoutMethods = []base.Method{
+	base.Options, base.Describe, base.Setup, base.Play,
+}
+
+// String implements flag.Value.
+func (m *methodTimeoutFlag) String() string {
+	entries := make([]string, 0, len(*m))
+	for method, timeout := range *m {
+		entries = append(entries, string(method)+"="+timeout.String())
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// Set implements flag.Value. It can be given several times, or with a
+// comma-separated list. Methods are case-insensitive.
+func (m *methodTimeoutFlag) Set(s string) error {
+	if *m == nil {
+		*m = make(methodTimeoutFlag)
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("invalid method timeout %q: expected METHOD=duration", entry)
+		}
+
+		var method base.Method
+		for _, known := range methodTimeoutMethods {
+			if strings.EqualFold(name, string(known)) {
+				method = known
+			}
+		}
+		if method == "" {
+			names := make([]string, len(methodTimeoutMethods))
+			for i, known := range methodTimeoutMethods {
+				names[i] = string(known)
+			}
+			return fmt.Errorf("invalid method %q: expected one of %s", name, strings.Join(names, ", "))
+		}
+
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q of %s: expected a positive duration", value, method)
+		}
+
+		(*m)[method] = timeout
+	}
+	return nil
+}
+
+// applyMethodTimeouts makes the client wait for the response to every
+// request within the timeout of its method, or within fallback, until PLAY
+// is answered. The client waits for responses within its ReadTimeout, which
+// is switched before every request and restored once it is answered;
+// requests and responses are handled by the goroutine of the client, which
+// reads ReadTimeout, so that switching it doesn't race. Once PLAY is
+// answered, ReadTimeout is the time after which the client gives up on the
+// media flow, and is left at fallback: the keepalives, whose responses the
+// client doesn't wait for, must not switch it.
+func applyMethodTimeouts(client *gortsplib.Client, timeouts methodTimeoutFlag, fallback time.Duration) {
+	if len(timeouts) == 0 {
+		return
+	}
+
+	var method base.Method
+	playing := false
+	onRequest := client.OnRequest
+	client.OnRequest = func(req *base.Request) {
+		if onRequest != nil {
+			onRequest(req)
+		}
+		if playing {
+			return
+		}
+		method = req.Method
+		client.ReadTimeout = fallback
+		if timeout, ok := timeouts[req.Method]; ok {
+			client.ReadTimeout = timeout
+		}
+	}
+	onResponse := client.OnResponse
+	client.OnResponse = func(res *base.Response) {
+		if onResponse != nil {
+			onResponse(res)
+		}
+		if playing {
+			return
+		}
+		client.ReadTimeout = fallback
+		playing = method == base.Play && res.StatusCode == base.StatusOK
+	}
+}
@@ -0,0 +1,939 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/pkg/formats/fmp4/seekablebuffer"
+)
+
+const (
+	// minimum duration of a fragment; fragments are cut on keyframes of
+	// the leading track, once they are at least this long.
+	mp4FragmentDuration = 1 * time.Second
+
+	// time to wait for an RTCP sender report before synchronizing tracks
+	// on reception time instead of NTP time.
+	mp4NTPWaitTimeout = 5 * time.Second
+
+	// maximum span of the units buffered with -warmup-describe while the
+	// parameters of the video tracks are unknown.
+	mp4WarmupBuffer = 10 * time.Second
+)
+
+// Policies applied when the parameters of a video track change while it is
+// recorded, so that its samples don't match the initialization segment of
+// the file anymore :
+const (
+	// The file is completed, and a new one starts on the next keyframe :
+	formatChangeRotate = "rotate"
+
+	// The samples are written on, players may fail to decode them :
+	formatChangeContinue = "continue"
+
+	// The recording stops, and the capture ends with an error :
+	formatChangeFail = "fail"
+)
+
+// errFormatChanged is returned when a track changed format, with
+// -on-format-change fail.
+var errFormatChanged = errors.New("format changed")
+
+// mp4Track is a track of the MP4 file.
+type mp4Track struct {
+	id        int
+	track     *track
+	format    format.Format
+	timeScale int64
+	isVideo   bool
+
+	// H264 and H265 parameters, updated with the in-band ones, and the ones
+	// of the initialization segment of the current file :
+	vps     []byte
+	sps     []byte
+	pps     []byte
+	initVPS []byte
+	initSPS []byte
+	initPPS []byte
+
+	h264DTS *h264.DTSExtractor2
+	h265DTS *h265.DTSExtractor2
+
+	started bool
+	// first PTS of the track, on the timeline fed to the DTS extractors.
+	firstPTS int64
+	// whether the track started in the current file, and the DTS
+	// corresponding to the start of the file.
+	inFile   bool
+	fileBase int64
+
+	// last sample, whose duration is known once the next one arrives.
+	pending      *fmp4.PartSample
+	pendingDTS   int64
+	lastDuration uint32
+	// completed samples of the current fragment.
+	samples  []*fmp4.PartSample
+	baseTime int64
+}
+
+// codec returns the fmp4 codec of the track, or nil when its parameters
+// are not known yet.
+func (mt *mp4Track) codec() fmp4.Codec {
+	switch forma := mt.format.(type) {
+	case *format.H264:
+		if mt.sps == nil || mt.pps == nil {
+			return nil
+		}
+		return &fmp4.CodecH264{SPS: mt.sps, PPS: mt.pps}
+
+	case *format.H265:
+		if mt.vps == nil || mt.sps == nil || mt.pps == nil {
+			return nil
+		}
+		return &fmp4.CodecH265{VPS: mt.vps, SPS: mt.sps, PPS: mt.pps}
+
+	case *format.MPEG4Audio:
+		conf := forma.GetConfig()
+		if conf == nil {
+			return nil
+		}
+		return &fmp4.CodecMPEG4Audio{Config: *conf}
+	}
+	return nil
+}
+
+// paramsChanged returns whether the parameters of the track differ from the
+// ones of the initialization segment, in a new resolution or profile.
+func (mt *mp4Track) paramsChanged() bool {
+	return !bytes.Equal(mt.vps, mt.initVPS) || !bytes.Equal(mt.sps, mt.initSPS) ||
+		!bytes.Equal(mt.pps, mt.initPPS)
+}
+
+// updateParams stores the parameter sets carried in-band by a video unit.
+func (mt *mp4Track) updateParams(units [][]byte) {
+	for _, nalu := range units {
+		if len(nalu) == 0 {
+			continue
+		}
+		switch mt.format.(type) {
+		case *format.H264:
+			switch h264.NALUType(nalu[0] & 0x1F) {
+			case h264.NALUTypeSPS:
+				mt.sps = nalu
+			case h264.NALUTypePPS:
+				mt.pps = nalu
+			}
+
+		case *format.H265:
+			switch h265.NALUType((nalu[0] >> 1) & 0x3F) {
+			case h265.NALUType_VPS_NUT:
+				mt.vps = nalu
+			case h265.NALUType_SPS_NUT:
+				mt.sps = nalu
+			case h265.NALUType_PPS_NUT:
+				mt.pps = nalu
+			}
+		}
+	}
+}
+
+// withParams returns a copy of a keyframe preceded by the parameter sets of
+// the track, replacing the ones it carries, if any, so that a keyframe
+// received before them can be decoded.
+func (mt *mp4Track) withParams(au *accessUnit) *accessUnit {
+	var units [][]byte
+	for _, param := range [][]byte{mt.vps, mt.sps, mt.pps} {
+		if param != nil {
+			units = append(units, param)
+		}
+	}
+	for _, nalu := range au.units {
+		if len(nalu) == 0 {
+			continue
+		}
+		switch mt.format.(type) {
+		case *format.H264:
+			switch h264.NALUType(nalu[0] & 0x1F) {
+			case h264.NALUTypeSPS, h264.NALUTypePPS:
+				continue
+			}
+
+		case *format.H265:
+			switch h265.NALUType((nalu[0] >> 1) & 0x3F) {
+			case h265.NALUType_VPS_NUT, h265.NALUType_SPS_NUT, h265.NALUType_PPS_NUT:
+				continue
+			}
+		}
+		units = append(units, nalu)
+	}
+
+	c := *au
+	c.units = units
+	return &c
+}
+
+// mp4Rotation tells when the MP4 recording moves on to a new file.
+type mp4Rotation struct {
+	// maximum duration and size of a file, or zero for no limit :
+	interval time.Duration
+	size     int64
+	// roll files on keyframes of the leading track only, so that every
+	// file starts decodable; otherwise, files are rolled as soon as a
+	// limit is reached, and video tracks may start in the middle of a GOP.
+	onKeyframe bool
+}
+
+// enabled returns whether the recording is split into several files.
+func (r mp4Rotation) enabled() bool {
+	return r.interval > 0 || r.size > 0
+}
+
+// mp4Muxer writes H264, H265 and AAC tracks into a fragmented MP4 file,
+// or a series of files when rotation is enabled, or the segments of a CMAF
+// output, which get a file of their own.
+// The recording starts with a keyframe of the leading track (the first video
+// track, or the first track when there is no video), and tracks are placed
+// on a common timeline using the NTP times of RTCP sender reports.
+type mp4Muxer struct {
+	mutex    sync.Mutex
+	path     string
+	rotation mp4Rotation
+	file     *os.File
+	tracks   map[*track]*mp4Track
+	ordered  []*mp4Track
+	leading  *mp4Track
+	closed   bool
+
+	// minimum duration of the fragments, and the segments receiving the
+	// initialization segment and the fragments instead of file, if any.
+	fragmentDuration time.Duration
+	segments         *cmafSegments
+
+	// policy applied when the parameters of a video track change, and
+	// channel closed when the recording stops for it.
+	onFormatChange string
+	formatChanged  chan struct{}
+
+	// with -warmup-describe, the file is only created once the parameters
+	// of every video track are known, and the units received meanwhile,
+	// from a keyframe of the leading track, are buffered into early.
+	warmup bool
+	early  []*accessUnit
+
+	firstUnitAt time.Time
+	started     bool
+	startNTP    time.Time
+	startWall   time.Time
+	nextSeq     uint32
+
+	// numbers of the files, and whether the first one is numbered too.
+	numbers  *fileNumbers
+	numbered bool
+
+	// index and path of the current file, and bytes written into it.
+	segment int
+	current string
+	written int64
+}
+
+// mp4Supported returns whether a format can be written into MP4 files.
+func mp4Supported(forma format.Format) bool {
+	switch forma.(type) {
+	case *format.H264, *format.H265, *format.MPEG4Audio:
+		return true
+	}
+	return false
+}
+
+// newMP4Muxer creates the MP4 file at path, containing the SETUP tracks
+// whose codec is supported. Other tracks are skipped.
+// When mediaType is not empty, only the tracks of that type are recorded.
+// With rotation, or numbered (with sessions), files are numbered from path
+// by numbers: rec.mp4 gives rec-0001.mp4, rec-0002.mp4, and so on. Without,
+// the files started after a change of parameters, with the rotate
+// onFormatChange policy, are numbered from the second one. With warmup, the
+// file is only created once the parameters of the video tracks are known.
+func newMP4Muxer(path string, numbers *fileNumbers, numbered bool, tracks []*track, rotation mp4Rotation,
+	mediaType description.MediaType, onFormatChange string, warmup bool,
+) (*mp4Muxer, error) {
+	m := &mp4Muxer{
+		path:             path,
+		numbers:          numbers,
+		numbered:         numbered || rotation.enabled(),
+		rotation:         rotation,
+		fragmentDuration: mp4FragmentDuration,
+		onFormatChange:   onFormatChange,
+		formatChanged:    make(chan struct{}),
+		tracks:           make(map[*track]*mp4Track),
+		warmup:           warmup,
+	}
+
+	err := m.addTracks(tracks, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	for _, mt := range m.ordered {
+		log.Printf("Writing track %s to MP4", mt.track)
+	}
+
+	if !warmup {
+		err = m.createFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// addTracks adds the SETUP tracks whose codec is supported, of mediaType
+// when it is not empty.
+func (m *mp4Muxer) addTracks(tracks []*track, mediaType description.MediaType) error {
+	for _, t := range tracks {
+		if !t.setup {
+			continue
+		}
+
+		if mediaType != "" && t.media.Type != mediaType {
+			log.Printf("Track %s is not %s, not writing it to MP4", t, mediaType)
+			continue
+		}
+
+		// Unsupported tracks were reported by warnUnsupportedTracks :
+		forma := t.media.Formats[0]
+		if !mp4Supported(forma) {
+			continue
+		}
+
+		mt := &mp4Track{
+			id:        len(m.ordered) + 1,
+			track:     t,
+			format:    forma,
+			timeScale: int64(forma.ClockRate()),
+			isVideo:   t.media.Type == description.MediaTypeVideo,
+		}
+
+		switch forma := forma.(type) {
+		case *format.H264:
+			mt.sps, mt.pps = forma.SafeParams()
+			mt.h264DTS = h264.NewDTSExtractor2()
+		case *format.H265:
+			mt.vps, mt.sps, mt.pps = forma.SafeParams()
+			mt.h265DTS = h265.NewDTSExtractor2()
+		}
+
+		m.tracks[t] = mt
+		m.ordered = append(m.ordered, mt)
+		if m.leading == nil || (mt.isVideo && !m.leading.isVideo) {
+			m.leading = mt
+		}
+	}
+
+	if len(m.ordered) == 0 {
+		if mediaType != "" {
+			return fmt.Errorf("no %s track can be written to MP4", mediaType)
+		}
+		return fmt.Errorf("no track can be written to MP4")
+	}
+	return nil
+}
+
+// filePath returns the path of the current file, or the directory of the
+// CMAF segments.
+func (m *mp4Muxer) filePath() string {
+	if m.segments != nil {
+		return m.path
+	}
+	return m.current
+}
+
+// numberedPath returns the path of the file of the given index, among the
+// files of a rotated output: rec.mp4 gives rec-0001.mp4, rec-0002.mp4...
+// Paths without extension, such as the ones of directories, are numbered at
+// their end, before a trailing separator: out/ gives out-0001/.
+func numberedPath(path string, index int) string {
+	name := strings.TrimRight(path, `/`+string(filepath.Separator))
+	separator := path[len(name):]
+
+	ext := filepath.Ext(name)
+	if separator != "" || ext == filepath.Base(name) {
+		ext = ""
+	}
+	return fmt.Sprintf("%s-%04d%s%s", strings.TrimSuffix(name, ext), index+1, ext, separator)
+}
+
+// fileNumbers numbers the files of the outputs written as a series: the
+// files of every session with -loop, -follow-sdp-update, -reconnect or
+// -schedule, and the files of a rotation. An output has a single series
+// across sessions and rotations, so that rec.mp4 gives rec-0001.mp4,
+// rec-0002.mp4... in the order they are created. The zero value is ready to use.
+type fileNumbers struct {
+	mutex sync.Mutex
+	next  map[string]int
+}
+
+// path returns the path of the next file of the output at path.
+func (n *fileNumbers) path(path string) string {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.next == nil {
+		n.next = make(map[string]int)
+	}
+	index := n.next[path]
+	n.next[path]++
+	return numberedPath(path, index)
+}
+
+// createFile creates the next file. Files are numbered with rotation or
+// sessions, and from the second one otherwise: the number of the first one
+// is taken all the same, so that rec.mp4 is followed by rec-0002.mp4.
+func (m *mp4Muxer) createFile() error {
+	m.current = m.path
+	numbered := m.numbers.path(m.path)
+	if m.numbered || m.segment != 0 {
+		m.current = numbered
+	}
+
+	f, err := createOutput(m.current)
+	if err != nil {
+		return err
+	}
+	m.file = f
+	m.written = 0
+	return nil
+}
+
+// write appends data to the current file.
+func (m *mp4Muxer) write(data []byte) error {
+	n, err := m.file.Write(data)
+	m.written += int64(n)
+	return err
+}
+
+// writeAccessUnit adds an access unit to the file.
+func (m *mp4Muxer) writeAccessUnit(au *accessUnit) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	mt, ok := m.tracks[au.track]
+	if !ok || m.closed {
+		return nil
+	}
+	if m.warmup {
+		return m.bufferEarly(mt, au)
+	}
+	return m.writeUnit(mt, au)
+}
+
+// videoParamsKnown returns whether the parameters of every video track are
+// known, from the SDP or in-band.
+func (m *mp4Muxer) videoParamsKnown() bool {
+	for _, mt := range m.ordered {
+		if mt.isVideo && mt.codec() == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// bufferEarly buffers a unit received before the parameters of every video
+// track are known, with -warmup-describe, from a keyframe of the leading
+// track. Once they are known, the recording starts from the first buffered
+// keyframe, which the parameters received after it make decodable.
+func (m *mp4Muxer) bufferEarly(mt *mp4Track, au *accessUnit) error {
+	if mt.isVideo {
+		mt.updateParams(au.units)
+	}
+	if len(m.early) == 0 {
+		if mt != m.leading || !au.keyframe {
+			return nil
+		}
+		if m.firstUnitAt.IsZero() {
+			m.firstUnitAt = au.received
+			if !m.videoParamsKnown() {
+				log.Printf("Waiting for the parameters of the video tracks before starting %s",
+					outputName(m.filePath()))
+			}
+		}
+	}
+	m.early = append(m.early, au)
+
+	// Keep the buffer within its span, from a keyframe of the leading track :
+	if au.received.Sub(m.early[0].received) > mp4WarmupBuffer {
+		next := 0
+		for i, u := range m.early[1:] {
+			if m.tracks[u.track] == m.leading && u.keyframe {
+				next = i + 1
+				break
+			}
+		}
+		if next == 0 {
+			m.early = m.early[:0]
+			return nil
+		}
+		m.early = m.early[next:]
+	}
+
+	// Wait for a sender report, to synchronize tracks on NTP time :
+	first := m.early[0]
+	if !m.videoParamsKnown() ||
+		first.ntp.IsZero() && au.received.Sub(m.firstUnitAt) < mp4NTPWaitTimeout {
+		return nil
+	}
+
+	early := m.early
+	m.early = nil
+	m.warmup = false
+	err := m.start(first)
+	if err != nil || !m.started {
+		return err
+	}
+	for _, u := range early {
+		ut, ok := m.tracks[u.track]
+		if !ok {
+			continue
+		}
+		if ut.isVideo && !ut.started && u.keyframe {
+			u = ut.withParams(u)
+		}
+		err = m.writeUnit(ut, u)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeUnit adds an access unit of mt to the file.
+func (m *mp4Muxer) writeUnit(mt *mp4Track, au *accessUnit) error {
+	if mt.isVideo {
+		mt.updateParams(au.units)
+		if m.started && mt.paramsChanged() {
+			err := m.formatChange(mt, au)
+			if err != nil || !m.started || !mt.inFile && !au.keyframe {
+				return err
+			}
+		}
+	}
+
+	if !m.started {
+		if mt != m.leading || !au.keyframe {
+			return nil
+		}
+		if m.firstUnitAt.IsZero() {
+			m.firstUnitAt = au.received
+		}
+
+		// Wait for a sender report, to synchronize tracks on NTP time :
+		if au.ntp.IsZero() && au.received.Sub(m.firstUnitAt) < mp4NTPWaitTimeout {
+			return nil
+		}
+
+		err := m.start(au)
+		if err != nil || !m.started {
+			return err
+		}
+	}
+
+	if mt == m.leading && m.rotationDue(au) {
+		err := m.rotate(au)
+		if err != nil || !m.started {
+			return err
+		}
+	}
+
+	if !mt.started {
+		if mt.isVideo && !au.keyframe {
+			return nil
+		}
+		mt.started = true
+		mt.firstPTS = au.pts
+	}
+
+	pts := au.pts - mt.firstPTS
+	if pts < 0 {
+		return nil
+	}
+
+	dts, sample, err := mt.newSample(pts, au.units)
+	if err != nil {
+		return err
+	}
+
+	// Place the track on the timeline of the current file. After a rotation
+	// which was not aligned on keyframes, video tracks resume anywhere :
+	if !mt.inFile {
+		if mt.isVideo && !au.keyframe && (m.segment == 0 || m.rotation.onKeyframe) {
+			return nil
+		}
+		mt.inFile = true
+		mt.fileBase = dts - m.timelineOffset(au, mt.timeScale)
+	}
+	dts -= mt.fileBase
+	if dts < 0 {
+		return nil
+	}
+
+	if mt.pending != nil {
+		if dts <= mt.pendingDTS {
+			return fmt.Errorf("non-increasing DTS on track %s", mt.track)
+		}
+		mt.pending.Duration = uint32(dts - mt.pendingDTS)
+		mt.lastDuration = mt.pending.Duration
+		if len(mt.samples) == 0 {
+			mt.baseTime = mt.pendingDTS
+		}
+		mt.samples = append(mt.samples, mt.pending)
+	}
+
+	// Cut a fragment before a keyframe of the leading track :
+	if mt == m.leading && au.keyframe && len(mt.samples) != 0 &&
+		dts-mt.baseTime >= int64(m.fragmentDuration.Seconds()*float64(mt.timeScale)) {
+		err = m.writeFragment()
+		if err != nil {
+			return err
+		}
+	}
+
+	mt.pending = sample
+	mt.pendingDTS = dts
+	return nil
+}
+
+// formatChange applies the policy to a unit of a video track whose
+// parameters changed since the initialization segment.
+func (m *mp4Muxer) formatChange(mt *mp4Track, au *accessUnit) error {
+	policy := m.onFormatChange
+	if policy == formatChangeRotate && m.path == stdoutPath {
+		log.Printf("WARNING: parameters of track %s changed, a new MP4 file can't be started "+
+			"on the standard output", mt.track)
+		policy = formatChangeFail
+	}
+
+	switch policy {
+	case formatChangeContinue:
+		log.Printf("WARNING: parameters of track %s changed while recording %s, "+
+			"players may fail to decode the samples which follow", mt.track, outputName(m.filePath()))
+		mt.initVPS, mt.initSPS, mt.initPPS = mt.vps, mt.sps, mt.pps
+		return nil
+
+	case formatChangeFail:
+		log.Printf("Parameters of track %s changed while recording %s, stopping the recording",
+			mt.track, outputName(m.filePath()))
+		close(m.formatChanged)
+		return fmt.Errorf("track %s: %w", mt.track, errFormatChanged)
+	}
+
+	// The samples of the new parameters start with a keyframe, and the ones
+	// before can't be decoded :
+	if !au.keyframe {
+		mt.inFile = false
+		return nil
+	}
+	if m.segments != nil {
+		log.Printf("Parameters of track %s changed, starting a new CMAF initialization segment", mt.track)
+	} else {
+		log.Printf("Parameters of track %s changed, completing %s and starting a new MP4 file",
+			mt.track, outputName(m.filePath()))
+	}
+	return m.rotate(au)
+}
+
+// start writes the initialization segment, when the parameters of
+// every video track are known.
+func (m *mp4Muxer) start(au *accessUnit) error {
+	init := &fmp4.Init{}
+	for _, mt := range m.ordered {
+		codec := mt.codec()
+		if codec == nil {
+			if mt.isVideo {
+				// wait for the parameters, carried in-band by a next keyframe.
+				return nil
+			}
+			log.Printf("WARNING: track %s has no codec configuration, skipping it", mt.track)
+			continue
+		}
+		init.Tracks = append(init.Tracks, &fmp4.InitTrack{
+			ID:        mt.id,
+			TimeScale: uint32(mt.timeScale),
+			Codec:     codec,
+		})
+	}
+
+	var buf seekablebuffer.Buffer
+	err := init.Marshal(&buf)
+	if err != nil {
+		return err
+	}
+	if m.segments == nil && m.file == nil {
+		err = m.createFile()
+		if err != nil {
+			return err
+		}
+	}
+	if m.segments != nil {
+		err = m.segments.writeInit(buf.Bytes())
+	} else {
+		err = m.write(buf.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, mt := range m.ordered {
+		included := false
+		for _, it := range init.Tracks {
+			if it.ID == mt.id {
+				included = true
+			}
+		}
+		if !included {
+			delete(m.tracks, mt.track)
+		}
+		mt.initVPS, mt.initSPS, mt.initPPS = mt.vps, mt.sps, mt.pps
+	}
+
+	m.started = true
+	m.startNTP = au.ntp
+	m.startWall = au.received
+
+	if m.startNTP.IsZero() {
+		log.Printf("MP4 recording started on %s, synchronized on reception time (no RTCP sender report)",
+			outputName(m.filePath()))
+	} else {
+		log.Printf("MP4 recording started on %s, synchronized on RTCP NTP time", outputName(m.filePath()))
+	}
+	return nil
+}
+
+// rotationDue returns whether the current file must be closed before
+// writing the given unit of the leading track.
+func (m *mp4Muxer) rotationDue(au *accessUnit) bool {
+	if !m.started || (m.rotation.onKeyframe && !au.keyframe) {
+		return false
+	}
+	return (m.rotation.interval > 0 && au.received.Sub(m.startWall) >= m.rotation.interval) ||
+		(m.rotation.size > 0 && m.written >= m.rotation.size)
+}
+
+// rotate completes the current file and starts the next one, from the
+// given unit of the leading track. CMAF outputs go on with a new
+// initialization segment.
+func (m *mp4Muxer) rotate(au *accessUnit) error {
+	err := m.flush()
+	if err != nil {
+		return err
+	}
+
+	m.segment++
+	if m.segments == nil {
+		err = m.file.Close()
+		if err != nil {
+			return err
+		}
+		err = m.createFile()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, mt := range m.ordered {
+		mt.inFile = false
+	}
+	m.started = false
+	m.nextSeq = 0
+	return m.start(au)
+}
+
+// timelineOffset returns the position of the first unit of a track on the
+// file timeline, in units of the given time scale.
+func (m *mp4Muxer) timelineOffset(au *accessUnit, timeScale int64) int64 {
+	var d time.Duration
+	if !m.startNTP.IsZero() && !au.ntp.IsZero() {
+		d = au.ntp.Sub(m.startNTP)
+	} else {
+		d = au.received.Sub(m.startWall)
+	}
+	return int64(d.Seconds() * float64(timeScale))
+}
+
+// newSample converts a unit into a sample, and returns its decoding timestamp.
+func (mt *mp4Track) newSample(pts int64, units [][]byte) (int64, *fmp4.PartSample, error) {
+	switch {
+	case mt.h264DTS != nil:
+		dts, err := mt.h264DTS.Extract(units, pts)
+		if err != nil {
+			return 0, nil, err
+		}
+		sample, err := fmp4.NewPartSampleH264(int32(pts-dts), units)
+		return dts, sample, err
+
+	case mt.h265DTS != nil:
+		dts, err := mt.h265DTS.Extract(units, pts)
+		if err != nil {
+			return 0, nil, err
+		}
+		sample, err := fmp4.NewPartSampleH265(int32(pts-dts), units)
+		return dts, sample, err
+
+	default:
+		return pts, &fmp4.PartSample{Payload: units[0]}, nil
+	}
+}
+
+// writeFragment writes the completed samples of every track.
+func (m *mp4Muxer) writeFragment() error {
+	part := &fmp4.Part{
+		SequenceNumber: m.nextSeq,
+	}
+
+	for _, mt := range m.ordered {
+		if len(mt.samples) == 0 {
+			continue
+		}
+		part.Tracks = append(part.Tracks, &fmp4.PartTrack{
+			ID:       mt.id,
+			BaseTime: uint64(mt.baseTime),
+			Samples:  mt.samples,
+		})
+		mt.samples = nil
+	}
+
+	if len(part.Tracks) == 0 {
+		return nil
+	}
+	m.nextSeq++
+
+	var buf seekablebuffer.Buffer
+	err := part.Marshal(&buf)
+	if err != nil {
+		return err
+	}
+	if m.segments != nil {
+		return m.segments.writeSegment(buf.Bytes(), partDuration(part, m.ordered))
+	}
+	return m.write(buf.Bytes())
+}
+
+// partDuration returns the duration of the longest track of a fragment.
+func partDuration(part *fmp4.Part, tracks []*mp4Track) time.Duration {
+	var longest time.Duration
+	for _, pt := range part.Tracks {
+		for _, mt := range tracks {
+			if mt.id != pt.ID {
+				continue
+			}
+			var ticks int64
+			for _, s := range pt.Samples {
+				ticks += int64(s.Duration)
+			}
+			longest = max(longest, ticksToDuration(ticks, mt.timeScale))
+		}
+	}
+	return longest
+}
+
+// close writes the remaining samples and closes the file, so that it is
+// playable. Units written afterwards are discarded.
+func (m *mp4Muxer) close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	var err error
+	if m.started {
+		err = m.flush()
+	}
+
+	var cerr error
+	switch {
+	case m.segments != nil:
+		cerr = m.segments.close()
+	case m.file != nil:
+		cerr = m.file.Close()
+	default:
+		log.Printf("WARNING: the parameters of the video tracks were never received, %s was not created",
+			outputName(m.filePath()))
+	}
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// flush writes the remaining samples of every track into the current file.
+func (m *mp4Muxer) flush() error {
+	// The duration of the last samples is unknown, repeat the previous one :
+	for _, mt := range m.ordered {
+		if mt.pending == nil {
+			continue
+		}
+		if len(mt.samples) == 0 {
+			mt.baseTime = mt.pendingDTS
+		}
+		mt.pending.Duration = mt.lastDuration
+		mt.samples = append(mt.samples, mt.pending)
+		mt.pending = nil
+	}
+	return m.writeFragment()
+}
+
+// openMP4 records the supported tracks into the -mp4-out file, finalized
+// once the session ends.
+func (o *outputs) openMP4(tracks []*track) error {
+	var mediaType description.MediaType
+	switch {
+	case o.cfg.mp4VideoOnly:
+		mediaType = description.MediaTypeVideo
+	case o.cfg.mp4AudioOnly:
+		mediaType = description.MediaTypeAudio
+	}
+	muxer, err := newMP4Muxer(o.cfg.mp4Out, &o.cfg.files, o.cfg.sessions(), tracks, o.cfg.mp4Rotation, mediaType,
+		o.cfg.onFormatChange, o.cfg.warmupDescribe)
+	if err != nil {
+		return fmt.Errorf("creating MP4 file: %w", err)
+	}
+	o.onClose(func() {
+		err := muxer.close()
+		if err != nil && !isBrokenPipe(err) {
+			log.Printf("Error finalizing MP4 file: %v", err)
+		}
+	})
+	o.mp4 = muxer
+	queue := o.newQueue("MP4")
+
+	setupDepacketizers(tracks, func(t *track) bool {
+		_, ok := muxer.tracks[t]
+		return ok
+	})
+	o.onAccessUnit(func(au *accessUnit) {
+		queue.push(func() error {
+			err := muxer.writeAccessUnit(au)
+			if isBrokenPipe(err) || errors.Is(err, errFormatChanged) {
+				return err
+			}
+			if err != nil {
+				log.Printf("Error writing track %s to MP4: %v", au.track, err)
+			}
+			return nil
+		})
+	})
+	return nil
+}
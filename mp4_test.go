@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestNumberedPath(t *testing.T) {
+	for _, ca := range []struct {
+		path string
+		want string
+	}{
+		{"rec.mp4", "rec-0003.mp4"},
+		{"out/rec.mp4", "out/rec-0003.mp4"},
+		{"rec", "rec-0003"},
+		{"out.d/rec", "out.d/rec-0003"},
+		{".rec", ".rec-0003"},
+		{"out/", "out-0003/"},
+		{"out.d//", "out.d-0003//"},
+	} {
+		got := numberedPath(ca.path, 2)
+		if got != ca.want {
+			t.Errorf("%s: got %s, want %s", ca.path, got, ca.want)
+		}
+	}
+}
+
+func TestMP4FileNumbers(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rec.mp4")
+
+	// Two sessions with rotation write a single series :
+	var numbers fileNumbers
+	var got []string
+	for session := 0; session < 2; session++ {
+		m := &mp4Muxer{path: path, numbers: &numbers, numbered: true}
+		for file := 0; file < 2; file++ {
+			err := m.createFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, filepath.Base(m.filePath()))
+			m.file.Close()
+			m.segment++
+		}
+	}
+	want := []string{"rec-0001.mp4", "rec-0002.mp4", "rec-0003.mp4", "rec-0004.mp4"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A single file is numbered from the second one, after a change of
+	// parameters :
+	numbers = fileNumbers{}
+	m := &mp4Muxer{path: path, numbers: &numbers}
+	for _, want := range []string{"rec.mp4", "rec-0002.mp4"} {
+		err := m.createFile()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := filepath.Base(m.filePath()); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+		if _, err := os.Stat(m.filePath()); err != nil {
+			t.Error(err)
+		}
+		m.file.Close()
+		m.segment++
+	}
+}
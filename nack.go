@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"strings"
+	"sync"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+	"github.com/pion/rtcp"
+)
+
+// largest gap for which retransmissions are requested; the packets of longer
+// bursts of loss are usually too old to be worth waiting for.
+const nackMaxGap = 64
+
+// nackMedias returns the indexes of the medias for which the SDP negotiates
+// Generic NACK feedback (RFC 4585), with an rtcp-fb attribute of type nack
+// and no parameter, for all or some payload types.
+func nackMedias(raw []byte) map[int]bool {
+	var ssd sdp.SessionDescription
+	if ssd.Unmarshal(raw) != nil {
+		return nil
+	}
+
+	medias := make(map[int]bool)
+	for i, md := range ssd.MediaDescriptions {
+		for _, attr := range md.Attributes {
+			if attr.Key != "rtcp-fb" {
+				continue
+			}
+			// the value is the payload type, or *, and the feedback type :
+			if fields := strings.Fields(attr.Value); len(fields) == 2 && strings.EqualFold(fields[1], "nack") {
+				medias[i] = true
+			}
+		}
+	}
+	return medias
+}
+
+// nackSender collects the sequence numbers found missing on a track, with
+// -send-nack, so that Generic NACKs are sent for them, and counts the
+// requested packets which arrive afterwards, late or retransmitted.
+type nackSender struct {
+	// SSRC identifying the client in the feedback.
+	ssrc uint32
+
+	mutex     sync.Mutex
+	pending   []uint16
+	requested *gapSet
+
+	sent      uint64
+	packets   uint64
+	recovered uint64
+}
+
+// newNACKSender allocates the sender of a track.
+func newNACKSender() *nackSender {
+	return &nackSender{
+		ssrc:      rand.Uint32(),
+		requested: newGapSet(gapWindow),
+	}
+}
+
+// add queues the request of a missing sequence number.
+func (s *nackSender) add(seq uint16) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending = append(s.pending, seq)
+}
+
+// fill records the reception of a packet which was missing, and counts it
+// when it was requested.
+func (s *nackSender) fill(seq uint16) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.requested.fill(seq) {
+		s.recovered++
+	}
+}
+
+// take returns the feedback requesting the queued sequence numbers of the
+// given media SSRC, or nil when none is queued.
+func (s *nackSender) take(mediaSSRC uint32) *rtcp.TransportLayerNack {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil
+	}
+	for _, seq := range s.pending {
+		s.requested.add(seq)
+	}
+	s.sent++
+	s.packets += uint64(len(s.pending))
+
+	nack := &rtcp.TransportLayerNack{
+		SenderSSRC: s.ssrc,
+		MediaSSRC:  mediaSSRC,
+		Nacks:      rtcp.NackPairsFromSequenceNumbers(s.pending),
+	}
+	s.pending = s.pending[:0]
+	return nack
+}
+
+// nackReport counts the feedback sent for a track with -send-nack.
+type nackReport struct {
+	Sent      uint64 `json:"sent"`
+	Requested uint64 `json:"requested_packets"`
+	Recovered uint64 `json:"recovered_packets"`
+}
+
+// report returns a snapshot of the counters.
+func (s *nackSender) report() *nackReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &nackReport{
+		Sent:      s.sent,
+		Requested: s.packets,
+		Recovered: s.recovered,
+	}
+}
+
+// setupNACKSenders requests the retransmission of lost packets on the
+// tracks which negotiate it in the SDP rawSDP, and whose RTCP is processed,
+// with -send-nack.
+func setupNACKSenders(tracks []*track, rawSDP []byte) {
+	feedback := nackMedias(rawSDP)
+	for _, t := range tracks {
+		switch {
+		case !t.setup:
+		case !feedback[t.index]:
+			log.Printf("WARNING: track %s doesn't negotiate Generic NACK feedback (a=rtcp-fb nack), "+
+				"no retransmission will be requested", t)
+		case !t.rtcp:
+			log.Printf("WARNING: RTCP of track %s is not processed, no retransmission will be requested", t)
+		default:
+			t.nack = newNACKSender()
+		}
+	}
+}
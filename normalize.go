@@ -0,0 +1,77 @@
+package main
+
+// number of frames whose timestamp is remembered by a timestampNormalizer.
+const normalizerFrameWindow = 64
+
+// timestampNormalizer numbers the packets and frames of a track from zero,
+// in order of reception, so that captures of the same content give the same
+// output regardless of the random initial values chosen by the server and
+// of the time of the capture. A new frame starts whenever the RTP timestamp
+// changes, unless it is the timestamp of one of the last frames: a late
+// packet gets the index of its frame, without shifting the following ones.
+// It is not safe for concurrent use: it must only be fed by the packet
+// callback of its track.
+type timestampNormalizer struct {
+	started bool
+	last    uint32
+	frame   uint32
+	packets uint64
+
+	// index of the last frames by timestamp, and their timestamps in
+	// order, to forget the oldest one.
+	frames map[uint32]uint32
+	recent [normalizerFrameWindow]uint32
+}
+
+// push returns the frame and packet indexes of a packet.
+func (n *timestampNormalizer) push(ts uint32) (uint32, uint64) {
+	switch {
+	case !n.started:
+		n.started = true
+		n.frames = map[uint32]uint32{ts: 0}
+		n.recent[0] = ts
+		n.last = ts
+	case ts != n.last:
+		if frame, ok := n.frames[ts]; ok {
+			packet := n.packets
+			n.packets++
+			return frame, packet
+		}
+		n.frame++
+		slot := n.frame % normalizerFrameWindow
+		if n.frame >= normalizerFrameWindow {
+			delete(n.frames, n.recent[slot])
+		}
+		n.frames[ts] = n.frame
+		n.recent[slot] = ts
+		n.last = ts
+	}
+
+	packet := n.packets
+	n.packets++
+	return n.frame, packet
+}
+
+// normalizeRecord replaces the timestamps of a record with the given indexes, and
+// drops its wall-clock times. With keepOriginal, the original RTP timestamp
+// and wall-clock times are kept in dedicated fields.
+func normalizeRecord(rec *PacketRecord, frame uint32, packet uint64, keepOriginal bool) {
+	if keepOriginal {
+		original := rec.Timestamp
+		rec.OriginalTimestamp = &original
+		rec.OriginalMediaClockTime = rec.MediaClockTime
+		rec.OriginalEpochTime = rec.EpochTime
+	}
+	rec.Timestamp = frame
+	rec.PacketIndex = &packet
+	rec.MediaClockTime = nil
+	rec.EpochTime = nil
+}
+
+// setupNormalizers numbers the frames and packets of every track, with
+// -normalize-timestamps.
+func setupNormalizers(tracks []*track) {
+	for _, t := range tracks {
+		t.normalizer = &timestampNormalizer{}
+	}
+}
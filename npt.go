@@ -0,0 +1,141 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/headers"
+)
+
+// tsUnwrapper extends 32-bit RTP timestamps into a monotonic 64-bit timeline,
+// so that streams longer than the RTP timestamp range are handled.
+type tsUnwrapper struct {
+	initialized bool
+	last        uint32
+	value       int64
+}
+
+// unwrap returns the extended value of the given RTP timestamp. The first
+// timestamp is extended to itself; following ones are placed relative to
+// the previous one, assuming they are less than 2^31 ticks apart.
+func (u *tsUnwrapper) unwrap(ts uint32) int64 {
+	if !u.initialized {
+		u.initialized = true
+		u.value = int64(ts)
+	} else {
+		u.value += int64(int32(ts - u.last))
+	}
+	u.last = ts
+	return u.value
+}
+
+// nptClock converts the RTP timestamps of a track into normal play time (NPT),
+// anchored by the RTP-Info header of the PLAY response: the timestamp given
+// in RTP-Info corresponds to the start of the range being played.
+// It is not safe for concurrent use: it must only be fed by the packet
+// callback of its track.
+type nptClock struct {
+	start     time.Duration
+	clockRate int64
+	anchor    int64
+	ts        tsUnwrapper
+}
+
+// newNPTClock creates a clock whose RTP timestamp anchor corresponds to start.
+func newNPTClock(start time.Duration, clockRate int, anchor uint32) *nptClock {
+	c := &nptClock{
+		start:     start,
+		clockRate: int64(clockRate),
+	}
+	c.anchor = c.ts.unwrap(anchor)
+	return c
+}
+
+// npt returns the normal play time of the given RTP timestamp.
+func (c *nptClock) npt(ts uint32) time.Duration {
+	elapsed := c.ts.unwrap(ts) - c.anchor
+	return c.start + ticksToDuration(elapsed, c.clockRate)
+}
+
+// ticksToDuration converts a number of ticks of the given clock rate into a
+// duration, without overflowing on long streams.
+func ticksToDuration(ticks int64, clockRate int64) time.Duration {
+	secs := ticks / clockRate
+	rem := ticks % clockRate
+	return time.Duration(secs)*time.Second + time.Duration(rem*int64(time.Second)/clockRate)
+}
+
+// playRangeStart returns the start of the range returned in the PLAY response.
+// Live streams usually omit it, in which case playing starts from zero.
+func playRangeStart(res *base.Response) time.Duration {
+	var ra headers.Range
+	if ra.Unmarshal(res.Header["Range"]) != nil {
+		return 0
+	}
+	if npt, ok := ra.Value.(*headers.RangeNPT); ok {
+		return npt.Start
+	}
+	return 0
+}
+
+// anchorNPT sets the NPT clock of every SETUP track from the RTP-Info header
+// of the PLAY response. Tracks without a matching RTP-Info entry, or without
+// a timestamp in it, are left without NPT.
+func anchorNPT(res *base.Response, baseURL *base.URL, tracks []*track) {
+	var info headers.RTPInfo
+	if res == nil || info.Unmarshal(res.Header["RTP-Info"]) != nil {
+		log.Println("PLAY response carries no RTP-Info header, NPT is not available")
+		return
+	}
+
+	start := playRangeStart(res)
+
+	var setupTracks []*track
+	for _, t := range tracks {
+		if t.setup {
+			setupTracks = append(setupTracks, t)
+		}
+	}
+
+	for i, t := range setupTracks {
+		entry := rtpInfoEntryOf(info, baseURL, t)
+
+		// Fall back on positional matching when URLs can't be matched :
+		if entry == nil && len(info) == len(setupTracks) {
+			entry = info[i]
+		}
+
+		if entry == nil || entry.Timestamp == nil {
+			log.Printf("No RTP-Info timestamp for track %s, NPT is not available", t)
+			continue
+		}
+
+		clockRate := t.media.Formats[0].ClockRate()
+		if clockRate <= 0 {
+			continue
+		}
+
+		t.npt.Store(newNPTClock(start, clockRate, *entry.Timestamp))
+	}
+}
+
+// rtpInfoEntryOf returns the RTP-Info entry whose URL points to the track.
+func rtpInfoEntryOf(info headers.RTPInfo, baseURL *base.URL, t *track) *headers.RTPInfoEntry {
+	var trackURL string
+	if u, err := t.media.URL(baseURL); err == nil {
+		trackURL = u.String()
+	}
+
+	for _, entry := range info {
+		switch {
+		case trackURL != "" && entry.URL == trackURL:
+			return entry
+		case t.media.Control != "" && (entry.URL == t.media.Control ||
+			strings.HasSuffix(entry.URL, "/"+t.media.Control)):
+			return entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/pion/rtp"
+)
+
+// outputs are the outputs of a session: the packet records (log, NDJSON or
+// protobuf file), and the outputs of the RTP packets, of the access units
+// reassembled by the depacketizers and of the documents of metadata tracks,
+// each enabled by its option. Every output is written from its own queue,
+// never from the packet callback. Each one is opened by a method of the
+// file of its feature, which registers its writers and closers here.
+type outputs struct {
+	cfg *config
+	// absolute time of the packets of a media from the RTCP sender reports,
+	// the PacketNTP method of the client :
+	packetNTP func(*description.Media, *rtp.Packet) (time.Time, bool)
+
+	sink      *fanoutSink
+	rtp       []func(t *track, pkt *rtp.Packet)
+	units     []func(au *accessUnit)
+	documents []func(t *track, doc []byte, ntp time.Time)
+	// the documents are written into the -metadata-out file instead of
+	// the packet records :
+	documentsOut bool
+
+	// MP4 recording, whose changes of format may end the session :
+	mp4 *mp4Muxer
+	// WebVTT captions, which need the absolute time of the packets :
+	webvtt *webvttWriter
+	// applies -on-decode-error, and dumps the packets with -decode-error-dump :
+	decodeErrors *decodeErrorHandler
+	decodeDump   func(t *track, pkt *rtp.Packet, err error)
+
+	queues  []*writeQueue
+	closers []func()
+}
+
+// openOutputs opens the outputs enabled by the options, for the given
+// tracks. On error, the outputs already opened are closed.
+func openOutputs(cfg *config, tracks []*track,
+	packetNTP func(*description.Media, *rtp.Packet) (time.Time, bool),
+) (*outputs, error) {
+	o := &outputs{
+		cfg:          cfg,
+		packetNTP:    packetNTP,
+		sink:         &fanoutSink{},
+		decodeErrors: newDecodeErrorHandler(cfg.onDecodeError),
+	}
+
+	openers := []struct {
+		enabled bool
+		open    func([]*track) error
+	}{
+		{cfg.ndjsonOut != "", o.openPacketFile},
+		{cfg.logPackets && !cfg.summaryOnly, o.openPacketLog},
+		{cfg.mp4Out != "", o.openMP4},
+		{cfg.cmafOut != "", o.openCMAF},
+		{cfg.traceFrames != "", o.openFrameTrace},
+		{cfg.frameTimelineOut != "", o.openFrameTimeline},
+		{cfg.checksumOut != "", o.openChecksums},
+		{cfg.grpcAddr != "", o.openGRPC},
+		{cfg.seiOut != "", o.openSEI},
+		{cfg.decodeErrorDump != "", o.openDecodeErrorDump},
+		{cfg.metadataOut != "", o.openMetadata},
+		{cfg.webvttOut != "", o.openWebVTT},
+		{cfg.pcapngOut != "", o.openPCAPNG},
+		{cfg.forwardUDP.isSet(), o.openUDPForward},
+		{cfg.rawPayloadOut != "", o.openRawPayload},
+	}
+	for _, opener := range openers {
+		if !opener.enabled {
+			continue
+		}
+		err := opener.open(tracks)
+		if err != nil {
+			o.close()
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// newQueue creates the write queue of an output, reported at the end of
+// the session.
+func (o *outputs) newQueue(name string) *writeQueue {
+	q := newWriteQueue(name, o.cfg.sinkBuffer, o.cfg.writeOverflow)
+	o.queues = append(o.queues, q)
+	return q
+}
+
+// onClose registers a function closing an output, once its queue is
+// drained. The functions are called in the reverse order.
+func (o *outputs) onClose(f func()) {
+	o.closers = append(o.closers, f)
+}
+
+// onRTP registers an output of the RTP packets.
+func (o *outputs) onRTP(f func(t *track, pkt *rtp.Packet)) {
+	o.rtp = append(o.rtp, f)
+}
+
+// onAccessUnit registers an output of the access units.
+func (o *outputs) onAccessUnit(f func(au *accessUnit)) {
+	o.units = append(o.units, f)
+}
+
+// onDocument registers an output of the documents of metadata tracks.
+func (o *outputs) onDocument(f func(t *track, doc []byte, ntp time.Time)) {
+	o.documents = append(o.documents, f)
+}
+
+// needsNTP returns whether the outputs of a track need the absolute time of
+// its packets.
+func (o *outputs) needsNTP(t *track) bool {
+	return t.depacketizer != nil || o.webvtt != nil
+}
+
+// deliver passes a packet of t to the outputs, once counted. The packet is
+// shared by every output: the depacketizer keeps references to its payload
+// across packets, and the outputs written by queues (raw payloads, packet
+// records, MP4 samples) read it after the callback returns. None of them
+// may modify it. Packets are thinned out with -sample-every only after all
+// of the outputs but the packet records got them.
+func (o *outputs) deliver(t *track, p orderedPacket) {
+	pkt := p.pkt
+
+	// Number every packet, including the ones thinned out below :
+	var frame uint32
+	var packet uint64
+	if t.normalizer != nil {
+		frame, packet = t.normalizer.push(pkt.Timestamp)
+	}
+
+	// Drop packets at random with -simulate-loss, once counted and
+	// numbered, so that the outputs see the gaps :
+	if t.loss != nil && t.loss.drop() {
+		return
+	}
+
+	o.writeRTP(t, pkt)
+
+	ntp := t.packetTime(pkt, p.forma.ClockRate(), o.needsNTP(t), o.packetNTP)
+	// The WebVTT timeline starts with the first mapped packet :
+	if o.webvtt != nil && !ntp.IsZero() {
+		o.webvtt.start(ntp)
+	}
+
+	// Reassemble access units for the outputs that need them :
+	if t.depacketizer != nil {
+		aus, err := t.depacketizer.push(pkt, ntp)
+		if err != nil {
+			o.onDecodeError(t, pkt, err)
+		}
+		for _, au := range aus {
+			if t.gop != nil {
+				t.gop.push(au)
+			}
+			o.writeAccessUnit(au)
+		}
+	}
+
+	// Reassemble metadata documents, fragmented across packets. The packet
+	// records carry them, unless they are written into a file :
+	var doc []byte
+	if t.metadata != nil {
+		var err error
+		doc, err = t.metadata.push(pkt)
+		if err != nil {
+			log.Printf("WARNING: track %s: %v", t, err)
+		}
+		if doc != nil && o.writeDocument(t, doc, ntp) {
+			doc = nil
+		}
+	}
+
+	// Thin the output of busy tracks, starting from their first packet,
+	// without dropping metadata documents :
+	if doc == nil && !p.retransmitted && (p.n-1)%uint64(o.cfg.sampleEvery) != 0 {
+		return
+	}
+
+	rec := packetRecordOf(t, p, ntp, doc)
+	if t.normalizer != nil {
+		normalizeRecord(rec, frame, packet, o.cfg.keepOriginalTimestamps)
+	}
+	o.sink.writePacket(t, rec)
+}
+
+// writeRTP passes a packet to the outputs of the RTP packets.
+func (o *outputs) writeRTP(t *track, pkt *rtp.Packet) {
+	for _, write := range o.rtp {
+		write(t, pkt)
+	}
+}
+
+// writeAccessUnit passes an access unit to the outputs of the access units.
+func (o *outputs) writeAccessUnit(au *accessUnit) {
+	for _, write := range o.units {
+		write(au)
+	}
+}
+
+// writeDocument passes a document of a metadata track to the outputs of the
+// documents. It returns whether the document was written into the
+// -metadata-out file, and must then be left out of the packet record.
+func (o *outputs) writeDocument(t *track, doc []byte, ntp time.Time) bool {
+	for _, write := range o.documents {
+		write(t, doc, ntp)
+	}
+	return o.documentsOut
+}
+
+// onDecodeError handles an error of the depacketizer of a track, on pkt.
+func (o *outputs) onDecodeError(t *track, pkt *rtp.Packet, err error) {
+	o.decodeErrors.handle(t, err)
+	if o.decodeDump != nil {
+		o.decodeDump(t, pkt, err)
+	}
+}
+
+// formatChanged returns a channel closed when the MP4 recording stops for a
+// change of format, with -on-format-change fail, or nil.
+func (o *outputs) formatChanged() <-chan struct{} {
+	if o.mp4 == nil || o.cfg.onFormatChange != formatChangeFail {
+		return nil
+	}
+	return o.mp4.formatChanged
+}
+
+// drain writes the queued packet records and writes. It can be called
+// several times.
+func (o *outputs) drain() {
+	o.sink.close()
+	for _, q := range o.queues {
+		q.close()
+	}
+}
+
+// reports returns the outcome of the queues of the outputs.
+func (o *outputs) reports() []outputReport {
+	var reports []outputReport
+	for _, q := range o.sink.sinks {
+		reports = append(reports, q.report())
+	}
+	for _, q := range o.queues {
+		reports = append(reports, q.report())
+	}
+	return reports
+}
+
+// close drains the outputs, and closes them.
+func (o *outputs) close() {
+	o.drain()
+	for i := len(o.closers) - 1; i >= 0; i-- {
+		o.closers[i]()
+	}
+}
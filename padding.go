@@ -0,0 +1,133 @@
+package main
+
+import (
+	"log"
+	"math"
+
+	"github.com/pion/rtp"
+)
+
+// minimum number of packets of a track before padding on all of them is
+// reported as an anomaly.
+const paddingMinPackets = 100
+
+// largest block size to which the padded packets are checked to be aligned.
+const paddingMaxAlignment = 64
+
+// Anomalies of the padding of a track, as they appear in the final report :
+const (
+	// every packet is padded, which unencrypted media has no reason to do.
+	paddingAnomalyEveryPacket = "every_packet"
+	// the padding bit is set with a padding count of zero, which counts
+	// itself and is at least one (RFC 3550, section 5.1).
+	paddingAnomalyZeroCount = "zero_count"
+)
+
+// paddingStats counts the padded RTP packets of a track and their padding
+// bytes. Padding aligns the packets to the block size of a cipher, or
+// pads them to a constant bitrate; the size to which the padded packets are
+// aligned tells which.
+type paddingStats struct {
+	packets   uint64
+	bytes     uint64
+	min       uint8
+	max       uint8
+	zeroCount uint64
+	// largest power of two, up to paddingMaxAlignment, dividing the size of
+	// every padded packet.
+	alignment int
+}
+
+// push records a packet, if padded.
+func (s *paddingStats) push(pkt *rtp.Packet) {
+	if !pkt.Padding {
+		return
+	}
+	if pkt.PaddingSize == 0 {
+		s.zeroCount++
+		return
+	}
+
+	if s.packets == 0 || pkt.PaddingSize < s.min {
+		s.min = pkt.PaddingSize
+	}
+	s.max = max(s.max, pkt.PaddingSize)
+	s.packets++
+	s.bytes += uint64(pkt.PaddingSize)
+
+	size := pkt.MarshalSize()
+	if s.packets == 1 {
+		s.alignment = paddingMaxAlignment
+	}
+	for s.alignment > 1 && size%s.alignment != 0 {
+		s.alignment /= 2
+	}
+}
+
+// paddingReport describes the padding of the packets of a track.
+type paddingReport struct {
+	Packets  uint64  `json:"packets"`
+	Ratio    float64 `json:"ratio"`
+	Bytes    uint64  `json:"bytes"`
+	MinBytes uint8   `json:"min_bytes"`
+	MaxBytes uint8   `json:"max_bytes"`
+	// block size to which every padded packet is aligned, when more than 1.
+	AlignedTo int `json:"aligned_to,omitempty"`
+	// packets whose padding bit is set with a padding count of zero.
+	ZeroCount uint64   `json:"zero_count,omitempty"`
+	Anomalies []string `json:"anomalies,omitempty"`
+}
+
+// report returns the padding of the track, out of total packets, or nil when
+// no packet was padded. The caller must hold the mutex of the track.
+func (s *paddingStats) report(total uint64, encrypted bool) *paddingReport {
+	if s.packets == 0 && s.zeroCount == 0 {
+		return nil
+	}
+
+	r := &paddingReport{
+		Packets:   s.packets,
+		Bytes:     s.bytes,
+		MinBytes:  s.min,
+		MaxBytes:  s.max,
+		ZeroCount: s.zeroCount,
+	}
+	if total != 0 {
+		r.Ratio = math.Round(float64(s.packets+s.zeroCount)/float64(total)*1000) / 1000
+	}
+	if s.alignment > 1 {
+		r.AlignedTo = s.alignment
+	}
+	if !encrypted && total >= paddingMinPackets && s.packets+s.zeroCount == total {
+		r.Anomalies = append(r.Anomalies, paddingAnomalyEveryPacket)
+	}
+	if s.zeroCount != 0 {
+		r.Anomalies = append(r.Anomalies, paddingAnomalyZeroCount)
+	}
+	return r
+}
+
+// checkPadding warns about the anomalies of the padding of the tracks.
+func checkPadding(tracks []trackReport) {
+	for _, t := range tracks {
+		if t.Padding == nil {
+			continue
+		}
+		for _, anomaly := range t.Padding.Anomalies {
+			switch anomaly {
+			case paddingAnomalyEveryPacket:
+				msg := "WARNING: every packet of track #%d is padded, which unencrypted media doesn't need"
+				// Padding of varying length to aligned sizes is the mark of a
+				// block cipher, while constant sizes are aligned by chance :
+				if t.Padding.AlignedTo > 1 && t.Padding.MinBytes != t.Padding.MaxBytes {
+					log.Printf(msg+": the packets are aligned to %d bytes, as by a block cipher", t.Index, t.Padding.AlignedTo)
+				} else {
+					log.Printf(msg, t.Index)
+				}
+			case paddingAnomalyZeroCount:
+				log.Printf("WARNING: %d packets of track #%d have the padding bit set with a padding count of zero",
+					t.Padding.ZeroCount, t.Index)
+			}
+		}
+	}
+}
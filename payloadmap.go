@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+)
+
+// payloadMapCodecs are the encoding names accepted by -payload-map, which
+// are the ones that can be depacketized.
+var payloadMapCodecs = map[string]string{
+	"h264":          "H264",
+	"h265":          "H265",
+	"mpeg4-generic": "MPEG4-GENERIC",
+}
+
+// payloadMapFlag is a flag holding the rtpmap to use for some dynamic
+// payload types, in the PT=codec/clockrate[/channels] form.
+type payloadMapFlag map[uint8]string
+
+// String implements flag.Value.
+func (m *payloadMapFlag) String() string {
+	pts := make([]int, 0, len(*m))
+	for pt := range *m {
+		pts = append(pts, int(pt))
+	}
+	sort.Ints(pts)
+
+	entries := make([]string, len(pts))
+	for i, pt := range pts {
+		entries[i] = strconv.Itoa(pt) + "=" + (*m)[uint8(pt)]
+	}
+	return strings.Join(entries, ",")
+}
+
+// Set implements flag.Value. It can be given several times, or with a
+// comma-separated list.
+func (m *payloadMapFlag) Set(s string) error {
+	if *m == nil {
+		*m = make(payloadMapFlag)
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		ptStr, rtpMap, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return fmt.Errorf("invalid payload mapping %q: expected PT=codec/clockrate", entry)
+		}
+
+		pt, err := strconv.ParseUint(ptStr, 10, 8)
+		if err != nil || pt < 96 || pt > 127 {
+			return fmt.Errorf("invalid payload type %q: dynamic payload types are 96 to 127", ptStr)
+		}
+
+		parts := strings.Split(rtpMap, "/")
+		if len(parts) < 2 || len(parts) > 3 {
+			return fmt.Errorf("invalid payload mapping %q: expected PT=codec/clockrate", entry)
+		}
+
+		codec, ok := payloadMapCodecs[strings.ToLower(parts[0])]
+		if !ok {
+			return fmt.Errorf("unsupported codec %q: supported codecs are H264, H265 and MPEG4-GENERIC", parts[0])
+		}
+		parts[0] = codec
+
+		for _, p := range parts[1:] {
+			v, err := strconv.ParseUint(p, 10, 31)
+			if err != nil || v == 0 {
+				return fmt.Errorf("invalid payload mapping %q: bad clock rate or channel count %q", entry, p)
+			}
+		}
+
+		(*m)[uint8(pt)] = strings.Join(parts, "/")
+	}
+	return nil
+}
+
+// applyPayloadMap rewrites the rtpmap attributes of the mapped payload types
+// in the SDP of a DESCRIBE response, and decodes the result. Overrides are
+// logged, once per media using them.
+func applyPayloadMap(res *base.Response, u *base.URL, m payloadMapFlag) (*description.Session, []byte, error) {
+	var out bytes.Buffer
+	var formats map[uint8]bool
+	media := -1
+
+	for _, line := range strings.SplitAfter(string(res.Body), "\n") {
+		content := strings.TrimRight(line, "\r\n")
+		if content == "" {
+			continue
+		}
+
+		// Drop the original rtpmap of the mapped payload types :
+		if rest, ok := strings.CutPrefix(content, "a=rtpmap:"); ok && media >= 0 {
+			ptStr, _, _ := strings.Cut(rest, " ")
+			if pt, err := strconv.ParseUint(ptStr, 10, 8); err == nil && formats[uint8(pt)] {
+				continue
+			}
+		}
+
+		out.WriteString(content + "\r\n")
+
+		mediaLine, ok := strings.CutPrefix(content, "m=")
+		if !ok {
+			continue
+		}
+
+		// Insert the mapped rtpmaps right after the media line :
+		media++
+		formats = make(map[uint8]bool)
+		fields := strings.Fields(mediaLine)
+		for i := 3; i < len(fields); i++ {
+			pt, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				continue
+			}
+			rtpMap, ok := m[uint8(pt)]
+			if !ok {
+				continue
+			}
+			formats[uint8(pt)] = true
+			fmt.Fprintf(&out, "a=rtpmap:%d %s\r\n", pt, rtpMap)
+			log.Printf("Payload type %d of media #%d interpreted as %s (-payload-map)", pt, media, rtpMap)
+		}
+	}
+
+	raw := out.Bytes()
+
+	var ssd sdp.SessionDescription
+	err := ssd.Unmarshal(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var desc description.Session
+	err = desc.Unmarshal(&ssd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desc.BaseURL, err = describeBaseURL(&ssd, res, u)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &desc, raw, nil
+}
+
+// describeBaseURL returns the base URL of a DESCRIBE response, in the same
+// way as the client: the session control attribute, then the Content-Base
+// and Content-Location headers, then the request URL.
+func describeBaseURL(ssd *sdp.SessionDescription, res *base.Response, u *base.URL) (*base.URL, error) {
+	if control, ok := ssd.Attribute("control"); ok && control != "*" {
+		ret, err := base.ParseURL(control)
+		if err != nil {
+			return nil, fmt.Errorf("invalid control attribute: '%v'", control)
+		}
+		ret.User = u.User
+		return ret, nil
+	}
+
+	for _, key := range []string{"Content-Base", "Content-Location"} {
+		v, ok := res.Header[key]
+		if !ok {
+			continue
+		}
+		if len(v) != 1 {
+			return nil, fmt.Errorf("invalid %s: '%v'", key, v)
+		}
+
+		// Relative paths are resolved against the server of the request :
+		str := v[0]
+		if strings.HasPrefix(str, "/") {
+			str = u.Scheme + "://" + u.Host + str
+		}
+
+		ret, err := base.ParseURL(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: '%v'", key, v)
+		}
+		ret.User = u.User
+		return ret, nil
+	}
+
+	return u, nil
+}
@@ -0,0 +1,460 @@
+// Schema of the packets written into -ndjson-out with -out-format protobuf.
+// The file is a stream of PacketRecord messages, each one preceded by its
+// size as a varint, as written by writeDelimitedTo in Java and C++, and read
+// by protodelim.UnmarshalFrom in Go.
+//
+// The Go code of the pb package is generated from this schema, with
+// go generate (see protobuf.go); fields must keep their numbers when the
+// schema changes.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.4
+// 	protoc        (unknown)
+// source: packet.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Header extension of an RTP packet (RFC 8285).
+type Extension struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Payload       []byte                 `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Extension) Reset() {
+	*x = Extension{}
+	mi := &file_packet_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Extension) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Extension) ProtoMessage() {}
+
+func (x *Extension) ProtoReflect() protoreflect.Message {
+	mi := &file_packet_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Extension.ProtoReflect.Descriptor instead.
+func (*Extension) Descriptor() ([]byte, []int) {
+	return file_packet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Extension) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Extension) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+// RTP packet, with the fields of the JSON output. Times are in nanoseconds
+// since the Unix epoch.
+type PacketRecord struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Track            uint32                 `protobuf:"varint,1,opt,name=track,proto3" json:"track,omitempty"`
+	TrackName        string                 `protobuf:"bytes,2,opt,name=track_name,json=trackName,proto3" json:"track_name,omitempty"`
+	Version          uint32                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	SequenceNumber   uint32                 `protobuf:"varint,4,opt,name=sequence_number,json=sequenceNumber,proto3" json:"sequence_number,omitempty"`
+	Timestamp        uint32                 `protobuf:"varint,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Extension        bool                   `protobuf:"varint,6,opt,name=extension,proto3" json:"extension,omitempty"`
+	Padding          bool                   `protobuf:"varint,7,opt,name=padding,proto3" json:"padding,omitempty"`
+	Marker           bool                   `protobuf:"varint,8,opt,name=marker,proto3" json:"marker,omitempty"`
+	PayloadType      uint32                 `protobuf:"varint,9,opt,name=payload_type,json=payloadType,proto3" json:"payload_type,omitempty"`
+	Ssrc             uint32                 `protobuf:"fixed32,10,opt,name=ssrc,proto3" json:"ssrc,omitempty"`
+	Csrc             []uint32               `protobuf:"fixed32,11,rep,packed,name=csrc,proto3" json:"csrc,omitempty"`
+	Extensions       []*Extension           `protobuf:"bytes,12,rep,name=extensions,proto3" json:"extensions,omitempty"`
+	ExtensionProfile uint32                 `protobuf:"varint,13,opt,name=extension_profile,json=extensionProfile,proto3" json:"extension_profile,omitempty"`
+	// Normal play time, when the server sent RTP-Info.
+	NptSeconds *float64 `protobuf:"fixed64,14,opt,name=npt_seconds,json=nptSeconds,proto3,oneof" json:"npt_seconds,omitempty"`
+	// Absolute time from the media clock declared in the SDP (RFC 7273).
+	MediaClockTimeUnixNano *int64 `protobuf:"varint,15,opt,name=media_clock_time_unix_nano,json=mediaClockTimeUnixNano,proto3,oneof" json:"media_clock_time_unix_nano,omitempty"`
+	// Absolute time from the epoch given with -rtp-epoch.
+	EpochTimeUnixNano *int64 `protobuf:"varint,16,opt,name=epoch_time_unix_nano,json=epochTimeUnixNano,proto3,oneof" json:"epoch_time_unix_nano,omitempty"`
+	// With -normalize-timestamps: index of the packet in the track, and the
+	// original values with -keep-original-timestamps.
+	PacketIndex                    *uint64 `protobuf:"varint,17,opt,name=packet_index,json=packetIndex,proto3,oneof" json:"packet_index,omitempty"`
+	OriginalTimestamp              *uint32 `protobuf:"varint,18,opt,name=original_timestamp,json=originalTimestamp,proto3,oneof" json:"original_timestamp,omitempty"`
+	OriginalMediaClockTimeUnixNano *int64  `protobuf:"varint,19,opt,name=original_media_clock_time_unix_nano,json=originalMediaClockTimeUnixNano,proto3,oneof" json:"original_media_clock_time_unix_nano,omitempty"`
+	// Interleaved channel, when streaming over TCP.
+	Channel *uint32 `protobuf:"varint,20,opt,name=channel,proto3,oneof" json:"channel,omitempty"`
+	// Whether the packet was restored from an RTX retransmission.
+	Retransmitted bool `protobuf:"varint,21,opt,name=retransmitted,proto3" json:"retransmitted,omitempty"`
+	// Metadata document completed by the packet, on ONVIF metadata tracks.
+	Metadata string `protobuf:"bytes,22,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// Encoding of the track, e.g. speex/16000, when the program doesn't know
+	// its codec.
+	Rtpmap string `protobuf:"bytes,23,opt,name=rtpmap,proto3" json:"rtpmap,omitempty"`
+	// With -normalize-timestamps and -keep-original-timestamps: original
+	// value of epoch_time_unix_nano.
+	OriginalEpochTimeUnixNano *int64 `protobuf:"varint,24,opt,name=original_epoch_time_unix_nano,json=originalEpochTimeUnixNano,proto3,oneof" json:"original_epoch_time_unix_nano,omitempty"`
+	unknownFields             protoimpl.UnknownFields
+	sizeCache                 protoimpl.SizeCache
+}
+
+func (x *PacketRecord) Reset() {
+	*x = PacketRecord{}
+	mi := &file_packet_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PacketRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PacketRecord) ProtoMessage() {}
+
+func (x *PacketRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_packet_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PacketRecord.ProtoReflect.Descriptor instead.
+func (*PacketRecord) Descriptor() ([]byte, []int) {
+	return file_packet_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PacketRecord) GetTrack() uint32 {
+	if x != nil {
+		return x.Track
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetTrackName() string {
+	if x != nil {
+		return x.TrackName
+	}
+	return ""
+}
+
+func (x *PacketRecord) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetSequenceNumber() uint32 {
+	if x != nil {
+		return x.SequenceNumber
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetTimestamp() uint32 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetExtension() bool {
+	if x != nil {
+		return x.Extension
+	}
+	return false
+}
+
+func (x *PacketRecord) GetPadding() bool {
+	if x != nil {
+		return x.Padding
+	}
+	return false
+}
+
+func (x *PacketRecord) GetMarker() bool {
+	if x != nil {
+		return x.Marker
+	}
+	return false
+}
+
+func (x *PacketRecord) GetPayloadType() uint32 {
+	if x != nil {
+		return x.PayloadType
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetSsrc() uint32 {
+	if x != nil {
+		return x.Ssrc
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetCsrc() []uint32 {
+	if x != nil {
+		return x.Csrc
+	}
+	return nil
+}
+
+func (x *PacketRecord) GetExtensions() []*Extension {
+	if x != nil {
+		return x.Extensions
+	}
+	return nil
+}
+
+func (x *PacketRecord) GetExtensionProfile() uint32 {
+	if x != nil {
+		return x.ExtensionProfile
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetNptSeconds() float64 {
+	if x != nil && x.NptSeconds != nil {
+		return *x.NptSeconds
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetMediaClockTimeUnixNano() int64 {
+	if x != nil && x.MediaClockTimeUnixNano != nil {
+		return *x.MediaClockTimeUnixNano
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetEpochTimeUnixNano() int64 {
+	if x != nil && x.EpochTimeUnixNano != nil {
+		return *x.EpochTimeUnixNano
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetPacketIndex() uint64 {
+	if x != nil && x.PacketIndex != nil {
+		return *x.PacketIndex
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetOriginalTimestamp() uint32 {
+	if x != nil && x.OriginalTimestamp != nil {
+		return *x.OriginalTimestamp
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetOriginalMediaClockTimeUnixNano() int64 {
+	if x != nil && x.OriginalMediaClockTimeUnixNano != nil {
+		return *x.OriginalMediaClockTimeUnixNano
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetChannel() uint32 {
+	if x != nil && x.Channel != nil {
+		return *x.Channel
+	}
+	return 0
+}
+
+func (x *PacketRecord) GetRetransmitted() bool {
+	if x != nil {
+		return x.Retransmitted
+	}
+	return false
+}
+
+func (x *PacketRecord) GetMetadata() string {
+	if x != nil {
+		return x.Metadata
+	}
+	return ""
+}
+
+func (x *PacketRecord) GetRtpmap() string {
+	if x != nil {
+		return x.Rtpmap
+	}
+	return ""
+}
+
+func (x *PacketRecord) GetOriginalEpochTimeUnixNano() int64 {
+	if x != nil && x.OriginalEpochTimeUnixNano != nil {
+		return *x.OriginalEpochTimeUnixNano
+	}
+	return 0
+}
+
+var File_packet_proto protoreflect.FileDescriptor
+
+var file_packet_proto_rawDesc = string([]byte{
+	0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x08,
+	0x72, 0x74, 0x73, 0x70, 0x6d, 0x65, 0x74, 0x61, 0x22, 0x35, 0x0a, 0x09, 0x45, 0x78, 0x74, 0x65,
+	0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x02, 0x69, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x22,
+	0xf2, 0x08, 0x0a, 0x0c, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64,
+	0x12, 0x14, 0x0a, 0x05, 0x74, 0x72, 0x61, 0x63, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x05, 0x74, 0x72, 0x61, 0x63, 0x6b, 0x12, 0x1d, 0x0a, 0x0a, 0x74, 0x72, 0x61, 0x63, 0x6b, 0x5f,
+	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x72, 0x61, 0x63,
+	0x6b, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x27, 0x0a, 0x0f, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x5f, 0x6e, 0x75, 0x6d, 0x62,
+	0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x78, 0x74, 0x65, 0x6e, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x65, 0x78, 0x74, 0x65, 0x6e,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x16,
+	0x0a, 0x06, 0x6d, 0x61, 0x72, 0x6b, 0x65, 0x72, 0x18, 0x08, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06,
+	0x6d, 0x61, 0x72, 0x6b, 0x65, 0x72, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61,
+	0x64, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0b, 0x70, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x73, 0x72,
+	0x63, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x07, 0x52, 0x04, 0x73, 0x73, 0x72, 0x63, 0x12, 0x12, 0x0a,
+	0x04, 0x63, 0x73, 0x72, 0x63, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x07, 0x52, 0x04, 0x63, 0x73, 0x72,
+	0x63, 0x12, 0x33, 0x0a, 0x0a, 0x65, 0x78, 0x74, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18,
+	0x0c, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x72, 0x74, 0x73, 0x70, 0x6d, 0x65, 0x74, 0x61,
+	0x2e, 0x45, 0x78, 0x74, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x0a, 0x65, 0x78, 0x74, 0x65,
+	0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x2b, 0x0a, 0x11, 0x65, 0x78, 0x74, 0x65, 0x6e, 0x73,
+	0x69, 0x6f, 0x6e, 0x5f, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x10, 0x65, 0x78, 0x74, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x50, 0x72, 0x6f, 0x66,
+	0x69, 0x6c, 0x65, 0x12, 0x24, 0x0a, 0x0b, 0x6e, 0x70, 0x74, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e,
+	0x64, 0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0a, 0x6e, 0x70, 0x74, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x88, 0x01, 0x01, 0x12, 0x3f, 0x0a, 0x1a, 0x6d, 0x65, 0x64,
+	0x69, 0x61, 0x5f, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e,
+	0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x03, 0x48, 0x01, 0x52,
+	0x16, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x54, 0x69, 0x6d, 0x65, 0x55,
+	0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x88, 0x01, 0x01, 0x12, 0x34, 0x0a, 0x14, 0x65, 0x70,
+	0x6f, 0x63, 0x68, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61,
+	0x6e, 0x6f, 0x18, 0x10, 0x20, 0x01, 0x28, 0x03, 0x48, 0x02, 0x52, 0x11, 0x65, 0x70, 0x6f, 0x63,
+	0x68, 0x54, 0x69, 0x6d, 0x65, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x88, 0x01, 0x01,
+	0x12, 0x26, 0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78,
+	0x18, 0x11, 0x20, 0x01, 0x28, 0x04, 0x48, 0x03, 0x52, 0x0b, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74,
+	0x49, 0x6e, 0x64, 0x65, 0x78, 0x88, 0x01, 0x01, 0x12, 0x32, 0x0a, 0x12, 0x6f, 0x72, 0x69, 0x67,
+	0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x12,
+	0x20, 0x01, 0x28, 0x0d, 0x48, 0x04, 0x52, 0x11, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x88, 0x01, 0x01, 0x12, 0x50, 0x0a, 0x23,
+	0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x5f, 0x63,
+	0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e,
+	0x61, 0x6e, 0x6f, 0x18, 0x13, 0x20, 0x01, 0x28, 0x03, 0x48, 0x05, 0x52, 0x1e, 0x6f, 0x72, 0x69,
+	0x67, 0x69, 0x6e, 0x61, 0x6c, 0x4d, 0x65, 0x64, 0x69, 0x61, 0x43, 0x6c, 0x6f, 0x63, 0x6b, 0x54,
+	0x69, 0x6d, 0x65, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x88, 0x01, 0x01, 0x12, 0x1d,
+	0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x18, 0x14, 0x20, 0x01, 0x28, 0x0d, 0x48,
+	0x06, 0x52, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x6e, 0x65, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x24, 0x0a,
+	0x0d, 0x72, 0x65, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x18, 0x15,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x0d, 0x72, 0x65, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6d, 0x69, 0x74,
+	0x74, 0x65, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x16, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12,
+	0x16, 0x0a, 0x06, 0x72, 0x74, 0x70, 0x6d, 0x61, 0x70, 0x18, 0x17, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x72, 0x74, 0x70, 0x6d, 0x61, 0x70, 0x12, 0x45, 0x0a, 0x1d, 0x6f, 0x72, 0x69, 0x67, 0x69,
+	0x6e, 0x61, 0x6c, 0x5f, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75,
+	0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x18, 0x18, 0x20, 0x01, 0x28, 0x03, 0x48, 0x07,
+	0x52, 0x19, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c, 0x45, 0x70, 0x6f, 0x63, 0x68, 0x54,
+	0x69, 0x6d, 0x65, 0x55, 0x6e, 0x69, 0x78, 0x4e, 0x61, 0x6e, 0x6f, 0x88, 0x01, 0x01, 0x42, 0x0e,
+	0x0a, 0x0c, 0x5f, 0x6e, 0x70, 0x74, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x42, 0x1d,
+	0x0a, 0x1b, 0x5f, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x5f, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x74,
+	0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x42, 0x17, 0x0a,
+	0x15, 0x5f, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69,
+	0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x70, 0x61, 0x63, 0x6b, 0x65,
+	0x74, 0x5f, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x42, 0x15, 0x0a, 0x13, 0x5f, 0x6f, 0x72, 0x69, 0x67,
+	0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x42, 0x26,
+	0x0a, 0x24, 0x5f, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c, 0x5f, 0x6d, 0x65, 0x64, 0x69,
+	0x61, 0x5f, 0x63, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69,
+	0x78, 0x5f, 0x6e, 0x61, 0x6e, 0x6f, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x63, 0x68, 0x61, 0x6e, 0x6e,
+	0x65, 0x6c, 0x42, 0x20, 0x0a, 0x1e, 0x5f, 0x6f, 0x72, 0x69, 0x67, 0x69, 0x6e, 0x61, 0x6c, 0x5f,
+	0x65, 0x70, 0x6f, 0x63, 0x68, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x69, 0x78, 0x5f,
+	0x6e, 0x61, 0x6e, 0x6f, 0x42, 0x0d, 0x5a, 0x0b, 0x72, 0x74, 0x73, 0x70, 0x4d, 0x65, 0x74, 0x61,
+	0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+})
+
+var (
+	file_packet_proto_rawDescOnce sync.Once
+	file_packet_proto_rawDescData []byte
+)
+
+func file_packet_proto_rawDescGZIP() []byte {
+	file_packet_proto_rawDescOnce.Do(func() {
+		file_packet_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_packet_proto_rawDesc), len(file_packet_proto_rawDesc)))
+	})
+	return file_packet_proto_rawDescData
+}
+
+var file_packet_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_packet_proto_goTypes = []any{
+	(*Extension)(nil),    // 0: rtspmeta.Extension
+	(*PacketRecord)(nil), // 1: rtspmeta.PacketRecord
+}
+var file_packet_proto_depIdxs = []int32{
+	0, // 0: rtspmeta.PacketRecord.extensions:type_name -> rtspmeta.Extension
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_packet_proto_init() }
+func file_packet_proto_init() {
+	if File_packet_proto != nil {
+		return
+	}
+	file_packet_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_packet_proto_rawDesc), len(file_packet_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_packet_proto_goTypes,
+		DependencyIndexes: file_packet_proto_depIdxs,
+		MessageInfos:      file_packet_proto_msgTypes,
+	}.Build()
+	File_packet_proto = out.File
+	file_packet_proto_goTypes = nil
+	file_packet_proto_depIdxs = nil
+}
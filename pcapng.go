@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/pion/rtp"
+)
+
+// pcapng block types and options.
+// Specification: draft-ietf-opsawg-pcapng
+const (
+	pcapngSectionHeader   = 0x0A0D0D0A
+	pcapngInterface       = 0x00000001
+	pcapngEnhancedPacket  = 0x00000006
+	pcapngByteOrderMagic  = 0x1A2B3C4D
+	pcapngOptEnd          = 0
+	pcapngOptComment      = 1
+	pcapngOptShbUserAppl  = 4
+	pcapngOptIfName       = 2
+	pcapngOptIfDesc       = 3
+	pcapngOptIfTsresol    = 9
+	pcapngLinkTypeIPv4    = 228
+	pcapngNanosecondsExp  = 9
+	pcapngApplicationName = "rtspMeta"
+)
+
+// addresses of the synthesized IPv4 and UDP headers: the server sends from
+// pcapngServerIP to pcapngClientIP, on a pair of ports per track.
+var (
+	pcapngServerIP = [4]byte{192, 0, 2, 1}
+	pcapngClientIP = [4]byte{192, 0, 2, 2}
+)
+
+// first UDP port of the synthesized headers; track i uses
+// pcapngBasePort+2*i on both ends.
+const pcapngBasePort = 20000
+
+// pcapngWriter writes the RTP packets of the SETUP tracks into a pcapng file,
+// for Wireshark. Every track is an interface of its own, whose ID is the
+// index of the track, named after it and described by the URL. Packets are
+// wrapped in synthesized IPv4 and UDP headers, whatever the transport, and
+// stamped with their time of reception; each one carries a comment with its
+// track, SSRC and, for H264 and H265, the type of the NAL units it carries.
+// Wireshark dissects them as RTP with "Decode As" on the UDP ports, or with
+// its rtp_udp heuristic. Duplicates dropped by -dedup are not written.
+type pcapngWriter struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	w     *bufio.Writer
+	flush bool
+	count int
+	ipID  uint16
+}
+
+// newPCAPNGWriter creates the file at path, and writes the section header
+// and an interface per track.
+func newPCAPNGWriter(path string, source string, tracks []*track) (*pcapngWriter, error) {
+	f, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	pw := &pcapngWriter{
+		path:  path,
+		file:  f,
+		w:     bufio.NewWriter(f),
+		flush: path == stdoutPath,
+	}
+
+	var opts []byte
+	opts = appendPCAPNGOption(opts, pcapngOptShbUserAppl, []byte(pcapngApplicationName))
+	body := binary.LittleEndian.AppendUint32(nil, pcapngByteOrderMagic)
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	body = binary.LittleEndian.AppendUint16(body, 0)
+	// unknown section length :
+	body = binary.LittleEndian.AppendUint64(body, ^uint64(0))
+	err = pw.writeBlock(pcapngSectionHeader, body, opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	for _, t := range tracks {
+		opts = appendPCAPNGOption(nil, pcapngOptIfName, []byte(fmt.Sprintf("track%d", t.index)))
+		opts = appendPCAPNGOption(opts, pcapngOptIfDesc, []byte(source+" track "+t.String()))
+		opts = appendPCAPNGOption(opts, pcapngOptIfTsresol, []byte{pcapngNanosecondsExp})
+		body = binary.LittleEndian.AppendUint16(nil, pcapngLinkTypeIPv4)
+		body = binary.LittleEndian.AppendUint16(body, 0)
+		// no snap length :
+		body = binary.LittleEndian.AppendUint32(body, 0)
+		err = pw.writeBlock(pcapngInterface, body, opts)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return pw, nil
+}
+
+// write appends a packet of t, received at received.
+func (pw *pcapngWriter) write(t *track, pkt *rtp.Packet, received time.Time) error {
+	payload, err := pkt.Marshal()
+	if err != nil {
+		return err
+	}
+
+	comment := fmt.Sprintf("track %s, SSRC %08X", t, pkt.SSRC)
+	if nalus := packetNALUTypes(t.media.Formats[0], pkt.Payload); nalus != "" {
+		comment += ", " + nalus
+	}
+
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	pw.ipID++
+	packet := pcapngDatagram(uint16(pcapngBasePort+2*t.index), pw.ipID, payload)
+
+	ts := uint64(received.UnixNano())
+	body := binary.LittleEndian.AppendUint32(nil, uint32(t.index))
+	body = binary.LittleEndian.AppendUint32(body, uint32(ts>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(ts))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(packet)))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(packet)))
+	body = append(body, packet...)
+	body = append(body, make([]byte, pcapngPadding(len(packet)))...)
+
+	err = pw.writeBlock(pcapngEnhancedPacket, body, appendPCAPNGOption(nil, pcapngOptComment, []byte(comment)))
+	if err != nil {
+		return err
+	}
+	pw.count++
+
+	// Readers of a pipe, such as wireshark -k -i -, display packets live :
+	if pw.flush {
+		return pw.w.Flush()
+	}
+	return nil
+}
+
+// writeBlock writes a block with its options, which are terminated here.
+func (pw *pcapngWriter) writeBlock(typ uint32, body []byte, opts []byte) error {
+	if len(opts) != 0 {
+		opts = appendPCAPNGOption(opts, pcapngOptEnd, nil)
+	}
+	length := uint32(12 + len(body) + len(opts))
+
+	buf := binary.LittleEndian.AppendUint32(nil, typ)
+	buf = binary.LittleEndian.AppendUint32(buf, length)
+	buf = append(buf, body...)
+	buf = append(buf, opts...)
+	buf = binary.LittleEndian.AppendUint32(buf, length)
+	_, err := pw.w.Write(buf)
+	return err
+}
+
+// close flushes the file, and closes it unless it is the standard output.
+// It logs how many packets were written.
+func (pw *pcapngWriter) close() error {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	log.Printf("%d packets written into %s", pw.count, outputName(pw.path))
+
+	err := pw.w.Flush()
+	if pw.file != os.Stdout {
+		if cerr := pw.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// appendPCAPNGOption appends an option, padded to 32 bits.
+func appendPCAPNGOption(buf []byte, code uint16, value []byte) []byte {
+	buf = binary.LittleEndian.AppendUint16(buf, code)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(value)))
+	buf = append(buf, value...)
+	return append(buf, make([]byte, pcapngPadding(len(value)))...)
+}
+
+// pcapngPadding returns the number of bytes padding n bytes to 32 bits.
+func pcapngPadding(n int) int {
+	return (4 - n%4) % 4
+}
+
+// pcapngDatagram wraps an RTP packet into IPv4 and UDP headers, from the
+// server to the client, on port. The UDP checksum is left out, as IPv4
+// allows.
+func pcapngDatagram(port uint16, id uint16, payload []byte) []byte {
+	const ipHeaderSize, udpHeaderSize = 20, 8
+	buf := make([]byte, ipHeaderSize+udpHeaderSize, ipHeaderSize+udpHeaderSize+len(payload))
+
+	ip := buf[:ipHeaderSize]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(len(buf)+len(payload)))
+	binary.BigEndian.PutUint16(ip[4:], id)
+	ip[8] = 64
+	ip[9] = 17
+	copy(ip[12:16], pcapngServerIP[:])
+	copy(ip[16:20], pcapngClientIP[:])
+	var sum uint32
+	for i := 0; i < ipHeaderSize; i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(ip[i:]))
+	}
+	for sum > 0xFFFF {
+		sum = sum&0xFFFF + sum>>16
+	}
+	binary.BigEndian.PutUint16(ip[10:], ^uint16(sum))
+
+	udp := buf[ipHeaderSize:]
+	binary.BigEndian.PutUint16(udp[0:], port)
+	binary.BigEndian.PutUint16(udp[2:], port)
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpHeaderSize+len(payload)))
+
+	return append(buf, payload...)
+}
+
+// packetNALUTypes describes the NAL units carried by an H264 or H265 RTP
+// payload: a single one, the ones of an aggregation packet, or the one of
+// which a fragmentation unit carries a part. It returns an empty string for
+// other formats.
+// Specification: RFC 6184, 5.2; RFC 7798, 4.4
+func packetNALUTypes(forma format.Format, payload []byte) string {
+	switch forma.(type) {
+	case *format.H264:
+		if len(payload) < 1 {
+			return ""
+		}
+		typ := h264.NALUType(payload[0] & 0x1F)
+		switch typ {
+		case h264.NALUTypeSTAPA:
+			var types []string
+			for buf := payload[1:]; len(buf) >= 3; {
+				size := int(binary.BigEndian.Uint16(buf))
+				if size == 0 || 2+size > len(buf) {
+					break
+				}
+				types = append(types, h264.NALUType(buf[2]&0x1F).String())
+				buf = buf[2+size:]
+			}
+			return "STAP-A of " + strings.Join(types, ", ")
+
+		case h264.NALUTypeFUA:
+			if len(payload) < 2 {
+				return ""
+			}
+			return "FU-A of " + h264.NALUType(payload[1]&0x1F).String() + fragmentPosition(payload[1])
+		}
+		return typ.String()
+
+	case *format.H265:
+		if len(payload) < 2 {
+			return ""
+		}
+		typ := h265.NALUType((payload[0] >> 1) & 0x3F)
+		switch typ {
+		case h265.NALUType_AggregationUnit:
+			var types []string
+			for buf := payload[2:]; len(buf) >= 4; {
+				size := int(binary.BigEndian.Uint16(buf))
+				if size < 2 || 2+size > len(buf) {
+					break
+				}
+				types = append(types, h265.NALUType((buf[2]>>1)&0x3F).String())
+				buf = buf[2+size:]
+			}
+			return "AP of " + strings.Join(types, ", ")
+
+		case h265.NALUType_FragmentationUnit:
+			if len(payload) < 3 {
+				return ""
+			}
+			return "FU of " + h265.NALUType(payload[2]&0x3F).String() + fragmentPosition(payload[2])
+		}
+		return typ.String()
+	}
+	return ""
+}
+
+// fragmentPosition tells from the header of a fragmentation unit whether it
+// starts or ends its NAL unit.
+func fragmentPosition(header byte) string {
+	switch {
+	case header&0x80 != 0:
+		return " (start)"
+	case header&0x40 != 0:
+		return " (end)"
+	}
+	return ""
+}
+
+// openPCAPNG captures the RTP packets of every track into the -pcapng-out
+// file.
+func (o *outputs) openPCAPNG(tracks []*track) error {
+	pcapng, err := newPCAPNGWriter(o.cfg.outputPath(o.cfg.pcapngOut), redactURL(o.cfg.url), tracks)
+	if err != nil {
+		return fmt.Errorf("creating pcapng file: %w", err)
+	}
+	o.onClose(func() {
+		err := pcapng.close()
+		if err != nil {
+			log.Printf("Error closing pcapng file: %v", err)
+		}
+	})
+	queue := o.newQueue("pcapng")
+
+	o.onRTP(func(t *track, pkt *rtp.Packet) {
+		received := time.Now()
+		queue.push(func() error {
+			err := pcapng.write(t, pkt, received)
+			if isBrokenPipe(err) {
+				return err
+			}
+			if err != nil {
+				log.Printf("Error writing packet of track %s to pcapng: %v", t, err)
+			}
+			return nil
+		})
+	})
+	return nil
+}
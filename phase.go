@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+)
+
+// phaseTimer aborts a phase of the session (DESCRIBE, SETUP) that does not
+// complete within its own timeout, independently of the read timeout of the
+// client: once expired, it closes the client, which makes the pending
+// request fail.
+type phaseTimer struct {
+	timer      *time.Timer
+	hasExpired atomic.Bool
+}
+
+// startPhaseTimer starts the timer of a phase. A zero timeout disables it.
+func startPhaseTimer(client *gortsplib.Client, timeout time.Duration) *phaseTimer {
+	p := &phaseTimer{}
+	if timeout > 0 {
+		p.timer = time.AfterFunc(timeout, func() {
+			p.hasExpired.Store(true)
+			client.Close()
+		})
+	}
+	return p
+}
+
+// expired returns whether the phase timed out.
+func (p *phaseTimer) expired() bool {
+	return p.hasExpired.Load()
+}
+
+// stop stops the timer at the end of the phase, and returns whether the
+// phase timed out.
+func (p *phaseTimer) stop() bool {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	return p.expired()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/liberrors"
+)
+
+// probeResult is the outcome of -connect-only, printed on stdout.
+type probeResult struct {
+	URL        string     `json:"url"`
+	OK         bool       `json:"ok"`
+	Connection *connAddrs `json:"connection,omitempty"`
+	// time to open the connection, and round-trip time of OPTIONS :
+	ConnectMS float64 `json:"connect_ms"`
+	RTTMS     float64 `json:"rtt_ms"`
+	// status of the OPTIONS response, when one was received.
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// probe connects to the server and sends OPTIONS, without DESCRIBE.
+// Any RTSP response, even an error status, proves that the server is
+// alive, and makes the probe succeed. It returns the exit code.
+func probe(cfg *config) int {
+	parsedURL, err := base.ParseURL(cfg.url)
+	if err != nil {
+		log.Printf("Cannot parse RTSP URL : %v", err)
+		return 1
+	}
+
+	client := &gortsplib.Client{
+		ReadTimeout:  cfg.connectTimeout,
+		WriteTimeout: cfg.connectTimeout,
+	}
+
+	// Time the opening of the connection apart from OPTIONS :
+	var connectTime time.Duration
+	conn := &connRecorder{dial: (&net.Dialer{}).DialContext}
+	if cfg.bindAddr != nil {
+		conn.dial = bindDialContext(cfg.bindAddr)
+	}
+	if cfg.pinnedHost != nil {
+		conn.dial = cfg.pinnedHost.wrap(conn.dial)
+	}
+	client.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialStart := time.Now()
+		defer func() { connectTime = time.Since(dialStart) }()
+
+		ctx, cancel := context.WithTimeout(ctx, cfg.connectTimeout)
+		defer cancel()
+		return conn.dialContext(ctx, network, address)
+	}
+
+	result := &probeResult{URL: cfg.url}
+	var rtt time.Duration
+
+	err = client.Start(parsedURL.Scheme, parsedURL.Host)
+	if err == nil {
+		defer client.Close()
+
+		optionsStart := time.Now()
+		var res *base.Response
+		res, err = client.Options(parsedURL)
+		rtt = time.Since(optionsStart) - connectTime
+
+		var badStatus liberrors.ErrClientBadStatusCode
+		switch {
+		case errors.As(err, &badStatus):
+			result.StatusCode = int(badStatus.Code)
+		case res != nil:
+			result.StatusCode = int(res.StatusCode)
+		}
+		if result.StatusCode != 0 {
+			result.OK = true
+			result.ConnectMS = durationMS(connectTime)
+			result.RTTMS = durationMS(rtt)
+		}
+	}
+	result.Connection = conn.addrs()
+
+	if result.OK {
+		log.Printf("Server answered OPTIONS with status %d, connect %v, round trip %v",
+			result.StatusCode, connectTime, rtt)
+	} else {
+		result.Error = err.Error()
+		log.Printf("Error probing server: %v", err)
+	}
+
+	printJSON(os.Stdout, result, cfg.reportJSONPretty)
+
+	if !result.OK {
+		return 1
+	}
+	return 0
+}
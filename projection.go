@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// projectedField is a field of PacketRecord kept by a projection.
+type projectedField struct {
+	name      string
+	index     int
+	omitEmpty bool
+}
+
+// fieldProjection restricts the packet records in the outputs to some of
+// their fields, with -json-fields or -json-exclude-fields, so that the
+// fields which are not needed are neither marshaled nor written.
+type fieldProjection struct {
+	fields []projectedField
+}
+
+// packetRecordFields returns the fields of PacketRecord, in order, named as
+// in the JSON output.
+func packetRecordFields() []projectedField {
+	typ := reflect.TypeOf(PacketRecord{})
+	fields := make([]projectedField, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		name, opts, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		fields = append(fields, projectedField{
+			name:      name,
+			index:     i,
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
+
+// newFieldProjection returns the projection keeping the included fields, or
+// all the fields but the excluded ones. Fields are named as in the JSON
+// output, or as in PacketRecord (seq for sequence_number), case-insensitively.
+func newFieldProjection(include []string, exclude []string) (*fieldProjection, error) {
+	all := packetRecordFields()
+	typ := reflect.TypeOf(PacketRecord{})
+
+	lookup := func(name string) (int, error) {
+		for i, f := range all {
+			if strings.EqualFold(name, f.name) || strings.EqualFold(name, typ.Field(f.index).Name) {
+				return i, nil
+			}
+		}
+		names := make([]string, len(all))
+		for i, f := range all {
+			names[i] = f.name
+		}
+		return 0, fmt.Errorf("unknown packet field %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	selected := make([]bool, len(all))
+	if len(include) == 0 {
+		for i := range selected {
+			selected[i] = true
+		}
+	}
+	for _, name := range include {
+		i, err := lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		selected[i] = true
+	}
+	for _, name := range exclude {
+		i, err := lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		selected[i] = false
+	}
+
+	p := &fieldProjection{}
+	for i, f := range all {
+		if selected[i] {
+			p.fields = append(p.fields, f)
+		}
+	}
+	return p, nil
+}
+
+// marshal returns the JSON object of a record restricted to the projected
+// fields, preceded by the index, the name and the rtpmap of its track when t
+// is not nil, as in NDJSON files. Fields are omitted when empty as in the
+// full record.
+func (p *fieldProjection) marshal(t *track, rec *PacketRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if t != nil {
+		buf.WriteString(`"track":` + strconv.Itoa(t.index))
+		if t.name != "" {
+			buf.WriteString(`,"track_name":` + strconv.Quote(t.name))
+		}
+		if rtpmap := t.genericRTPMap(); rtpmap != "" {
+			buf.WriteString(`,"rtpmap":` + strconv.Quote(rtpmap))
+		}
+	}
+
+	v := reflect.ValueOf(rec).Elem()
+	for _, f := range p.fields {
+		fv := v.Field(f.index)
+		if f.omitEmpty && fv.IsZero() || f.omitEmpty && fv.Kind() == reflect.Slice && fv.Len() == 0 {
+			continue
+		}
+		value, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(strconv.Quote(f.name))
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// splitList splits a comma-separated list, ignoring spaces and empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
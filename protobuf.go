@@ -0,0 +1,113 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=module=rtspMeta packet.proto
+
+import (
+	"bufio"
+	"os"
+	"time"
+
+	"github.com/pion/rtp"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+
+	"rtspMeta/pb"
+)
+
+// formats of the packets written into -ndjson-out :
+const (
+	outFormatJSON     = "json"
+	outFormatProtobuf = "protobuf"
+)
+
+// packetProto converts the record of a packet of t to a PacketRecord message
+// of packet.proto.
+func packetProto(t *track, rec *PacketRecord) *pb.PacketRecord {
+	msg := &pb.PacketRecord{
+		Track:     uint32(t.index),
+		TrackName: t.name,
+
+		Version:          uint32(rec.Version),
+		SequenceNumber:   uint32(rec.Seq),
+		Timestamp:        rec.Timestamp,
+		Extension:        rec.Extension,
+		Padding:          rec.Padding,
+		Marker:           rec.Marker,
+		PayloadType:      uint32(rec.PayloadType),
+		Ssrc:             rec.SSRC,
+		Csrc:             rec.CSRC,
+		ExtensionProfile: uint32(rec.ExtensionProfile),
+
+		NptSeconds:             rec.NPTSeconds,
+		MediaClockTimeUnixNano: unixNano(rec.MediaClockTime),
+		EpochTimeUnixNano:      unixNano(rec.EpochTime),
+
+		PacketIndex:                    rec.PacketIndex,
+		OriginalTimestamp:              rec.OriginalTimestamp,
+		OriginalMediaClockTimeUnixNano: unixNano(rec.OriginalMediaClockTime),
+		OriginalEpochTimeUnixNano:      unixNano(rec.OriginalEpochTime),
+
+		Retransmitted: rec.Retransmitted,
+		Metadata:      rec.Metadata,
+		Rtpmap:        t.genericRTPMap(),
+	}
+
+	// The fields of extensions are only exposed through the header :
+	header := rtp.Header{Extension: rec.Extension, Extensions: rec.Extensions}
+	for _, id := range header.GetExtensionIDs() {
+		msg.Extensions = append(msg.Extensions, &pb.Extension{
+			Id:      uint32(id),
+			Payload: header.GetExtension(id),
+		})
+	}
+
+	if rec.Channel != nil {
+		msg.Channel = proto.Uint32(uint32(*rec.Channel))
+	}
+	return msg
+}
+
+// unixNano returns a time as nanoseconds since the Unix epoch, or nil.
+func unixNano(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	return proto.Int64(t.UnixNano())
+}
+
+// protobufSink writes every packet into a file, as PacketRecord messages of
+// packet.proto, each one preceded by its size as a varint. It is several
+// times faster to write and to read than NDJSON, and smaller.
+type protobufSink struct {
+	file *os.File
+	w    *bufio.Writer
+}
+
+// newProtobufSink creates the file at path, with a write buffer of
+// bufferSize bytes.
+func newProtobufSink(path string, bufferSize int) (*protobufSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &protobufSink{
+		file: f,
+		w:    bufio.NewWriterSize(f, bufferSize),
+	}, nil
+}
+
+// writePacket implements packetSink.
+func (s *protobufSink) writePacket(t *track, rec *PacketRecord) error {
+	_, err := protodelim.MarshalTo(s.w, packetProto(t, rec))
+	return err
+}
+
+// close implements packetSink.
+func (s *protobufSink) close() error {
+	err := s.w.Flush()
+	cerr := s.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+
+	"rtspMeta/pb"
+)
+
+func TestProtobufSink(t *testing.T) {
+	tr := parseTestSDP(t, muxedSDP)[0]
+	tr.name = "front"
+
+	npt := 12.5
+	epoch := time.Unix(1700000000, 123456789)
+	index := uint64(41)
+	original := uint32(90000)
+	channel := 2
+	header := rtp.Header{Extension: true, ExtensionProfile: 0xBEDE}
+	err := header.SetExtension(3, []byte{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := []*PacketRecord{
+		{
+			Version:          2,
+			Seq:              1000,
+			Timestamp:        3000,
+			Extension:        true,
+			Marker:           true,
+			PayloadType:      96,
+			SSRC:             0xDEADBEEF,
+			CSRC:             []uint32{1, 2},
+			Extensions:       header.Extensions,
+			ExtensionProfile: header.ExtensionProfile,
+			NPTSeconds:       &npt,
+			EpochTime:        &epoch,
+			PacketIndex:      &index,
+			Channel:          &channel,
+			Retransmitted:    true,
+		},
+		// Optional fields keep their zero values :
+		{
+			Version:           2,
+			PacketIndex:       new(uint64),
+			OriginalTimestamp: &original,
+			OriginalEpochTime: &time.Time{},
+		},
+	}
+	want := []*pb.PacketRecord{
+		{
+			Track:             0,
+			TrackName:         "front",
+			Version:           2,
+			SequenceNumber:    1000,
+			Timestamp:         3000,
+			Extension:         true,
+			Marker:            true,
+			PayloadType:       96,
+			Ssrc:              0xDEADBEEF,
+			Csrc:              []uint32{1, 2},
+			Extensions:        []*pb.Extension{{Id: 3, Payload: []byte{1, 2}}},
+			ExtensionProfile:  0xBEDE,
+			NptSeconds:        proto.Float64(12.5),
+			EpochTimeUnixNano: proto.Int64(1700000000123456789),
+			PacketIndex:       proto.Uint64(41),
+			Channel:           proto.Uint32(2),
+			Retransmitted:     true,
+		},
+		{
+			TrackName:                 "front",
+			Version:                   2,
+			PacketIndex:               proto.Uint64(0),
+			OriginalTimestamp:         proto.Uint32(90000),
+			OriginalEpochTimeUnixNano: proto.Int64((time.Time{}).UnixNano()),
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "packets.pb")
+	s, err := newProtobufSink(path, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		err = s.writePacket(tr, rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file decodes against packet.proto as delimited messages :
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	for i, w := range want {
+		var msg pb.PacketRecord
+		err = protodelim.UnmarshalFrom(r, &msg)
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !proto.Equal(&msg, w) {
+			t.Errorf("record %d: got %v, want %v", i, &msg, w)
+		}
+	}
+	var msg pb.PacketRecord
+	err = protodelim.UnmarshalFrom(r, &msg)
+	if err != io.EOF {
+		t.Errorf("got %v after the records, want EOF", err)
+	}
+}
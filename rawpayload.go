@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// rawPayloadWriter writes the RTP payloads of a single track into a file,
+// as they are received, without any depacketization: the bytes are the ones
+// following the RTP header, padding excluded. Without framing, payloads are
+// simply concatenated; with framing, each one is preceded by its length as a
+// 4-byte big-endian integer, so that packet boundaries are preserved.
+// Duplicates dropped by -dedup and packets restored from RTX retransmissions
+// are not written, so that the file follows the order of reception.
+type rawPayloadWriter struct {
+	track  *track
+	framed bool
+
+	mutex  sync.Mutex
+	file   *os.File
+	w      *bufio.Writer
+	offset int64
+
+	// index of the frames, when enabled :
+	index *rawPayloadIndex
+}
+
+// newRawPayloadWriter creates the file at path, receiving the payloads of t.
+// With index, the index of the frames is written into path.idx.
+func newRawPayloadWriter(path string, t *track, framed bool, index bool) (*rawPayloadWriter, error) {
+	if !t.setup {
+		return nil, fmt.Errorf("track %s was not SETUP", t)
+	}
+
+	f, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &rawPayloadWriter{
+		track:  t,
+		framed: framed,
+		file:   f,
+		w:      bufio.NewWriter(f),
+	}
+
+	if index {
+		w.index, err = newRawPayloadIndex(path+".idx", t.media.Formats[0].Codec())
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// write appends a payload to the file, with the RTP timestamp of its packet.
+func (w *rawPayloadWriter) write(payload []byte, timestamp uint32) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.index != nil {
+		err := w.index.push(w.offset, payload, timestamp)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.framed {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(payload)))
+		_, err := w.w.Write(size[:])
+		if err != nil {
+			return err
+		}
+		w.offset += int64(len(size))
+	}
+	_, err := w.w.Write(payload)
+	w.offset += int64(len(payload))
+	return err
+}
+
+// close flushes and closes the file, and then the index.
+func (w *rawPayloadWriter) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.w.Flush()
+	cerr := w.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	if w.index != nil {
+		cerr = w.index.close(w.offset)
+		if err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// rawPayloadIndexEntry is a line of the index of a raw payload file: a
+// frame, made of the payloads sharing an RTP timestamp, and where its
+// bytes lie in the file, framing included.
+type rawPayloadIndexEntry struct {
+	Frame     uint64 `json:"frame"`
+	Offset    int64  `json:"offset"`
+	Size      int64  `json:"size"`
+	Packets   int    `json:"packets"`
+	Timestamp uint32 `json:"timestamp"`
+	Keyframe  bool   `json:"keyframe"`
+}
+
+// rawPayloadIndex writes the index of a raw payload file, one JSON object
+// per frame, so that the file can be seeked by timestamp, frame number or
+// keyframe. A frame is written once the next one starts. The index is
+// written into a temporary file, renamed next to the raw payload file once
+// complete, so that an index is never partial.
+type rawPayloadIndex struct {
+	path  string
+	codec string
+	file  *os.File
+	w     *bufio.Writer
+
+	// frame in progress :
+	started bool
+	entry   rawPayloadIndexEntry
+}
+
+// newRawPayloadIndex creates the temporary file of the index at path.
+func newRawPayloadIndex(path string, codec string) (*rawPayloadIndex, error) {
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &rawPayloadIndex{
+		path:  path,
+		codec: codec,
+		file:  f,
+		w:     bufio.NewWriter(f),
+	}, nil
+}
+
+// push adds a payload, written at offset, to the index.
+func (x *rawPayloadIndex) push(offset int64, payload []byte, timestamp uint32) error {
+	if !x.started || timestamp != x.entry.Timestamp {
+		if x.started {
+			err := x.writeEntry(offset)
+			if err != nil {
+				return err
+			}
+			x.entry = rawPayloadIndexEntry{Frame: x.entry.Frame + 1}
+		}
+		x.started = true
+		x.entry.Offset = offset
+		x.entry.Timestamp = timestamp
+		// frames of other formats than video can all be decoded alone :
+		x.entry.Keyframe = x.codec != "H264" && x.codec != "H265"
+	}
+
+	x.entry.Packets++
+	if !x.entry.Keyframe {
+		x.entry.Keyframe = payloadHasKeyframe(x.codec, payload)
+	}
+	return nil
+}
+
+// writeEntry writes the frame in progress, which ends at end.
+func (x *rawPayloadIndex) writeEntry(end int64) error {
+	x.entry.Size = end - x.entry.Offset
+	buf, err := json.Marshal(x.entry)
+	if err != nil {
+		return err
+	}
+	_, err = x.w.Write(append(buf, '\n'))
+	return err
+}
+
+// close writes the last frame, which ends at end, and renames the index
+// into its final path.
+func (x *rawPayloadIndex) close(end int64) error {
+	var err error
+	if x.started {
+		err = x.writeEntry(end)
+	}
+	if err == nil {
+		err = x.w.Flush()
+	}
+	if err == nil {
+		err = x.file.Sync()
+	}
+	cerr := x.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(x.file.Name(), x.path)
+}
+
+// payloadHasKeyframe returns whether an H264 or H265 RTP payload carries
+// the start of an IDR or IRAP picture, as a single NAL unit, in an
+// aggregation packet or as the first fragment of a fragmentation unit.
+func payloadHasKeyframe(codec string, payload []byte) bool {
+	switch codec {
+	case "H264":
+		if len(payload) < 1 {
+			return false
+		}
+		switch typ := payload[0] & 0x1F; typ {
+		case 5:
+			return true
+		case 24: // STAP-A
+			for p := payload[1:]; len(p) > 2; {
+				size := int(binary.BigEndian.Uint16(p))
+				if size == 0 || len(p) < 2+size {
+					return false
+				}
+				if p[2]&0x1F == 5 {
+					return true
+				}
+				p = p[2+size:]
+			}
+		case 28: // FU-A
+			return len(payload) >= 2 && payload[1]&0x80 != 0 && payload[1]&0x1F == 5
+		}
+
+	case "H265":
+		if len(payload) < 2 {
+			return false
+		}
+		irap := func(typ byte) bool { return typ >= 16 && typ <= 23 }
+		switch typ := (payload[0] >> 1) & 0x3F; typ {
+		case 48: // aggregation packet
+			for p := payload[2:]; len(p) > 2; {
+				size := int(binary.BigEndian.Uint16(p))
+				if size == 0 || len(p) < 2+size {
+					return false
+				}
+				if irap((p[2] >> 1) & 0x3F) {
+					return true
+				}
+				p = p[2+size:]
+			}
+		case 49: // fragmentation unit
+			return len(payload) >= 3 && payload[2]&0x80 != 0 && irap(payload[2]&0x3F)
+		default:
+			return irap(typ)
+		}
+	}
+	return false
+}
+
+// openRawPayload writes the payloads of the track of -raw-payload-track
+// into the -raw-payload-out file.
+func (o *outputs) openRawPayload(tracks []*track) error {
+	if o.cfg.rawPayloadTrack >= len(tracks) {
+		return fmt.Errorf("creating raw payload file: there is no track #%d", o.cfg.rawPayloadTrack)
+	}
+	rawPayloadOut, err := newRawPayloadWriter(o.cfg.outputPath(o.cfg.rawPayloadOut), tracks[o.cfg.rawPayloadTrack],
+		o.cfg.rawPayloadFramed, o.cfg.rawPayloadIndex)
+	if err != nil {
+		return fmt.Errorf("creating raw payload file: %w", err)
+	}
+	o.onClose(func() {
+		err := rawPayloadOut.close()
+		if err != nil && !isBrokenPipe(err) {
+			log.Printf("Error closing raw payload file: %v", err)
+		}
+	})
+	queue := o.newQueue("raw payload")
+
+	o.onRTP(func(t *track, pkt *rtp.Packet) {
+		if t != rawPayloadOut.track {
+			return
+		}
+		payload, timestamp := pkt.Payload, pkt.Timestamp
+		queue.push(func() error {
+			err := rawPayloadOut.write(payload, timestamp)
+			if isBrokenPipe(err) {
+				return err
+			}
+			if err != nil {
+				log.Printf("Error writing raw payload of track %s: %v", t, err)
+			}
+			return nil
+		})
+	})
+	return nil
+}
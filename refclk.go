@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+)
+
+// Epochs of the reference clocks whose media clocks can be mapped to
+// absolute times. PTP counts TAI seconds, which are ahead of UTC by
+// the leap seconds accumulated since 1972 :
+var (
+	ptpEpoch = time.Unix(0, 0).Add(-37 * time.Second)
+	ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// mediaClock describes the reference clock and the media clock of a media
+// (RFC 7273), from the ts-refclk and mediaclk attributes of the SDP.
+type mediaClock struct {
+	Media    int    `json:"media"`
+	RefClock string `json:"ts_refclk,omitempty"`
+	MediaClk string `json:"mediaclk,omitempty"`
+	// RTP timestamp at the epoch of the reference clock, for direct media clocks.
+	Offset *uint32 `json:"offset,omitempty"`
+	// whether the RTP timestamps are locked to an external clock.
+	Synchronized bool `json:"synchronized"`
+}
+
+// mediaClocks returns the clocks declared in the raw SDP for every media
+// which declares some, at media or session level. Media-level attributes
+// take precedence.
+func mediaClocks(raw []byte) []*mediaClock {
+	var ssd sdp.SessionDescription
+	if ssd.Unmarshal(raw) != nil {
+		return nil
+	}
+
+	var sessionRefClk, sessionMediaClk string
+	for _, attr := range ssd.Attributes {
+		setClockAttribute(&sessionRefClk, &sessionMediaClk, attr.Key, attr.Value)
+	}
+
+	var clocks []*mediaClock
+	for i, md := range ssd.MediaDescriptions {
+		var refClk, mediaClk string
+		for _, attr := range md.Attributes {
+			setClockAttribute(&refClk, &mediaClk, attr.Key, attr.Value)
+		}
+		if refClk == "" {
+			refClk = sessionRefClk
+		}
+		if mediaClk == "" {
+			mediaClk = sessionMediaClk
+		}
+		if refClk == "" && mediaClk == "" {
+			continue
+		}
+
+		c := &mediaClock{
+			Media:        i,
+			RefClock:     refClk,
+			MediaClk:     mediaClk,
+			Synchronized: refClk != "" && !strings.HasPrefix(refClk, "local"),
+		}
+		if v, ok := strings.CutPrefix(mediaClk, "direct="); ok {
+			// the offset can be followed by a rate, ignored here.
+			v, _, _ = strings.Cut(v, " ")
+			if offset, err := strconv.ParseUint(v, 10, 32); err == nil {
+				o := uint32(offset)
+				c.Offset = &o
+			}
+		}
+		clocks = append(clocks, c)
+	}
+	return clocks
+}
+
+// setClockAttribute stores the value of a ts-refclk or mediaclk attribute.
+// Only the first occurrence of each is kept, as the preferred one.
+func setClockAttribute(refClk *string, mediaClk *string, key string, value string) {
+	switch {
+	case key == "ts-refclk" && *refClk == "":
+		*refClk = value
+	case key == "mediaclk" && *mediaClk == "":
+		*mediaClk = value
+	}
+}
+
+// epoch returns the epoch of the reference clock, when it is known.
+func (c *mediaClock) epoch() (time.Time, bool) {
+	switch {
+	case strings.HasPrefix(c.RefClock, "ptp="):
+		return ptpEpoch, true
+	case strings.HasPrefix(c.RefClock, "ntp="):
+		return ntpEpoch, true
+	}
+	return time.Time{}, false
+}
+
+// absoluteTime maps an RTP timestamp of a direct media clock to an absolute
+// time. Since RTP timestamps wrap around, the time closest to now is chosen.
+func (c *mediaClock) absoluteTime(ts uint32, clockRate int, now time.Time) (time.Time, bool) {
+	epoch, ok := c.epoch()
+	if c.Offset == nil || !ok || clockRate <= 0 {
+		return time.Time{}, false
+	}
+
+	period := ticksToDuration(1<<32, int64(clockRate))
+	elapsed := ticksToDuration(int64(ts-*c.Offset), int64(clockRate))
+
+	// Number of whole periods between the epoch and now :
+	periods := now.Sub(epoch) / period
+	t := epoch.Add(periods*period + elapsed)
+	if d := t.Sub(now); d > period/2 {
+		t = t.Add(-period)
+	} else if d < -period/2 {
+		t = t.Add(period)
+	}
+	return t, true
+}
+
+// logMediaClocks logs the SETUP tracks whose timestamps are locked to a
+// synchronized reference clock.
+func logMediaClocks(tracks []*track) {
+	for _, t := range tracks {
+		if t.setup && t.clock != nil && t.clock.Synchronized {
+			log.Printf("Track %s uses a synchronized clock: ts-refclk %s, mediaclk %s",
+				t, t.clock.RefClock, t.clock.MediaClk)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+)
+
+// marshalJSON returns the JSON encoding of v, indented when pretty is set.
+func marshalJSON(v any, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// printJSON writes the JSON encoding of v to w, followed by a line break.
+func printJSON(w io.Writer, v any, pretty bool) error {
+	buf, err := marshalJSON(v, pretty)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+// logJSON logs a title followed by the JSON encoding of v.
+func logJSON(title string, v any, pretty bool) {
+	buf, err := marshalJSON(v, pretty)
+	if err != nil {
+		log.Printf("Error marshaling %s to JSON: %v", title, err)
+		return
+	}
+	log.Println(title + ":")
+	log.Println(string(buf))
+}
+
+// setupSummary lists the outcome of the SETUP of every track.
+type setupSummary struct {
+	Tracks []setupReport `json:"tracks"`
+	// time taken by the SETUP requests of all the tracks.
+	DurationMS float64 `json:"duration_ms,omitempty"`
+}
+
+// newSetupSummary builds the setup summary of the given tracks.
+func newSetupSummary(cfg *config, tracks []*track) *setupSummary {
+	s := &setupSummary{
+		Tracks: make([]setupReport, len(tracks)),
+	}
+	for i, t := range tracks {
+		s.Tracks[i] = t.setupReport(cfg.trackSelected(t.index))
+	}
+	return s
+}
+
+// succeeded returns whether every selected track was SETUP.
+func (s *setupSummary) succeeded() bool {
+	for _, t := range s.Tracks {
+		if t.Selected && !t.Setup {
+			return false
+		}
+	}
+	return true
+}
+
+// finalReport is printed when the program exits.
+type finalReport struct {
+	URL       string        `json:"url"`
+	StartedAt time.Time     `json:"started_at"`
+	Uptime    string        `json:"uptime"`
+	Totals    totalsReport  `json:"totals"`
+	Tracks    []trackReport `json:"tracks"`
+
+	// Addresses of the RTSP connection :
+	Connection *connAddrs `json:"connection,omitempty"`
+
+	// Offset of the server clock, from the Date header of its responses :
+	ServerClock *serverClockReport `json:"server_clock,omitempty"`
+
+	// Switches from UDP to TCP during the run :
+	TransportSwitches []transportSwitch `json:"transport_switches,omitempty"`
+
+	// RTCP packets multiplexed with RTP, which were dropped :
+	MuxedRTCPDropped uint64 `json:"muxed_rtcp_dropped,omitempty"`
+
+	// Writes of the outputs, dropped or delayed because of slow storage :
+	Outputs []outputReport `json:"outputs,omitempty"`
+
+	// Relative drift between the clocks of the tracks, measured from RTCP
+	// sender reports :
+	ClockDrift []driftPair `json:"clock_drift,omitempty"`
+
+	// Outcome of -min-packets :
+	MinPackets *minPacketsReport `json:"min_packets,omitempty"`
+}
+
+// minPacketsReport tells whether every SETUP track received the number of
+// packets required by -min-packets.
+type minPacketsReport struct {
+	Required uint64              `json:"required"`
+	Met      bool                `json:"met"`
+	Tracks   []minPacketsOutcome `json:"tracks"`
+}
+
+// minPacketsOutcome is the number of packets received by a track.
+type minPacketsOutcome struct {
+	Track   int    `json:"track"`
+	Packets uint64 `json:"packets"`
+	Met     bool   `json:"met"`
+}
+
+// checkMinPackets checks the number of packets of the SETUP tracks against
+// required, and logs the tracks which fall short.
+func checkMinPackets(r *finalReport, tracks []*track, required uint64) *minPacketsReport {
+	m := &minPacketsReport{
+		Required: required,
+		Met:      true,
+	}
+	for i, t := range tracks {
+		if !t.setup {
+			continue
+		}
+		o := minPacketsOutcome{
+			Track:   t.index,
+			Packets: r.Tracks[i].Packets,
+			Met:     r.Tracks[i].Packets >= required,
+		}
+		if !o.Met {
+			m.Met = false
+			log.Printf("Track %s received %d packets, fewer than the %d required by -min-packets",
+				t, o.Packets, required)
+		}
+		m.Tracks = append(m.Tracks, o)
+	}
+	return m
+}
+
+// totalsReport sums the counters of all the tracks.
+type totalsReport struct {
+	Packets    uint64 `json:"packets"`
+	Bytes      uint64 `json:"bytes"`
+	Duplicates uint64 `json:"duplicates"`
+	Malformed  uint64 `json:"malformed"`
+	Lost       uint64 `json:"lost"`
+	Recovered  uint64 `json:"recovered"`
+	// packets dropped before the outputs with -simulate-loss.
+	SimulatedLosses uint64 `json:"simulated_losses,omitempty"`
+}
+
+// newFinalReport builds the final report of a run started at the given time.
+func newFinalReport(url string, startedAt time.Time, tracks []*track,
+	conn *connRecorder, fallbacks *fallbackRecorder,
+) *finalReport {
+	r := &finalReport{
+		URL:               url,
+		StartedAt:         startedAt,
+		Uptime:            time.Since(startedAt).Round(time.Millisecond).String(),
+		Tracks:            make([]trackReport, len(tracks)),
+		Connection:        conn.addrs(),
+		TransportSwitches: fallbacks.report(),
+	}
+	for i, t := range tracks {
+		r.Tracks[i] = t.report()
+		r.Totals.Packets += r.Tracks[i].Packets
+		r.Totals.Bytes += r.Tracks[i].Bytes
+		r.Totals.Duplicates += r.Tracks[i].Duplicates
+		r.Totals.Malformed += r.Tracks[i].Malformed
+		r.Totals.Lost += r.Tracks[i].Lost
+		r.Totals.Recovered += r.Tracks[i].Recovered
+		r.Totals.SimulatedLosses += r.Tracks[i].SimulatedLosses
+	}
+	return r
+}
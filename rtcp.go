@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pion/rtcp"
+)
+
+// rtcpRecord is the representation of an RTCP packet in the output.
+// Extended Reports are decoded block by block, other packets are output
+// as decoded by the rtcp package.
+type rtcpRecord struct {
+	Track     int    `json:"track"`
+	TrackName string `json:"track_name,omitempty"`
+	// interleaved channel of the packet, when streaming over TCP.
+	Channel *int   `json:"channel,omitempty"`
+	Type    string `json:"type"`
+	Packet  any    `json:"packet"`
+}
+
+// logRTCPPacket prints an RTCP packet of a track in JSON through the
+// standard logger.
+func logRTCPPacket(t *track, pkt rtcp.Packet, pretty bool) {
+	rec := rtcpRecord{
+		Track:     t.index,
+		TrackName: t.name,
+		Type:      strings.TrimPrefix(fmt.Sprintf("%T", pkt), "*rtcp."),
+		Packet:    pkt,
+	}
+	if ch := t.channels(); ch != nil {
+		rec.Channel = &ch[1]
+	}
+	if xr, ok := pkt.(*rtcp.ExtendedReport); ok {
+		rec.Packet = newXRRecord(xr)
+	}
+
+	packetJSON, err := marshalJSON(rec, pretty)
+	if err != nil {
+		log.Printf("Error marshaling RTCP packet to JSON: %v", err)
+		return
+	}
+	log.Println("Received RTCP packet:")
+	log.Println(string(packetJSON))
+}
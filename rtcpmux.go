@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/liberrors"
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+)
+
+// Payload types under which RTCP packets (types 200 to 207) appear when
+// they are parsed as RTP, the marker bit taking the high bit (RFC 5761) :
+const (
+	rtcpMuxMinPayloadType = 72
+	rtcpMuxMaxPayloadType = 79
+)
+
+// rtcpMuxMedias returns the indexes of the medias for which the SDP
+// advertises RTP/RTCP multiplexing (a=rtcp-mux), at media or session level.
+func rtcpMuxMedias(raw []byte) map[int]bool {
+	var ssd sdp.SessionDescription
+	if ssd.Unmarshal(raw) != nil {
+		return nil
+	}
+
+	session := false
+	for _, attr := range ssd.Attributes {
+		if attr.Key == "rtcp-mux" {
+			session = true
+		}
+	}
+
+	medias := make(map[int]bool)
+	for i, md := range ssd.MediaDescriptions {
+		medias[i] = session
+		for _, attr := range md.Attributes {
+			if attr.Key == "rtcp-mux" {
+				medias[i] = true
+			}
+		}
+	}
+	return medias
+}
+
+// markRTCPMux flags the tracks for which the raw SDP advertises rtcp-mux.
+func markRTCPMux(tracks []*track, raw []byte) {
+	for i, mux := range rtcpMuxMedias(raw) {
+		if mux && i < len(tracks) {
+			tracks[i].rtcpMux = true
+		}
+	}
+}
+
+// rtcpMuxNegotiated returns whether the server sends RTP and RTCP on the same
+// port, as it does when it applies rtcp-mux on a UDP transport.
+func (t *track) rtcpMuxNegotiated() bool {
+	if t.transport == nil || t.transport.ServerPorts == nil {
+		return false
+	}
+	return t.transport.ServerPorts[0] == t.transport.ServerPorts[1]
+}
+
+// rtcpDemux demultiplexes RTP and RTCP (RFC 5761) for the UDP tracks whose
+// SDP advertises rtcp-mux. gortsplib reads RTCP on its own socket and checks
+// the type of the sockets it gets, so they can't be wrapped: instead, the RTCP
+// socket of such a track is bound to the port of its RTP socket, and the
+// kernel steers the RTCP packets to it (see listenReusePort). gortsplib then
+// processes them as usual, with the sender reports, NTP mapping and receiver
+// reports, and no RTCP port is allocated. The server is told to use that
+// single port for both, which also suits the servers applying rtcp-mux.
+type rtcpDemux struct {
+	mutex sync.Mutex
+	// whether the track being set up is demultiplexed, and the RTP port
+	// bound for it :
+	enabled bool
+	rtpPort int
+	// whether the RTCP socket of the track is bound to its RTP port, or why
+	// it couldn't be :
+	done bool
+	err  error
+}
+
+// expect prepares the SETUP of a track, whose SDP advertises rtcp-mux or not.
+func (d *rtcpDemux) expect(mux bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.enabled = mux
+	d.rtpPort = 0
+	d.done = false
+	d.err = nil
+}
+
+// take returns whether the last SETUP demultiplexes RTCP, or why it
+// couldn't.
+func (d *rtcpDemux) take() (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	done, err := d.done, d.err
+	d.enabled = false
+	return done, err
+}
+
+// listen binds a UDP socket of the client. RTP ports are even, and RTCP
+// ones follow them; multicast sockets are not concerned.
+func (d *rtcpDemux) listen(network, address string) (net.PacketConn, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	host, portStr, serr := net.SplitHostPort(address)
+	port, perr := strconv.Atoi(portStr)
+	ip := net.ParseIP(host)
+	if !d.enabled || serr != nil || perr != nil || port == 0 || (ip != nil && ip.IsMulticast()) {
+		return net.ListenPacket(network, address)
+	}
+
+	if port%2 == 0 {
+		pc, err := listenReusePort(network, address, true)
+		if errors.Is(err, errors.ErrUnsupported) {
+			d.enabled = false
+			d.err = err
+			return net.ListenPacket(network, address)
+		}
+		if err == nil {
+			d.rtpPort = port
+		}
+		return pc, err
+	}
+	if port != d.rtpPort+1 {
+		return net.ListenPacket(network, address)
+	}
+
+	pc, err := listenReusePort(network, net.JoinHostPort(host, strconv.Itoa(d.rtpPort)), false)
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	d.done = true
+	d.err = nil
+	return pc, nil
+}
+
+// muxedRTCPFilter recognizes, among the decode errors of the client, the RTCP
+// packets multiplexed with RTP which rtcpDemux doesn't take: over TCP, where
+// they share the interleaved channel of RTP, or when the system can't steer
+// them. gortsplib can't demultiplex them, so they are counted and dropped,
+// with a single warning, instead of being logged one by one. RTP is not
+// affected. Other errors are logged with the rate limit of the malformed
+// packets, as the packets of a new source rejected over UDP come one by one.
+type muxedRTCPFilter struct {
+	dropped atomic.Uint64
+
+	mutex      sync.Mutex
+	logged     int
+	lastLogged time.Time
+	suppressed int
+}
+
+// onDecodeError implements the OnDecodeError function of gortsplib.Client.
+func (f *muxedRTCPFilter) onDecodeError(err error) {
+	var unknownPT liberrors.ErrClientRTPPacketUnknownPayloadType
+	if errors.As(err, &unknownPT) &&
+		unknownPT.PayloadType >= rtcpMuxMinPayloadType && unknownPT.PayloadType <= rtcpMuxMaxPayloadType {
+		if f.dropped.Add(1) == 1 {
+			log.Println("WARNING: received RTCP packets multiplexed with RTP (rtcp-mux), " +
+				"which can't be demultiplexed; they are dropped, RTP reception is not affected")
+		}
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	if f.logged >= validationBurst && now.Sub(f.lastLogged) < validationInterval {
+		f.suppressed++
+		return
+	}
+
+	suffix := ""
+	if f.suppressed != 0 {
+		suffix = fmt.Sprintf(" (%d similar errors suppressed)", f.suppressed)
+	}
+	log.Println(err.Error() + suffix)
+
+	f.logged++
+	f.lastLogged = now
+	f.suppressed = 0
+}
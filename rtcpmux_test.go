@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/headers"
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// muxedSDP declares rtcp-mux on its video media only.
+var muxedSDP = sdpLines(
+	"v=0",
+	"o=- 0 0 IN IP4 127.0.0.1",
+	"s=Test",
+	"t=0 0",
+	"m=video 0 RTP/AVP 96",
+	"a=rtpmap:96 H264/90000",
+	"a=fmtp:96 packetization-mode=1",
+	"a=control:trackID=0",
+	"a=rtcp-mux",
+	"m=audio 0 RTP/AVP 0",
+	"a=control:trackID=1",
+)
+
+// parseTestSDP parses raw into the tracks of its session.
+func parseTestSDP(t *testing.T, raw []byte) []*track {
+	var ssd sdp.SessionDescription
+	err := ssd.Unmarshal(raw)
+	if err != nil {
+		t.Fatalf("invalid SDP: %v", err)
+	}
+	var desc description.Session
+	err = desc.Unmarshal(&ssd)
+	if err != nil {
+		t.Fatalf("invalid session: %v", err)
+	}
+	return newTracks(&desc)
+}
+
+func TestRTCPMuxMedias(t *testing.T) {
+	for _, ca := range []struct {
+		name   string
+		raw    []byte
+		medias map[int]bool
+	}{
+		{
+			name:   "media level",
+			raw:    muxedSDP,
+			medias: map[int]bool{0: true, 1: false},
+		},
+		{
+			name: "session level",
+			raw: sdpLines(
+				"v=0",
+				"o=- 0 0 IN IP4 127.0.0.1",
+				"s=Test",
+				"t=0 0",
+				"a=rtcp-mux",
+				"m=video 0 RTP/AVP 96",
+				"a=rtpmap:96 H264/90000",
+				"m=audio 0 RTP/AVP 0",
+			),
+			medias: map[int]bool{0: true, 1: true},
+		},
+		{
+			name:   "invalid SDP",
+			raw:    []byte("not an SDP"),
+			medias: nil,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			medias := rtcpMuxMedias(ca.raw)
+			if len(medias) != len(ca.medias) {
+				t.Fatalf("got %v, want %v", medias, ca.medias)
+			}
+			for i, mux := range ca.medias {
+				if medias[i] != mux {
+					t.Errorf("media %d: got %v, want %v", i, medias[i], mux)
+				}
+			}
+		})
+	}
+}
+
+func TestRTCPMuxSetupReport(t *testing.T) {
+	tracks := parseTestSDP(t, muxedSDP)
+	markRTCPMux(tracks, muxedSDP)
+
+	// The server applies rtcp-mux on the video track, sending RTP and RTCP
+	// from the same port :
+	tracks[0].setup = true
+	tracks[0].transport = &headers.Transport{
+		Protocol:    headers.TransportProtocolUDP,
+		ClientPorts: &[2]int{5000, 5001},
+		ServerPorts: &[2]int{6000, 6000},
+	}
+	tracks[1].setup = true
+	tracks[1].transport = &headers.Transport{
+		Protocol:    headers.TransportProtocolUDP,
+		ClientPorts: &[2]int{5002, 5003},
+		ServerPorts: &[2]int{6002, 6003},
+	}
+
+	video := tracks[0].setupReport(true)
+	if !video.RTCPMux || !video.RTCPMuxNegotiated {
+		t.Errorf("video: got rtcp_mux %v and rtcp_mux_negotiated %v, want both", video.RTCPMux, video.RTCPMuxNegotiated)
+	}
+	audio := tracks[1].setupReport(true)
+	if audio.RTCPMux || audio.RTCPMuxNegotiated {
+		t.Errorf("audio: got rtcp_mux %v and rtcp_mux_negotiated %v, want neither",
+			audio.RTCPMux, audio.RTCPMuxNegotiated)
+	}
+
+	buf, err := json.Marshal(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"rtcp_mux":true`, `"rtcp_mux_negotiated":true`} {
+		if !strings.Contains(string(buf), field) {
+			t.Errorf("setup summary %s lacks %s", buf, field)
+		}
+	}
+	buf, err = json.Marshal(audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "rtcp_mux") {
+		t.Errorf("setup summary %s reports rtcp-mux", buf)
+	}
+}
+
+func TestRTCPDemux(t *testing.T) {
+	d := &rtcpDemux{}
+	d.expect(true)
+
+	// Bind the pair of sockets as gortsplib does, on a free even port :
+	var rtpConn, rtcpConn net.PacketConn
+	for port := 40000; port < 41000; port += 2 {
+		pc, err := d.listen("udp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("RTCP can't be steered on this system")
+		}
+		if err != nil {
+			continue
+		}
+		rtpConn = pc
+		rtcpConn, err = d.listen("udp4", net.JoinHostPort("127.0.0.1", strconv.Itoa(port+1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+	if rtpConn == nil {
+		t.Fatal("no port pair available")
+	}
+	defer rtpConn.Close()
+	defer rtcpConn.Close()
+
+	done, err := d.take()
+	if !done || err != nil {
+		t.Fatalf("got %v, %v, want the pair demultiplexed", done, err)
+	}
+	if rtpConn.LocalAddr().String() != rtcpConn.LocalAddr().String() {
+		t.Fatalf("RTP bound to %v and RTCP to %v, want the same port", rtpConn.LocalAddr(), rtcpConn.LocalAddr())
+	}
+
+	rtpPkt, err := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96, SequenceNumber: 1}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rtcpPkt, err := (&rtcp.SenderReport{SSRC: 1}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err := net.Dial("udp4", rtpConn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	for i := 0; i < 3; i++ {
+		for _, pkt := range [][]byte{rtpPkt, rtcpPkt} {
+			_, err = server.Write(pkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	read := func(pc net.PacketConn) []byte {
+		buf := make([]byte, 1500)
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buf[:n]
+	}
+	for i := 0; i < 3; i++ {
+		if got := read(rtpConn); !bytes.Equal(got, rtpPkt) {
+			t.Errorf("RTP socket got %x, want %x", got, rtpPkt)
+		}
+		if got := read(rtcpConn); !bytes.Equal(got, rtcpPkt) {
+			t.Errorf("RTCP socket got %x, want %x", got, rtcpPkt)
+		}
+	}
+}
+
+func TestRTCPDemuxDisabled(t *testing.T) {
+	d := &rtcpDemux{}
+	d.expect(false)
+
+	pc, err := d.listen("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	done, err := d.take()
+	if done || err != nil {
+		t.Errorf("got %v, %v, want nothing demultiplexed", done, err)
+	}
+}
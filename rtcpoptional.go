@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync"
+)
+
+// number of consecutive port pairs whose RTCP port can't be bound after
+// which -rtcp-optional gives RTCP up; the RTSP library tries another pair
+// after each failure.
+const rtcpBindAttempts = 3
+
+// optionalRTCP wraps the listen function of the client, with -rtcp-optional,
+// so that the SETUP of a UDP track succeeds when its RTCP port can't be
+// bound, while its RTP port can. The RTCP socket is then bound to an
+// ephemeral port instead: the server keeps sending RTCP to the announced
+// port, so no RTCP is received for the track, and the features relying on
+// it, such as NTP mapping, are disabled. Multicast sockets are not concerned.
+type optionalRTCP struct {
+	listen func(network, address string) (net.PacketConn, error)
+
+	mutex sync.Mutex
+	// last RTP port bound, and number of its successive failures to bind
+	// the RTCP port of a pair :
+	rtpPort  int
+	failures int
+	// RTCP port which was given up during the current SETUP, if any, and
+	// why :
+	port int
+	err  error
+}
+
+// listenPacket implements the ListenPacket function of gortsplib.Client.
+// RTP ports are even, and RTCP ones follow them.
+func (o *optionalRTCP) listenPacket(network, address string) (net.PacketConn, error) {
+	pc, err := o.listen(network, address)
+
+	host, portStr, serr := net.SplitHostPort(address)
+	port, perr := strconv.Atoi(portStr)
+	if serr != nil || perr != nil || host != "" {
+		return pc, err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if port%2 == 0 {
+		if err == nil {
+			o.rtpPort = port
+		}
+		return pc, err
+	}
+	if err == nil || port != o.rtpPort+1 {
+		o.failures = 0
+		return pc, err
+	}
+
+	o.failures++
+	if o.failures < rtcpBindAttempts {
+		return nil, err
+	}
+
+	pc, ferr := o.listen(network, net.JoinHostPort(host, "0"))
+	if ferr != nil {
+		return nil, err
+	}
+	o.failures = 0
+	o.port = port
+	o.err = err
+	return pc, nil
+}
+
+// take returns the RTCP port given up during the last SETUP and why, or a
+// nil error, and forgets them.
+func (o *optionalRTCP) take() (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	port, err := o.port, o.err
+	o.port, o.err = 0, nil
+	return port, err
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"github.com/pion/rtcp"
+)
+
+// maximum number of sequence numbers listed by an RLE block; longer lists
+// are truncated, the counts remain exact.
+const xrMaxSequenceNumbers = 256
+
+// xrRecord is the representation of an RTCP Extended Report (RFC 3611) in
+// the output, with a record per block.
+type xrRecord struct {
+	SenderSSRC uint32          `json:"sender_ssrc"`
+	Blocks     []xrBlockRecord `json:"blocks"`
+}
+
+// xrBlockRecord is a block of an Extended Report. The blocks of unknown
+// types are kept as raw bytes.
+type xrBlockRecord struct {
+	Type      string `json:"type"`
+	BlockType uint8  `json:"block_type"`
+	Report    any    `json:"report,omitempty"`
+	Raw       []byte `json:"raw,omitempty"`
+}
+
+// xrRLERecord is a Loss RLE or a Duplicate RLE block (RFC 3611, 4.1 and
+// 4.2): the packets of the range which were lost, or duplicated.
+type xrRLERecord struct {
+	SSRC     uint32 `json:"ssrc"`
+	BeginSeq uint16 `json:"begin_seq"`
+	EndSeq   uint16 `json:"end_seq"`
+	Thinning uint8  `json:"thinning"`
+	// number of packets of the range reported lost or duplicated, and of
+	// the other ones, and the sequence numbers of the former :
+	Marked    int      `json:"marked"`
+	Unmarked  int      `json:"unmarked"`
+	Seqs      []uint16 `json:"seqs,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+}
+
+// xrStatisticsRecord is a Statistics Summary block (RFC 3611, 4.6). Jitter
+// values are in timestamp units.
+type xrStatisticsRecord struct {
+	SSRC        uint32  `json:"ssrc"`
+	BeginSeq    uint16  `json:"begin_seq"`
+	EndSeq      uint16  `json:"end_seq"`
+	LostPackets *uint32 `json:"lost_packets,omitempty"`
+	DupPackets  *uint32 `json:"dup_packets,omitempty"`
+	MinJitter   *uint32 `json:"min_jitter,omitempty"`
+	MaxJitter   *uint32 `json:"max_jitter,omitempty"`
+	MeanJitter  *uint32 `json:"mean_jitter,omitempty"`
+	DevJitter   *uint32 `json:"dev_jitter,omitempty"`
+	// TTL (IPv4) or hop limit (IPv6) statistics :
+	HopLimit     string `json:"hop_limit,omitempty"`
+	MinHopLimit  uint8  `json:"min_hop_limit,omitempty"`
+	MaxHopLimit  uint8  `json:"max_hop_limit,omitempty"`
+	MeanHopLimit uint8  `json:"mean_hop_limit,omitempty"`
+	DevHopLimit  uint8  `json:"dev_hop_limit,omitempty"`
+}
+
+// xrVoIPMetricsRecord is a VoIP Metrics block (RFC 3611, 4.7), converted
+// to usual units. Metrics which the reporter marks unavailable are omitted.
+type xrVoIPMetricsRecord struct {
+	SSRC              uint32   `json:"ssrc"`
+	LossPercent       float64  `json:"loss_percent"`
+	DiscardPercent    float64  `json:"discard_percent"`
+	BurstDensity      float64  `json:"burst_density_percent"`
+	GapDensity        float64  `json:"gap_density_percent"`
+	BurstDurationMS   uint16   `json:"burst_duration_ms"`
+	GapDurationMS     uint16   `json:"gap_duration_ms"`
+	RoundTripDelayMS  uint16   `json:"round_trip_delay_ms"`
+	EndSystemDelayMS  uint16   `json:"end_system_delay_ms"`
+	SignalLevelDBm    *int8    `json:"signal_level_dbm,omitempty"`
+	NoiseLevelDBm     *int8    `json:"noise_level_dbm,omitempty"`
+	ResidualEchoDB    *uint8   `json:"residual_echo_return_loss_db,omitempty"`
+	Gmin              uint8    `json:"gmin"`
+	RFactor           *uint8   `json:"r_factor,omitempty"`
+	ExternalRFactor   *uint8   `json:"external_r_factor,omitempty"`
+	MOSLQ             *float64 `json:"mos_lq,omitempty"`
+	MOSCQ             *float64 `json:"mos_cq,omitempty"`
+	RXConfig          uint8    `json:"rx_config"`
+	JitterBufferMS    uint16   `json:"jitter_buffer_nominal_ms"`
+	JitterBufferMaxMS uint16   `json:"jitter_buffer_max_ms"`
+	JitterBufferAbsMS uint16   `json:"jitter_buffer_abs_max_ms"`
+}
+
+// newXRRecord returns the record of an Extended Report.
+func newXRRecord(xr *rtcp.ExtendedReport) *xrRecord {
+	rec := &xrRecord{
+		SenderSSRC: xr.SenderSSRC,
+		Blocks:     make([]xrBlockRecord, len(xr.Reports)),
+	}
+
+	for i, report := range xr.Reports {
+		var b xrBlockRecord
+		switch report := report.(type) {
+		case *rtcp.LossRLEReportBlock:
+			b.Type = "loss_rle"
+			b.BlockType = rtcp.LossRLEReportBlockType
+			// a set bit means that the packet was received :
+			b.Report = newXRRLERecord(report.SSRC, report.BeginSeq, report.EndSeq, report.T, report.Chunks, false)
+
+		case *rtcp.DuplicateRLEReportBlock:
+			b.Type = "duplicate_rle"
+			b.BlockType = rtcp.DuplicateRLEReportBlockType
+			// a set bit means that the packet was duplicated :
+			b.Report = newXRRLERecord(report.SSRC, report.BeginSeq, report.EndSeq, report.T, report.Chunks, true)
+
+		case *rtcp.PacketReceiptTimesReportBlock:
+			b.Type = "packet_receipt_times"
+			b.BlockType = rtcp.PacketReceiptTimesReportBlockType
+			b.Report = report
+
+		case *rtcp.ReceiverReferenceTimeReportBlock:
+			b.Type = "receiver_reference_time"
+			b.BlockType = rtcp.ReceiverReferenceTimeReportBlockType
+			b.Report = report
+
+		case *rtcp.DLRRReportBlock:
+			b.Type = "dlrr"
+			b.BlockType = rtcp.DLRRReportBlockType
+			b.Report = report
+
+		case *rtcp.StatisticsSummaryReportBlock:
+			b.Type = "statistics_summary"
+			b.BlockType = rtcp.StatisticsSummaryReportBlockType
+			b.Report = newXRStatisticsRecord(report)
+
+		case *rtcp.VoIPMetricsReportBlock:
+			b.Type = "voip_metrics"
+			b.BlockType = rtcp.VoIPMetricsReportBlockType
+			b.Report = newXRVoIPMetricsRecord(report)
+
+		case *rtcp.UnknownReportBlock:
+			b.Type = "unknown"
+			b.BlockType = uint8(report.BlockType)
+			b.Raw = report.Bytes
+		}
+		rec.Blocks[i] = b
+	}
+
+	return rec
+}
+
+// newXRRLERecord decodes the chunks of a run-length encoded block. Packets
+// whose bit equals marked are counted and listed. Sequence numbers are
+// spaced by 2^thinning.
+func newXRRLERecord(ssrc uint32, beginSeq uint16, endSeq uint16, thinning uint8,
+	chunks []rtcp.Chunk, marked bool,
+) *xrRLERecord {
+	r := &xrRLERecord{
+		SSRC:     ssrc,
+		BeginSeq: beginSeq,
+		EndSeq:   endSeq,
+		Thinning: thinning,
+	}
+
+	seq := beginSeq
+	step := uint16(1) << thinning
+	add := func(bit bool) {
+		if bit == marked {
+			r.Marked++
+			if len(r.Seqs) < xrMaxSequenceNumbers {
+				r.Seqs = append(r.Seqs, seq)
+			} else {
+				r.Truncated = true
+			}
+		} else {
+			r.Unmarked++
+		}
+		seq += step
+	}
+
+	for _, c := range chunks {
+		switch c.Type() {
+		case rtcp.RunLengthChunkType:
+			runType, _ := c.RunType()
+			for n := c.Value(); n > 0; n-- {
+				add(runType == 1)
+			}
+
+		case rtcp.BitVectorChunkType:
+			for bit := 14; bit >= 0; bit-- {
+				add(c.Value()&(1<<bit) != 0)
+			}
+		}
+	}
+
+	return r
+}
+
+// newXRStatisticsRecord returns the record of a Statistics Summary block,
+// with the statistics that the flags of the block declare.
+func newXRStatisticsRecord(b *rtcp.StatisticsSummaryReportBlock) *xrStatisticsRecord {
+	r := &xrStatisticsRecord{
+		SSRC:     b.SSRC,
+		BeginSeq: b.BeginSeq,
+		EndSeq:   b.EndSeq,
+	}
+	if b.LossReports {
+		r.LostPackets = &b.LostPackets
+	}
+	if b.DuplicateReports {
+		r.DupPackets = &b.DupPackets
+	}
+	if b.JitterReports {
+		r.MinJitter = &b.MinJitter
+		r.MaxJitter = &b.MaxJitter
+		r.MeanJitter = &b.MeanJitter
+		r.DevJitter = &b.DevJitter
+	}
+
+	switch b.TTLorHopLimit {
+	case rtcp.ToHIPv4:
+		r.HopLimit = "ipv4_ttl"
+	case rtcp.ToHIPv6:
+		r.HopLimit = "ipv6_hop_limit"
+	}
+	if r.HopLimit != "" {
+		r.MinHopLimit = b.MinTTLOrHL
+		r.MaxHopLimit = b.MaxTTLOrHL
+		r.MeanHopLimit = b.MeanTTLOrHL
+		r.DevHopLimit = b.DevTTLOrHL
+	}
+
+	return r
+}
+
+// Values of the VoIP Metrics block meaning that a metric is unavailable :
+const (
+	xrVoIPLevelUnavailable   = 127
+	xrVoIPRERLUnavailable    = 127
+	xrVoIPRFactorUnavailable = 127
+	xrVoIPMOSUnavailable     = 127
+)
+
+// newXRVoIPMetricsRecord returns the record of a VoIP Metrics block.
+func newXRVoIPMetricsRecord(b *rtcp.VoIPMetricsReportBlock) *xrVoIPMetricsRecord {
+	// rates are fractions of 256 :
+	percent := func(v uint8) float64 {
+		return float64(v) * 100 / 256
+	}
+
+	r := &xrVoIPMetricsRecord{
+		SSRC:              b.SSRC,
+		LossPercent:       percent(b.LossRate),
+		DiscardPercent:    percent(b.DiscardRate),
+		BurstDensity:      percent(b.BurstDensity),
+		GapDensity:        percent(b.GapDensity),
+		BurstDurationMS:   b.BurstDuration,
+		GapDurationMS:     b.GapDuration,
+		RoundTripDelayMS:  b.RoundTripDelay,
+		EndSystemDelayMS:  b.EndSystemDelay,
+		Gmin:              b.Gmin,
+		RXConfig:          b.RXConfig,
+		JitterBufferMS:    b.JBNominal,
+		JitterBufferMaxMS: b.JBMaximum,
+		JitterBufferAbsMS: b.JBAbsMax,
+	}
+
+	// levels are signed dBm :
+	if b.SignalLevel != xrVoIPLevelUnavailable {
+		v := int8(b.SignalLevel)
+		r.SignalLevelDBm = &v
+	}
+	if b.NoiseLevel != xrVoIPLevelUnavailable {
+		v := int8(b.NoiseLevel)
+		r.NoiseLevelDBm = &v
+	}
+	if b.RERL != xrVoIPRERLUnavailable {
+		v := b.RERL
+		r.ResidualEchoDB = &v
+	}
+	if b.RFactor != xrVoIPRFactorUnavailable {
+		v := b.RFactor
+		r.RFactor = &v
+	}
+	if b.ExtRFactor != xrVoIPRFactorUnavailable {
+		v := b.ExtRFactor
+		r.ExternalRFactor = &v
+	}
+	// MOS are in tenths :
+	if b.MOSLQ != xrVoIPMOSUnavailable {
+		v := float64(b.MOSLQ) / 10
+		r.MOSLQ = &v
+	}
+	if b.MOSCQ != xrVoIPMOSUnavailable {
+		v := float64(b.MOSCQ) / 10
+		r.MOSCQ = &v
+	}
+
+	return r
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+)
+
+// rtpMapping is the encoding of a payload type, as given by its a=rtpmap
+// attribute, or by RFC 3551 for static payload types declared without one.
+type rtpMapping struct {
+	Encoding  string
+	ClockRate int
+	// zero when not given.
+	Channels int
+}
+
+// staticPayloadTypes are the static payload types of RFC 3551, tables 4
+// and 5, which SDPs may declare without a=rtpmap.
+var staticPayloadTypes = map[uint8]rtpMapping{
+	0:  {"PCMU", 8000, 1},
+	3:  {"GSM", 8000, 1},
+	4:  {"G723", 8000, 1},
+	5:  {"DVI4", 8000, 1},
+	6:  {"DVI4", 16000, 1},
+	7:  {"LPC", 8000, 1},
+	8:  {"PCMA", 8000, 1},
+	9:  {"G722", 8000, 1},
+	10: {"L16", 44100, 2},
+	11: {"L16", 44100, 1},
+	12: {"QCELP", 8000, 1},
+	13: {"CN", 8000, 1},
+	14: {"MPA", 90000, 0},
+	15: {"G728", 8000, 1},
+	16: {"DVI4", 11025, 1},
+	17: {"DVI4", 22050, 1},
+	18: {"G729", 8000, 1},
+	25: {"CelB", 90000, 0},
+	26: {"JPEG", 90000, 0},
+	28: {"nv", 90000, 0},
+	31: {"H261", 90000, 0},
+	32: {"MPV", 90000, 0},
+	33: {"MP2T", 90000, 0},
+	34: {"H263", 90000, 0},
+}
+
+// parseRTPMap returns the encoding of a format, from the value of its
+// a=rtpmap attribute (<encoding name>/<clock rate>[/<channels>]) or from its
+// static payload type, or nil when neither gives it.
+func parseRTPMap(forma format.Format) *rtpMapping {
+	if value := forma.RTPMap(); value != "" {
+		parts := strings.Split(value, "/")
+		m := &rtpMapping{Encoding: strings.TrimSpace(parts[0])}
+		if len(parts) >= 2 {
+			m.ClockRate, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+		if len(parts) >= 3 {
+			m.Channels, _ = strconv.Atoi(strings.TrimSpace(parts[2]))
+		}
+		if m.Encoding != "" {
+			return m
+		}
+	}
+
+	if m, ok := staticPayloadTypes[forma.PayloadType()]; ok {
+		return &m
+	}
+	return nil
+}
+
+// String returns the mapping as in a=rtpmap, e.g. speex/16000, leaving out
+// a single channel.
+func (m *rtpMapping) String() string {
+	s := m.Encoding
+	if m.ClockRate != 0 {
+		s += "/" + strconv.Itoa(m.ClockRate)
+		if m.Channels > 1 {
+			s += "/" + strconv.Itoa(m.Channels)
+		}
+	}
+	return s
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/pion/rtcp"
+)
+
+// interval between two receiver reference times sent with -measure-rtt.
+const rttInterval = 5 * time.Second
+
+// number of reference times remembered, and of samples of the rolling
+// average of the round-trip time.
+const rttWindow = 16
+
+// rttMeter measures the round-trip time to the server of a track, with
+// -measure-rtt. A client receiving media sends no sender reports, so the
+// LSR and DLSR fields of the reports of the server don't refer to it:
+// the client sends instead Receiver Reference Time blocks in RTCP Extended
+// Reports (RFC 3611, section 4.4), which the server echoes in DLRR blocks
+// (section 4.5) with the delay since their reception. The round-trip time
+// is the time elapsed since the reference time was sent, minus that delay.
+// Reception reports of the server carrying the client SSRC, with LSR and
+// DLSR, are measured the same way.
+type rttMeter struct {
+	// SSRC identifying the client in the reports.
+	ssrc uint32
+
+	mutex sync.Mutex
+	// local send time of the last reference times, by the middle 32 bits
+	// of their NTP timestamp.
+	sent  map[uint32]time.Time
+	order []uint32
+
+	samples uint64
+	min     time.Duration
+	max     time.Duration
+	last    []time.Duration
+}
+
+// newRTTMeter allocates the meter of a track.
+func newRTTMeter() *rttMeter {
+	return &rttMeter{
+		ssrc: rand.Uint32(),
+		sent: make(map[uint32]time.Time),
+	}
+}
+
+// ntpTimestamp returns t as a 64-bit NTP timestamp: seconds since 1900,
+// with a 32-bit fractional part.
+func ntpTimestamp(t time.Time) uint64 {
+	d := t.Sub(ntpEpoch)
+	secs := uint64(d / time.Second)
+	frac := uint64(d%time.Second) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// referenceTime returns the Extended Report carrying the reference time of
+// now, and remembers it.
+func (m *rttMeter) referenceTime(now time.Time) *rtcp.ExtendedReport {
+	ntp := ntpTimestamp(now)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	mid := uint32(ntp >> 16)
+	if len(m.order) == rttWindow {
+		delete(m.sent, m.order[0])
+		m.order = m.order[1:]
+	}
+	m.sent[mid] = now
+	m.order = append(m.order, mid)
+
+	return &rtcp.ExtendedReport{
+		SenderSSRC: m.ssrc,
+		Reports: []rtcp.ReportBlock{
+			&rtcp.ReceiverReferenceTimeReportBlock{NTPTimestamp: ntp},
+		},
+	}
+}
+
+// sendReferenceTimes sends a reference time to the server of every track
+// measuring its round-trip time, every rttInterval until ctx is done.
+func sendReferenceTimes(ctx context.Context, client *gortsplib.Client, tracks []*track) {
+	ticker := time.NewTicker(rttInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, t := range tracks {
+			if t.rtt == nil {
+				continue
+			}
+			err := client.WritePacketRTCP(t.media, t.rtt.referenceTime(time.Now()))
+			if err != nil {
+				log.Printf("Error sending receiver reference time for track %s: %v", t, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// onPacket measures the round-trip time from the DLRR blocks and reception
+// reports of an RTCP packet of the server received at now, which refer to
+// the client.
+func (m *rttMeter) onPacket(pkt rtcp.Packet, now time.Time) {
+	var reports []rtcp.ReceptionReport
+	switch pkt := pkt.(type) {
+	case *rtcp.ExtendedReport:
+		for _, block := range pkt.Reports {
+			if dlrr, ok := block.(*rtcp.DLRRReportBlock); ok {
+				for _, r := range dlrr.Reports {
+					if r.SSRC == m.ssrc {
+						m.measure(r.LastRR, r.DLRR, now)
+					}
+				}
+			}
+		}
+		return
+	case *rtcp.SenderReport:
+		reports = pkt.Reports
+	case *rtcp.ReceiverReport:
+		reports = pkt.Reports
+	}
+	for _, r := range reports {
+		if r.SSRC == m.ssrc {
+			m.measure(r.LastSenderReport, r.Delay, now)
+		}
+	}
+}
+
+// measure records the round-trip time of the reference time whose middle
+// 32 bits are lrr, held by the server for delay, in units of 1/65536
+// seconds.
+func (m *rttMeter) measure(lrr, delay uint32, now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sentAt, ok := m.sent[lrr]
+	if lrr == 0 || !ok {
+		return
+	}
+	rtt := now.Sub(sentAt) - time.Duration(delay)*time.Second/65536
+	if rtt < 0 {
+		rtt = 0
+	}
+
+	if m.samples == 0 || rtt < m.min {
+		m.min = rtt
+	}
+	if rtt > m.max {
+		m.max = rtt
+	}
+	m.samples++
+	if len(m.last) == rttWindow {
+		m.last = m.last[1:]
+	}
+	m.last = append(m.last, rtt)
+}
+
+// rttReport is the round-trip time of a track, in milliseconds, over the
+// whole session, and averaged over its last rttWindow samples.
+type rttReport struct {
+	Samples uint64  `json:"samples"`
+	MinMS   float64 `json:"min_ms,omitempty"`
+	MaxMS   float64 `json:"max_ms,omitempty"`
+	AvgMS   float64 `json:"avg_ms,omitempty"`
+}
+
+// report returns a snapshot of the measures.
+func (m *rttMeter) report() *rttReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r := &rttReport{Samples: m.samples}
+	if m.samples == 0 {
+		return r
+	}
+	var sum time.Duration
+	for _, rtt := range m.last {
+		sum += rtt
+	}
+	r.MinMS = durationMS(m.min)
+	r.MaxMS = durationMS(m.max)
+	r.AvgMS = durationMS(sum / time.Duration(len(m.last)))
+	return r
+}
+
+// checkRTT warns about the tracks whose server never answered the reference
+// times, which servers not supporting RFC 3611 ignore.
+func checkRTT(tracks []trackReport) {
+	for _, t := range tracks {
+		if t.RTT != nil && t.RTT.Samples == 0 && t.Packets != 0 {
+			log.Printf("WARNING: the server of track #%d never answered the receiver reference times (RFC 3611 DLRR): "+
+				"its round-trip time couldn't be measured", t.Index)
+		}
+	}
+}
+
+// setupRTTMeters measures the round-trip time to the server on the tracks
+// whose RTCP is processed, with -measure-rtt.
+func setupRTTMeters(tracks []*track) {
+	for _, t := range tracks {
+		switch {
+		case !t.setup:
+		case !t.rtcp:
+			log.Printf("WARNING: RTCP of track %s is not processed, its round-trip time won't be measured", t)
+		default:
+			t.rtt = newRTTMeter()
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/pion/rtp"
+)
+
+// rtxPayloadTypes returns the retransmission payload types (RFC 4588)
+// declared by the media, associated with the payload type they retransmit
+// (the apt parameter).
+func rtxPayloadTypes(medi *description.Media) map[uint8]uint8 {
+	var rtx map[uint8]uint8
+	for _, forma := range medi.Formats {
+		if !strings.HasPrefix(strings.ToLower(forma.RTPMap()), "rtx/") {
+			continue
+		}
+		apt, err := strconv.ParseUint(forma.FMTP()["apt"], 10, 7)
+		if err != nil {
+			continue
+		}
+		if rtx == nil {
+			rtx = make(map[uint8]uint8)
+		}
+		rtx[forma.PayloadType()] = uint8(apt)
+	}
+	return rtx
+}
+
+// aptFormat returns the format of the media retransmitted with apt, or nil.
+func aptFormat(medi *description.Media, apt uint8) format.Format {
+	for _, forma := range medi.Formats {
+		if forma.PayloadType() == apt {
+			return forma
+		}
+	}
+	return nil
+}
+
+// unwrapRTX restores the original packet carried by a retransmission packet:
+// its payload starts with the original sequence number, and it uses the
+// associated payload type and the SSRC of the original stream.
+func unwrapRTX(pkt *rtp.Packet, apt uint8, ssrc uint32) (*rtp.Packet, error) {
+	if len(pkt.Payload) < 2 {
+		return nil, fmt.Errorf("RTX packet too short")
+	}
+
+	orig := &rtp.Packet{
+		Header:  pkt.Header,
+		Payload: pkt.Payload[2:],
+	}
+	orig.Header.SequenceNumber = binary.BigEndian.Uint16(pkt.Payload)
+	orig.Header.PayloadType = apt
+	orig.Header.SSRC = ssrc
+	return orig, nil
+}
+
+// maximum number of packets held after a gap while waiting for its
+// retransmission, before giving up on it.
+const rtxReorderWindow = 64
+
+// orderedPacket is a packet released by an rtxReorderer.
+type orderedPacket struct {
+	pkt   *rtp.Packet
+	forma format.Format
+	// number of packets of the track received up to this one, or zero for
+	// a retransmission.
+	n             uint64
+	retransmitted bool
+}
+
+// rtxReorderer puts the retransmitted packets of a track back in sequence
+// order, so that the outputs, the depacketizer first, get them in the gaps
+// they fill. Packets following a gap are held until it is filled, for at
+// most wait (-rtx-reorder-wait) or until rtxReorderWindow packets are held,
+// which delays the outputs of the track by as much. Retransmissions arriving
+// after that are delivered late, out of order. The packets still held when
+// the session ends are delivered by close.
+type rtxReorderer struct {
+	wait    time.Duration
+	deliver func(orderedPacket)
+
+	mutex    sync.Mutex
+	closed   bool
+	started  bool
+	expected uint16
+	pending  map[uint16]orderedPacket
+	// when the packets held started waiting for the gap at expected, and
+	// the timer giving up on it.
+	waitingSince time.Time
+	timer        *time.Timer
+}
+
+// newRTXReorderer allocates a reorderer. start must be called before push.
+func newRTXReorderer() *rtxReorderer {
+	return &rtxReorderer{pending: make(map[uint16]orderedPacket, rtxReorderWindow)}
+}
+
+// start sets how long the packets following a gap are held, and the function
+// receiving the packets released.
+func (r *rtxReorderer) start(wait time.Duration, deliver func(orderedPacket)) {
+	r.wait = wait
+	r.deliver = deliver
+}
+
+// push delivers the packets released in sequence order by p, if any.
+func (r *rtxReorderer) push(p orderedPacket) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	for _, p := range r.release(p, time.Now()) {
+		r.deliver(p)
+	}
+}
+
+// expire gives up on the gap whose packets waited too long, once the timer
+// fires.
+func (r *rtxReorderer) expire() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.timer = nil
+	if r.closed {
+		return
+	}
+	for _, p := range r.giveUp(time.Now()) {
+		r.deliver(p)
+	}
+}
+
+// close delivers the packets still held, in sequence order, and ignores the
+// following ones.
+func (r *rtxReorderer) close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	for _, p := range r.flush() {
+		r.deliver(p)
+	}
+}
+
+// release returns the packets released in sequence order by p, received at
+// now, if any.
+func (r *rtxReorderer) release(p orderedPacket, now time.Time) []orderedPacket {
+	seq := p.pkt.SequenceNumber
+	if !r.started {
+		r.started = true
+		r.expected = seq
+	}
+
+	diff := int16(seq - r.expected)
+	switch {
+	case diff < 0 && (p.retransmitted || diff > -rtxReorderWindow):
+		// Late, the gap was given up on :
+		return []orderedPacket{p}
+
+	case diff < 0 || diff >= rtxReorderWindow:
+		// Jump of the sequence numbers, there is nothing to wait for :
+		out := r.flush()
+		r.expected = seq + 1
+		return append(out, p)
+
+	case diff > 0:
+		if len(r.pending) == 0 {
+			r.waitingSince = now
+		}
+		r.pending[seq] = p
+		if len(r.pending) >= rtxReorderWindow {
+			return r.skip(nil, now)
+		}
+		r.schedule(now)
+		return nil
+	}
+
+	r.expected++
+	return r.drain([]orderedPacket{p}, now)
+}
+
+// giveUp returns the packets released at now, when the gap at expected was
+// waited on for wait.
+func (r *rtxReorderer) giveUp(now time.Time) []orderedPacket {
+	if len(r.pending) == 0 || now.Sub(r.waitingSince) < r.wait {
+		r.schedule(now)
+		return nil
+	}
+	return r.skip(nil, now)
+}
+
+// skip gives up on the gaps before the oldest packet held, and appends to out
+// the packets which follow.
+func (r *rtxReorderer) skip(out []orderedPacket, now time.Time) []orderedPacket {
+	for {
+		if _, ok := r.pending[r.expected]; ok {
+			break
+		}
+		r.expected++
+	}
+	return r.drain(out, now)
+}
+
+// drain appends to out the packets held which follow without gap. The
+// packets still held then wait for the next gap, from now.
+func (r *rtxReorderer) drain(out []orderedPacket, now time.Time) []orderedPacket {
+	n := len(out)
+	out = r.take(out)
+	if len(out) != n {
+		r.waitingSince = now
+	}
+	r.schedule(now)
+	return out
+}
+
+// take appends to out the packets held which follow expected without gap.
+func (r *rtxReorderer) take(out []orderedPacket) []orderedPacket {
+	for {
+		p, ok := r.pending[r.expected]
+		if !ok {
+			return out
+		}
+		delete(r.pending, r.expected)
+		out = append(out, p)
+		r.expected++
+	}
+}
+
+// schedule arms the timer giving up on the current gap, while packets are
+// held.
+func (r *rtxReorderer) schedule(now time.Time) {
+	if len(r.pending) == 0 || r.timer != nil || r.deliver == nil {
+		return
+	}
+	r.timer = time.AfterFunc(r.waitingSince.Add(r.wait).Sub(now), r.expire)
+}
+
+// flush returns every packet held, in sequence order.
+func (r *rtxReorderer) flush() []orderedPacket {
+	var out []orderedPacket
+	for len(r.pending) != 0 {
+		for {
+			if _, ok := r.pending[r.expected]; ok {
+				break
+			}
+			r.expected++
+		}
+		out = r.take(out)
+	}
+	return out
+}
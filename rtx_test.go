@@ -0,0 +1,121 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// reorderRecorder records the sequence numbers delivered by a reorderer.
+type reorderRecorder struct {
+	mutex sync.Mutex
+	seqs  []uint16
+}
+
+func (r *reorderRecorder) deliver(p orderedPacket) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.seqs = append(r.seqs, p.pkt.SequenceNumber)
+}
+
+func (r *reorderRecorder) take() []uint16 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	seqs := r.seqs
+	r.seqs = nil
+	return seqs
+}
+
+func orderedSeq(seq uint16, retransmitted bool) orderedPacket {
+	return orderedPacket{
+		pkt:           &rtp.Packet{Header: rtp.Header{SequenceNumber: seq}},
+		retransmitted: retransmitted,
+	}
+}
+
+func TestRTXReordererFillsGap(t *testing.T) {
+	var rec reorderRecorder
+	r := newRTXReorderer()
+	r.start(time.Hour, rec.deliver)
+	defer r.close()
+
+	for _, seq := range []uint16{10, 11, 13, 14} {
+		r.push(orderedSeq(seq, false))
+	}
+	if got := rec.take(); !slices.Equal(got, []uint16{10, 11}) {
+		t.Fatalf("got %v before the retransmission, want [10 11]", got)
+	}
+
+	r.push(orderedSeq(12, true))
+	if got := rec.take(); !slices.Equal(got, []uint16{12, 13, 14}) {
+		t.Errorf("got %v after the retransmission, want [12 13 14]", got)
+	}
+}
+
+func TestRTXReordererWindow(t *testing.T) {
+	var rec reorderRecorder
+	r := newRTXReorderer()
+	r.start(time.Hour, rec.deliver)
+	defer r.close()
+
+	r.push(orderedSeq(0, false))
+	for seq := uint16(2); seq < 2+rtxReorderWindow; seq++ {
+		r.push(orderedSeq(seq, false))
+	}
+	got := rec.take()
+	if len(got) != 1+rtxReorderWindow || got[1] != 2 {
+		t.Errorf("got %v, want every packet once the window is full", got)
+	}
+}
+
+func TestRTXReordererWait(t *testing.T) {
+	var rec reorderRecorder
+	r := newRTXReorderer()
+	r.start(20*time.Millisecond, rec.deliver)
+	defer r.close()
+
+	for _, seq := range []uint16{10, 12, 13} {
+		r.push(orderedSeq(seq, false))
+	}
+	rec.take()
+
+	// The packets held are released once the wait is over, with no other
+	// packet arriving :
+	deadline := time.Now().Add(time.Second)
+	var got []uint16
+	for len(got) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		got = append(got, rec.take()...)
+	}
+	if !slices.Equal(got, []uint16{12, 13}) {
+		t.Fatalf("got %v after the wait, want [12 13]", got)
+	}
+
+	// and the late retransmission is delivered all the same :
+	r.push(orderedSeq(11, true))
+	if got := rec.take(); !slices.Equal(got, []uint16{11}) {
+		t.Errorf("got %v for the late retransmission, want [11]", got)
+	}
+}
+
+func TestRTXReordererClose(t *testing.T) {
+	var rec reorderRecorder
+	r := newRTXReorderer()
+	r.start(time.Hour, rec.deliver)
+
+	for _, seq := range []uint16{1, 3, 5} {
+		r.push(orderedSeq(seq, false))
+	}
+	r.close()
+	if got := rec.take(); !slices.Equal(got, []uint16{1, 3, 5}) {
+		t.Errorf("got %v, want the packets held delivered on close", got)
+	}
+
+	r.push(orderedSeq(6, false))
+	if got := rec.take(); len(got) != 0 {
+		t.Errorf("got %v after close, want nothing", got)
+	}
+}
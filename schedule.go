@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// time to wait before capturing again, when a capture ended while its window
+// is still open.
+const scheduleRetryDelay = 10 * time.Second
+
+// errWindowClosed is the cause of the end of a capture at the close of its
+// window.
+var errWindowClosed = errors.New("capture window closed")
+
+// weekdays are the names of the days accepted by -schedule.
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// scheduleWindow is a window of -schedule: from start to end, on the given
+// days. A window ending before it starts ends on the next day.
+type scheduleWindow struct {
+	days                   [7]bool
+	startHour, startMinute int
+	endHour, endMinute     int
+}
+
+// captureSchedule is the set of windows of -schedule, in a time zone,
+// during which captures run.
+type captureSchedule struct {
+	windows  []scheduleWindow
+	location *time.Location
+}
+
+// parseSchedule parses comma-separated windows in the [days ]HH:MM-HH:MM
+// form, days being a day (mon) or a range of days (mon-fri), every day
+// when omitted.
+func parseSchedule(s string, location *time.Location) (*captureSchedule, error) {
+	sched := &captureSchedule{location: location}
+
+	for _, entry := range strings.Split(s, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("invalid window %q: expected [days ]HH:MM-HH:MM", strings.TrimSpace(entry))
+		}
+
+		var w scheduleWindow
+		if len(fields) == 2 {
+			days, err := parseWeekdays(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			w.days = days
+		} else {
+			for i := range w.days {
+				w.days[i] = true
+			}
+		}
+
+		times := fields[len(fields)-1]
+		start, end, ok := strings.Cut(times, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid window %q: expected [days ]HH:MM-HH:MM", strings.TrimSpace(entry))
+		}
+		var err error
+		w.startHour, w.startMinute, err = parseTimeOfDay(start)
+		if err != nil {
+			return nil, err
+		}
+		w.endHour, w.endMinute, err = parseTimeOfDay(end)
+		if err != nil {
+			return nil, err
+		}
+		if w.startHour == w.endHour && w.startMinute == w.endMinute {
+			return nil, fmt.Errorf("invalid window %q: it starts when it ends", strings.TrimSpace(entry))
+		}
+
+		sched.windows = append(sched.windows, w)
+	}
+	return sched, nil
+}
+
+// parseWeekdays parses a day, or a range of days which may wrap around the
+// end of the week (fri-mon).
+func parseWeekdays(s string) ([7]bool, error) {
+	var days [7]bool
+	index := func(name string) (int, error) {
+		for i, d := range weekdays {
+			if strings.EqualFold(name, d) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("invalid day %q: expected %s", name, strings.Join(weekdays, ", "))
+	}
+
+	first, last, isRange := strings.Cut(s, "-")
+	from, err := index(first)
+	if err != nil {
+		return days, err
+	}
+	to := from
+	if isRange {
+		to, err = index(last)
+		if err != nil {
+			return days, err
+		}
+	}
+	for i := from; ; i = (i + 1) % 7 {
+		days[i] = true
+		if i == to {
+			break
+		}
+	}
+	return days, nil
+}
+
+// parseTimeOfDay parses a HH:MM time of day.
+func parseTimeOfDay(s string) (hour int, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q: expected HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// state returns whether captures must run at now, and when that changes:
+// the close of the current windows, or the opening of the next one.
+// Overlapping and adjacent windows are merged.
+func (s *captureSchedule) state(now time.Time) (open bool, next time.Time) {
+	now = now.In(s.location)
+
+	// Windows starting from the day before, which may not have ended yet,
+	// to a week later :
+	type interval struct{ start, end time.Time }
+	var intervals []interval
+	for day := -1; day <= 7; day++ {
+		date := now.AddDate(0, 0, day)
+		for _, w := range s.windows {
+			if !w.days[date.Weekday()] {
+				continue
+			}
+			start := time.Date(date.Year(), date.Month(), date.Day(), w.startHour, w.startMinute, 0, 0, s.location)
+			end := time.Date(date.Year(), date.Month(), date.Day(), w.endHour, w.endMinute, 0, 0, s.location)
+			if !end.After(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			intervals = append(intervals, interval{start, end})
+		}
+	}
+
+	// Extend the current window with the ones it overlaps, until none does :
+	var end time.Time
+	for extended := true; extended; {
+		extended = false
+		for _, i := range intervals {
+			if end.IsZero() && !i.start.After(now) && i.end.After(now) {
+				end, extended = i.end, true
+			} else if !end.IsZero() && !i.start.After(end) && i.end.After(end) {
+				end, extended = i.end, true
+			}
+		}
+	}
+	if !end.IsZero() {
+		return true, end
+	}
+
+	for _, i := range intervals {
+		if i.start.After(now) && (next.IsZero() || i.start.Before(next)) {
+			next = i.start
+		}
+	}
+	return false, next
+}
+
+// captureScheduled runs captures during the windows of the schedule, until
+// ctx is done. Captures end at the close of their window, tearing the
+// session down, and start again at the opening of the next one. It returns
+// the exit code of the last capture.
+func captureScheduled(ctx context.Context, cfg *config) int {
+	code := 0
+	for {
+		open, next := cfg.schedule.state(time.Now())
+		if next.IsZero() {
+			log.Println("The capture schedule has no window, exiting")
+			return code
+		}
+
+		if !open {
+			log.Printf("Outside the capture schedule, next window opens at %s", next.Format(time.RFC3339))
+			if !sleepUntil(ctx, next) {
+				return code
+			}
+			continue
+		}
+
+		log.Printf("Capture window open until %s", next.Format(time.RFC3339))
+		windowCtx, cancel := context.WithDeadlineCause(ctx, next, errWindowClosed)
+		code = capture(windowCtx, cfg)
+		cancel()
+		if ctx.Err() != nil {
+			return code
+		}
+
+		// The session ended within its window :
+		if time.Now().Before(next) {
+			log.Printf("Capture ended before the close of its window, capturing again in %v", scheduleRetryDelay)
+			if !sleepUntil(ctx, time.Now().Add(scheduleRetryDelay)) {
+				return code
+			}
+		}
+	}
+}
+
+// sleepUntil waits until t, and returns false when ctx is done first.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
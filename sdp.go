@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+)
+
+// Limits on the SDP of the DESCRIBE response, against hostile or broken
+// servers. The RTSP library already refuses bodies bigger than 128 KiB,
+// so the size limit can only be lowered :
+const (
+	sdpMaxSize   = 128 * 1024
+	sdpMaxMedias = 32
+)
+
+// checkSDPLimits returns an error when the SDP exceeds the given limits.
+func checkSDPLimits(raw []byte, desc *description.Session, maxSize int, maxMedias int) error {
+	if len(raw) > maxSize {
+		return fmt.Errorf("SDP is %d bytes long, more than the %d bytes allowed by -max-sdp-size",
+			len(raw), maxSize)
+	}
+	if len(desc.Medias) > maxMedias {
+		return fmt.Errorf("SDP declares %d medias, more than the %d allowed by -max-medias",
+			len(desc.Medias), maxMedias)
+	}
+	return nil
+}
+
+// emptySDPResponse returns whether a DESCRIBE response is successful but
+// carries no media, with an empty body or an SDP without any m= line.
+func emptySDPResponse(res *base.Response) bool {
+	if res == nil || res.StatusCode != base.StatusOK {
+		return false
+	}
+	for _, line := range strings.Split(string(res.Body), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "m=") {
+			return false
+		}
+	}
+	return true
+}
+
+// mappedSDPAttributes are the attributes that gortsplib maps to fields of
+// description.Session and description.Media. Any other attribute is lost
+// during parsing and is reported as unknown.
+var mappedSDPAttributes = map[string]struct{}{
+	"control":  {},
+	"group":    {},
+	"mid":      {},
+	"rtpmap":   {},
+	"fmtp":     {},
+	"sendonly": {},
+}
+
+// sdpAttribute is an "a=" line of the SDP.
+type sdpAttribute struct {
+	// Index of the media the attribute belongs to,
+	// or -1 for session-level attributes :
+	Media int    `json:"media"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// unknownSDPAttributes returns the attributes of the raw SDP which are not
+// mapped to fields of the parsed session description, in order of appearance.
+func unknownSDPAttributes(raw []byte) ([]sdpAttribute, error) {
+	var ssd sdp.SessionDescription
+	err := ssd.Unmarshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var attrs []sdpAttribute
+	collect := func(media int, key string, value string) {
+		if _, ok := mappedSDPAttributes[key]; !ok {
+			attrs = append(attrs, sdpAttribute{Media: media, Key: key, Value: value})
+		}
+	}
+
+	for _, attr := range ssd.Attributes {
+		collect(-1, attr.Key, attr.Value)
+	}
+	for i, md := range ssd.MediaDescriptions {
+		for _, attr := range md.Attributes {
+			collect(i, attr.Key, attr.Value)
+		}
+	}
+
+	return attrs, nil
+}
+
+// sdpDump is the JSON representation of the SDP: the parsed session
+// description, its formats with structured fmtp parameters, plus the raw
+// attributes that the parser did not map.
+type sdpDump struct {
+	*description.Session
+	Formats           []formatDump   `json:"formats"`
+	UnknownAttributes []sdpAttribute `json:"unknown_attributes"`
+	MediaClocks       []*mediaClock  `json:"media_clocks,omitempty"`
+}
+
+// newSDPDump builds the dump of the session description parsed from raw.
+func newSDPDump(desc *description.Session, raw []byte) *sdpDump {
+	d := &sdpDump{
+		Session:           desc,
+		Formats:           newFormatDumps(desc),
+		UnknownAttributes: []sdpAttribute{},
+	}
+
+	attrs, err := unknownSDPAttributes(raw)
+	if err == nil {
+		d.UnknownAttributes = attrs
+	}
+	d.MediaClocks = mediaClocks(raw)
+
+	return d
+}
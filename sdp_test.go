@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sdpLines joins SDP lines with CRLF.
+func sdpLines(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func TestUnknownSDPAttributes(t *testing.T) {
+	for _, ca := range []struct {
+		name  string
+		raw   []byte
+		attrs []sdpAttribute
+	}{
+		{
+			name: "vendor attributes",
+			raw: sdpLines(
+				"v=0",
+				"o=- 0 0 IN IP4 127.0.0.1",
+				"s=Test",
+				"t=0 0",
+				"a=control:*",
+				"a=x-vendor:acme-camera",
+				"a=x-vendor-firmware:1.2.3",
+				"m=video 0 RTP/AVP 96",
+				"a=rtpmap:96 H264/90000",
+				"a=fmtp:96 packetization-mode=1",
+				"a=control:trackID=0",
+				"a=x-vendor:lens=wide",
+				"a=framerate:25",
+				"m=audio 0 RTP/AVP 0",
+				"a=control:trackID=1",
+				"a=x-vendor-flag",
+			),
+			attrs: []sdpAttribute{
+				{Media: -1, Key: "x-vendor", Value: "acme-camera"},
+				{Media: -1, Key: "x-vendor-firmware", Value: "1.2.3"},
+				{Media: 0, Key: "x-vendor", Value: "lens=wide"},
+				{Media: 0, Key: "framerate", Value: "25"},
+				{Media: 1, Key: "x-vendor-flag"},
+			},
+		},
+		{
+			name: "known attributes only",
+			raw: sdpLines(
+				"v=0",
+				"o=- 0 0 IN IP4 127.0.0.1",
+				"s=Test",
+				"t=0 0",
+				"a=group:BUNDLE 0",
+				"m=video 0 RTP/AVP 96",
+				"a=mid:0",
+				"a=rtpmap:96 H264/90000",
+				"a=fmtp:96 packetization-mode=1",
+				"a=control:trackID=0",
+				"a=sendonly",
+			),
+			attrs: nil,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			attrs, err := unknownSDPAttributes(ca.raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(attrs, ca.attrs) {
+				t.Errorf("got %+v, want %+v", attrs, ca.attrs)
+			}
+
+			// Attributes mapped by the parser are never reported, and
+			// the others once :
+			seen := make(map[sdpAttribute]int)
+			for _, attr := range attrs {
+				if _, ok := mappedSDPAttributes[attr.Key]; ok {
+					t.Errorf("known attribute %q reported as unknown", attr.Key)
+				}
+				seen[attr]++
+				if seen[attr] > 1 {
+					t.Errorf("attribute %+v reported twice", attr)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownSDPAttributesInvalid(t *testing.T) {
+	_, err := unknownSDPAttributes([]byte("not an SDP"))
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+}
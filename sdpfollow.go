@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/liberrors"
+)
+
+// minimum time between two DESCRIBEs of -follow-sdp-update, so that stray
+// packets don't make it hammer the server.
+const sdpRecheckInterval = 10 * time.Second
+
+// sdpFollower recognizes, among the decode errors of the client, the packets
+// whose payload type the SDP doesn't declare, which usually means that the
+// server reconfigured the stream. With -follow-sdp-update, the stream is
+// DESCRIBEd again on another connection, and changed is closed when the
+// medias differ from the ones of the session, so that a new session picks up
+// the new SDP. When the SDP didn't change, the session goes on: sessions are
+// never restarted for an SDP which stays the same. With -fail-fast, the
+// first of these packets trips strict instead. Other errors are passed to
+// next.
+type sdpFollower struct {
+	cfg     *config
+	strict  *failFast
+	desc    *description.Session
+	next    func(err error)
+	changed chan struct{}
+
+	mutex     sync.Mutex
+	reported  map[uint8]bool
+	checking  bool
+	lastCheck time.Time
+	restart   bool
+}
+
+// newSDPFollower creates the follower of a session, passing the other
+// decode errors to next. desc must be set before playing.
+func newSDPFollower(cfg *config, strict *failFast, next func(err error)) *sdpFollower {
+	return &sdpFollower{
+		cfg:      cfg,
+		strict:   strict,
+		next:     next,
+		changed:  make(chan struct{}),
+		reported: make(map[uint8]bool),
+	}
+}
+
+// onDecodeError implements the OnDecodeError function of gortsplib.Client.
+// Payload types of RTCP packets multiplexed with RTP are left to next.
+func (f *sdpFollower) onDecodeError(err error) {
+	var unknownPT liberrors.ErrClientRTPPacketUnknownPayloadType
+	if !errors.As(err, &unknownPT) ||
+		(unknownPT.PayloadType >= rtcpMuxMinPayloadType && unknownPT.PayloadType <= rtcpMuxMaxPayloadType) {
+		f.next(err)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.reported[unknownPT.PayloadType] {
+		f.reported[unknownPT.PayloadType] = true
+		if f.cfg.followSDPUpdate {
+			log.Printf("WARNING: received packets of payload type %d, which the SDP doesn't declare: "+
+				"the server may have reconfigured the stream, DESCRIBEing it again", unknownPT.PayloadType)
+		} else {
+			log.Printf("WARNING: received packets of payload type %d, which the SDP doesn't declare: "+
+				"the server may have reconfigured the stream, see -follow-sdp-update", unknownPT.PayloadType)
+			f.strict.trip("packets of a payload type which the SDP doesn't declare")
+		}
+	}
+
+	if !f.cfg.followSDPUpdate || f.checking || f.restart || time.Since(f.lastCheck) < sdpRecheckInterval {
+		return
+	}
+	f.checking = true
+	f.lastCheck = time.Now()
+	go f.check()
+}
+
+// check DESCRIBEs the stream again, and closes changed when its medias
+// changed.
+func (f *sdpFollower) check() {
+	defer func() {
+		f.mutex.Lock()
+		f.checking = false
+		f.mutex.Unlock()
+	}()
+
+	// The payload types of the session were mapped by -payload-map :
+	desc, res, err := describeOnly(f.cfg, f.cfg.url)
+	if err == nil && len(f.cfg.payloadMap) != 0 {
+		var u *base.URL
+		u, err = base.ParseURL(f.cfg.url)
+		if err == nil {
+			desc, _, err = applyPayloadMap(res, u, f.cfg.payloadMap)
+		}
+	}
+	if err != nil {
+		log.Printf("Error DESCRIBEing the stream again: %v", err)
+		return
+	}
+
+	diffs := diffSessions(f.desc, desc)
+	if len(diffs) == 0 {
+		log.Println("SDP unchanged, going on with the session")
+		return
+	}
+
+	logJSON("SDP changes", diffs, f.cfg.reportJSONPretty)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.restart = true
+	close(f.changed)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+)
+
+// seiNames names the payload types of the SEI messages (H.264 Annex D,
+// H.265 Annex D) that cameras commonly send. Both codecs share these.
+var seiNames = map[int]string{
+	0:   "buffering_period",
+	1:   "pic_timing",
+	4:   "user_data_registered_itu_t_t35",
+	5:   "user_data_unregistered",
+	6:   "recovery_point",
+	129: "active_parameter_sets",
+	132: "decoded_picture_hash",
+	136: "time_code",
+	137: "mastering_display_colour_volume",
+	144: "content_light_level_info",
+	147: "alternative_transfer_characteristics",
+}
+
+// seiMessage is an SEI message, with the contents of the user data types
+// decoded: the UUID of unregistered user data, which identifies the vendor
+// format, followed by its data as text when printable, and the country code
+// of registered user data. The payload is always given in hex.
+type seiMessage struct {
+	PayloadType int    `json:"payload_type"`
+	Name        string `json:"name,omitempty"`
+	Size        int    `json:"size"`
+	UUID        string `json:"uuid,omitempty"`
+	Text        string `json:"text,omitempty"`
+	CountryCode *int   `json:"country_code,omitempty"`
+	Hex         string `json:"hex"`
+}
+
+// seiSupported returns whether the SEI messages of a format can be
+// extracted.
+func seiSupported(forma format.Format) bool {
+	switch forma.(type) {
+	case *format.H264, *format.H265:
+		return true
+	}
+	return false
+}
+
+// seiNALU returns the payload of a NAL unit following its header when it
+// carries SEI messages, or nil.
+func seiNALU(forma format.Format, nalu []byte) []byte {
+	switch forma.(type) {
+	case *format.H264:
+		if len(nalu) > 1 && h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeSEI {
+			return nalu[1:]
+		}
+	case *format.H265:
+		if len(nalu) > 2 {
+			typ := h265.NALUType((nalu[0] >> 1) & 0x3F)
+			if typ == h265.NALUType_PREFIX_SEI_NUT || typ == h265.NALUType_SUFFIX_SEI_NUT {
+				return nalu[2:]
+			}
+		}
+	}
+	return nil
+}
+
+// parseSEI parses the SEI messages of the payload of an SEI NAL unit,
+// emulation prevention bytes included.
+func parseSEI(payload []byte) ([]seiMessage, error) {
+	rbsp := h264.EmulationPreventionRemove(payload)
+
+	// Type and size are coded as runs of 0xFF followed by a last byte :
+	readValue := func() (int, error) {
+		v := 0
+		for {
+			if len(rbsp) == 0 {
+				return 0, errors.New("truncated SEI message")
+			}
+			b := rbsp[0]
+			rbsp = rbsp[1:]
+			v += int(b)
+			if b != 0xFF {
+				return v, nil
+			}
+		}
+	}
+
+	var messages []seiMessage
+	// The messages end with the RBSP trailing bits :
+	for len(rbsp) != 0 && !(len(rbsp) == 1 && rbsp[0] == 0x80) {
+		typ, err := readValue()
+		if err != nil {
+			return messages, err
+		}
+		size, err := readValue()
+		if err != nil {
+			return messages, err
+		}
+		if size > len(rbsp) {
+			return messages, fmt.Errorf("SEI message of type %d is %d bytes, beyond the NAL unit", typ, size)
+		}
+		data := rbsp[:size]
+		rbsp = rbsp[size:]
+
+		msg := seiMessage{
+			PayloadType: typ,
+			Name:        seiNames[typ],
+			Size:        size,
+			Hex:         hex.EncodeToString(data),
+		}
+		switch {
+		case typ == 5 && size >= 16:
+			u := data[:16]
+			msg.UUID = fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+			msg.Text = printableText(data[16:])
+		case typ == 4 && size >= 1:
+			code := int(data[0])
+			msg.CountryCode = &code
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// printableText returns data as text when it is only printable ASCII,
+// ignoring trailing NUL bytes, or an empty string.
+func printableText(data []byte) string {
+	for len(data) != 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	for _, b := range data {
+		if b < 0x20 || b > 0x7E {
+			return ""
+		}
+	}
+	return string(data)
+}
+
+// seiRecord is a line of the SEI file: the SEI messages of an access unit.
+type seiRecord struct {
+	Track     int          `json:"track"`
+	TrackName string       `json:"track_name,omitempty"`
+	PTS       int64        `json:"pts"`
+	NTP       *time.Time   `json:"ntp,omitempty"`
+	Received  time.Time    `json:"received"`
+	Keyframe  bool         `json:"keyframe"`
+	Messages  []seiMessage `json:"messages"`
+}
+
+// seiRecordOf returns the record of the SEI messages of an access unit, or
+// nil when it carries none. Messages which can't be parsed are logged.
+func seiRecordOf(au *accessUnit) *seiRecord {
+	var rec *seiRecord
+	for _, nalu := range au.units {
+		payload := seiNALU(au.track.media.Formats[0], nalu)
+		if payload == nil {
+			continue
+		}
+
+		messages, err := parseSEI(payload)
+		if err != nil {
+			log.Printf("WARNING: track %s: %v", au.track, err)
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		if rec == nil {
+			rec = &seiRecord{
+				Track:     au.track.index,
+				TrackName: au.track.name,
+				PTS:       au.pts,
+				Received:  au.received,
+				Keyframe:  au.keyframe,
+			}
+			if !au.ntp.IsZero() {
+				ntp := au.ntp
+				rec.NTP = &ntp
+			}
+		}
+		rec.Messages = append(rec.Messages, messages...)
+	}
+	return rec
+}
+
+// seiWriter writes the SEI messages of the video tracks into a file, one
+// JSON object per access unit carrying some.
+type seiWriter struct {
+	mutex sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+	count int
+}
+
+// newSEIWriter creates the SEI file at path.
+func newSEIWriter(path string) (*seiWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &seiWriter{file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// write appends a record to the file.
+func (w *seiWriter) write(rec *seiRecord) error {
+	buf, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.count += len(rec.Messages)
+	_, err = w.w.Write(append(buf, '\n'))
+	return err
+}
+
+// close closes the file, and logs how many messages were written.
+func (w *seiWriter) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	log.Printf("%d SEI messages written into %s", w.count, w.file.Name())
+
+	err := w.w.Flush()
+	cerr := w.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openSEI writes the SEI messages of the video tracks into the -sei-out
+// file.
+func (o *outputs) openSEI(tracks []*track) error {
+	setupDepacketizers(tracks, func(t *track) bool {
+		return seiSupported(t.media.Formats[0])
+	})
+	seiOut, err := newSEIWriter(o.cfg.outputPath(o.cfg.seiOut))
+	if err != nil {
+		return fmt.Errorf("creating SEI file: %w", err)
+	}
+	o.onClose(func() {
+		err := seiOut.close()
+		if err != nil {
+			log.Printf("Error closing SEI file: %v", err)
+		}
+	})
+	queue := o.newQueue("SEI")
+
+	o.onAccessUnit(func(au *accessUnit) {
+		if !seiSupported(au.track.media.Formats[0]) {
+			return
+		}
+		if rec := seiRecordOf(au); rec != nil {
+			queue.push(func() error {
+				return seiOut.write(rec)
+			})
+		}
+	})
+	return nil
+}
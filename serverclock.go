@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// offset between the server and the local clocks beyond which it is
+// reported as a warning. The Date header has a resolution of one second.
+const serverClockTolerance = 2 * time.Second
+
+// serverClock compares the clock of the server, from the Date header of its
+// OPTIONS and DESCRIBE responses, with the local clock. A server whose
+// clock is off maps RTP timestamps to wrong absolute times in its RTCP
+// sender reports, which explains discrepancies in the NTP mapping.
+type serverClock struct {
+	mutex  sync.Mutex
+	method base.Method
+	sentAt time.Time
+	last   *serverClockReport
+}
+
+// serverClockReport is the offset of the server clock, measured on the last
+// response carrying a Date header.
+type serverClockReport struct {
+	Method string    `json:"method"`
+	Date   time.Time `json:"date"`
+	// positive when the server clock is ahead of the local clock.
+	OffsetMS float64 `json:"offset_ms"`
+}
+
+// watchServerClock chains the request and response callbacks of the client
+// with the measure of the server clock.
+func watchServerClock(client *gortsplib.Client) *serverClock {
+	c := &serverClock{}
+
+	onRequest := client.OnRequest
+	client.OnRequest = func(req *base.Request) {
+		if onRequest != nil {
+			onRequest(req)
+		}
+		c.mutex.Lock()
+		c.method = req.Method
+		c.sentAt = time.Now()
+		c.mutex.Unlock()
+	}
+
+	onResponse := client.OnResponse
+	client.OnResponse = func(res *base.Response) {
+		if onResponse != nil {
+			onResponse(res)
+		}
+		receivedAt := time.Now()
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		if c.method != base.Options && c.method != base.Describe {
+			return
+		}
+		date, ok := res.Header["Date"]
+		if !ok || len(date) == 0 {
+			return
+		}
+		t, err := http.ParseTime(date[0])
+		if err != nil {
+			log.Printf("WARNING: invalid Date header in the %s response: %v", c.method, err)
+			return
+		}
+		c.measure(t, receivedAt)
+	}
+
+	return c
+}
+
+// measure compares the date of a response with the local time at which it
+// was sent, midway between the request and the response. The date is
+// truncated to the second, so the server sent it half a second later on
+// average.
+func (c *serverClock) measure(date time.Time, receivedAt time.Time) {
+	local := c.sentAt.Add(receivedAt.Sub(c.sentAt) / 2)
+	offset := date.Add(500 * time.Millisecond).Sub(local)
+
+	first := c.last == nil
+	c.last = &serverClockReport{
+		Method:   string(c.method),
+		Date:     date,
+		OffsetMS: durationMS(offset),
+	}
+	if !first {
+		return
+	}
+	if offset > serverClockTolerance || offset < -serverClockTolerance {
+		direction := "ahead of"
+		if offset < 0 {
+			direction, offset = "behind", -offset
+		}
+		log.Printf("WARNING: the server clock is %v %s the local clock (Date header of the %s response): "+
+			"the absolute times it maps RTP timestamps to will be off as well",
+			offset.Round(time.Millisecond), direction, c.method)
+		return
+	}
+	log.Printf("Server clock is within %v of the local clock (Date header of the %s response)",
+		serverClockTolerance, c.method)
+}
+
+// report returns the last measure, or nil when the server sent no Date.
+func (c *serverClock) report() *serverClockReport {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.last
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// PacketRecord is the representation of an RTP packet in the output.
+type PacketRecord struct {
+	Version          uint8           `json:"version"`
+	Seq              uint16          `json:"sequence_number"`
+	Timestamp        uint32          `json:"timestamp"`
+	Extension        bool            `json:"extension"`
+	Padding          bool            `json:"padding"`
+	Marker           bool            `json:"marker"`
+	PayloadType      uint8           `json:"payload_type"`
+	SSRC             uint32          `json:"ssrc"`
+	CSRC             []uint32        `json:"csrc"`
+	Extensions       []rtp.Extension `json:"extensions"`
+	ExtensionProfile uint16          `json:"extension_profile"`
+
+	// Normal play time of the packet, when the server sent RTP-Info :
+	NPTSeconds *float64 `json:"npt_seconds,omitempty"`
+
+	// Absolute time of the packet, when the SDP declares a direct media
+	// clock locked to a PTP or NTP reference clock (RFC 7273) :
+	MediaClockTime *time.Time `json:"media_clock_time,omitempty"`
+
+	// Absolute time of the packet, from the epoch given with -rtp-epoch :
+	EpochTime *time.Time `json:"epoch_time,omitempty"`
+
+	// With -normalize-timestamps, timestamp is the index of the frame in
+	// the track, and the index of the packet in the track is given here.
+	// The original values are kept with -keep-original-timestamps :
+	PacketIndex            *uint64    `json:"packet_index,omitempty"`
+	OriginalTimestamp      *uint32    `json:"original_timestamp,omitempty"`
+	OriginalMediaClockTime *time.Time `json:"original_media_clock_time,omitempty"`
+	OriginalEpochTime      *time.Time `json:"original_epoch_time,omitempty"`
+
+	// Interleaved channel of the packet, when streaming over TCP :
+	Channel *int `json:"channel,omitempty"`
+
+	// Whether the packet was restored from an RTX retransmission :
+	Retransmitted bool `json:"retransmitted,omitempty"`
+
+	// Metadata document completed by the packet, on ONVIF metadata tracks
+	// when -metadata-out is not given :
+	Metadata string `json:"metadata,omitempty"`
+
+	// Size of the packet, header included, for -output-template :
+	Bytes int `json:"-"`
+}
+
+// newPacketRecord fills a record with the header fields of the packet.
+func newPacketRecord(pkt *rtp.Packet) *PacketRecord {
+	return &PacketRecord{
+		Version:          pkt.Version,
+		Seq:              pkt.SequenceNumber,
+		Timestamp:        pkt.Timestamp,
+		Extension:        pkt.Extension,
+		Padding:          pkt.Padding,
+		Marker:           pkt.Marker,
+		PayloadType:      pkt.PayloadType,
+		SSRC:             pkt.SSRC,
+		CSRC:             pkt.CSRC,
+		Extensions:       pkt.Extensions,
+		ExtensionProfile: pkt.ExtensionProfile,
+		Bytes:            pkt.MarshalSize(),
+	}
+}
+
+// packetSink receives the records of the RTP packets that must be output.
+type packetSink interface {
+	writePacket(t *track, rec *PacketRecord) error
+	close() error
+}
+
+// logSink prints every packet in JSON through the standard logger.
+type logSink struct {
+	pretty  bool
+	flatten bool
+	// fields of the packets to print, all of them when nil.
+	projection *fieldProjection
+	// template of the line of every packet, instead of JSON, when not nil.
+	template *template.Template
+	// packets are skipped while muted is set.
+	muted *atomic.Bool
+}
+
+// writePacket implements packetSink.
+func (s logSink) writePacket(t *track, rec *PacketRecord) error {
+	if s.muted.Load() {
+		return nil
+	}
+
+	if s.template != nil {
+		line, err := renderPacket(s.template, t, rec)
+		if err != nil {
+			return err
+		}
+		log.Println(string(line))
+		return nil
+	}
+
+	var v any = rec
+	if s.projection != nil {
+		projected, err := s.projection.marshal(nil, rec)
+		if err != nil {
+			return err
+		}
+		v = json.RawMessage(projected)
+	}
+
+	var packetJSON []byte
+	var err error
+	if s.flatten {
+		packetJSON, err = marshalFlatJSON(v, s.pretty)
+	} else {
+		packetJSON, err = marshalJSON(v, s.pretty)
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("Received RTP packet:")
+	log.Println(string(packetJSON))
+	return nil
+}
+
+// close implements packetSink.
+func (logSink) close() error {
+	return nil
+}
+
+// ndjsonRecord is a line of an NDJSON file: the record of a packet,
+// along with the index and the name of its track, and the rtpmap of its
+// codec when gortsplib doesn't know it.
+type ndjsonRecord struct {
+	Track     int    `json:"track"`
+	TrackName string `json:"track_name,omitempty"`
+	RTPMap    string `json:"rtpmap,omitempty"`
+	*PacketRecord
+}
+
+// ndjsonSink writes every packet into a file, one JSON object per line, or
+// one line rendered by -output-template.
+type ndjsonSink struct {
+	file       *os.File
+	w          *bufio.Writer
+	flatten    bool
+	projection *fieldProjection
+	template   *template.Template
+}
+
+// newNDJSONSink creates the NDJSON file at path, with a write buffer of
+// bufferSize bytes, flattening the records into single-level objects when
+// flatten is set, and restricting them to the fields of projection when it
+// is not nil. Packets are rendered by tmpl instead when it is not nil.
+func newNDJSONSink(path string, bufferSize int, flatten bool, projection *fieldProjection,
+	tmpl *template.Template,
+) (*ndjsonSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ndjsonSink{
+		file:       f,
+		w:          bufio.NewWriterSize(f, bufferSize),
+		flatten:    flatten,
+		projection: projection,
+		template:   tmpl,
+	}, nil
+}
+
+// writePacket implements packetSink.
+func (s *ndjsonSink) writePacket(t *track, rec *PacketRecord) error {
+	if s.template != nil {
+		line, err := renderPacket(s.template, t, rec)
+		if err != nil {
+			return err
+		}
+		_, err = s.w.Write(append(line, '\n'))
+		return err
+	}
+
+	var v any = ndjsonRecord{Track: t.index, TrackName: t.name, RTPMap: t.genericRTPMap(), PacketRecord: rec}
+	if s.projection != nil {
+		projected, err := s.projection.marshal(t, rec)
+		if err != nil {
+			return err
+		}
+		if !s.flatten {
+			_, err = s.w.Write(append(projected, '\n'))
+			return err
+		}
+		v = json.RawMessage(projected)
+	}
+
+	var buf []byte
+	var err error
+	if s.flatten {
+		buf, err = marshalFlatJSON(v, false)
+	} else {
+		buf, err = json.Marshal(v)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(buf, '\n'))
+	return err
+}
+
+// flushEvery flushes the write buffer w of a sink at the given interval,
+// from the queue of the sink, until the returned function is called.
+func flushEvery(q *writeQueue, w *bufio.Writer, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				q.push(w.Flush)
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// close implements packetSink.
+func (s *ndjsonSink) close() error {
+	err := s.w.Flush()
+	cerr := s.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// sinkEntry is a packet waiting to be written by a sink.
+type sinkEntry struct {
+	track *track
+	rec   *PacketRecord
+}
+
+// bufferedSink feeds a sink from its own write queue, so that a slow sink
+// doesn't stall the reception of packets nor the other sinks.
+type bufferedSink struct {
+	*writeQueue
+	sink packetSink
+}
+
+// push queues a packet.
+func (b *bufferedSink) push(e sinkEntry) {
+	b.writeQueue.push(func() error {
+		return b.sink.writePacket(e.track, e.rec)
+	})
+}
+
+// close writes the queued packets and closes the sink.
+func (b *bufferedSink) close() error {
+	b.writeQueue.close()
+	return b.sink.close()
+}
+
+// fanoutSink forwards every packet to several sinks, each one buffered
+// on its own. Packets written after close are discarded.
+type fanoutSink struct {
+	mutex  sync.RWMutex
+	sinks  []*bufferedSink
+	closed bool
+}
+
+// add starts forwarding packets to a sink, queuing up to size packets with
+// the given overflow policy. It returns the queue of the sink.
+func (f *fanoutSink) add(name string, sink packetSink, size int, overflow string) *writeQueue {
+	b := &bufferedSink{
+		writeQueue: newWriteQueue(name, size, overflow),
+		sink:       sink,
+	}
+	f.sinks = append(f.sinks, b)
+	return b.writeQueue
+}
+
+// writePacket implements packetSink.
+func (f *fanoutSink) writePacket(t *track, rec *PacketRecord) error {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	if f.closed {
+		return nil
+	}
+	for _, s := range f.sinks {
+		s.push(sinkEntry{track: t, rec: rec})
+	}
+	return nil
+}
+
+// close implements packetSink. It waits for every sink to write its
+// buffered packets.
+func (f *fanoutSink) close() error {
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mutex.Unlock()
+
+	var firstErr error
+	for _, s := range f.sinks {
+		err := s.close()
+		if err != nil {
+			log.Printf("Error closing %s output: %v", s.name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// openPacketFile writes the packet records into the -ndjson-out file, in
+// NDJSON or protobuf depending on -out-format, flushed every -flush-interval.
+func (o *outputs) openPacketFile([]*track) error {
+	var fileSink packetSink
+	var fileBuffer *bufio.Writer
+	name := "NDJSON"
+	if o.cfg.outFormat == outFormatProtobuf {
+		pb, err := newProtobufSink(o.cfg.outputPath(o.cfg.ndjsonOut), o.cfg.outputBufferSize)
+		if err != nil {
+			return fmt.Errorf("creating protobuf file: %w", err)
+		}
+		fileSink, fileBuffer, name = pb, pb.w, "protobuf"
+	} else {
+		ndjson, err := newNDJSONSink(o.cfg.outputPath(o.cfg.ndjsonOut), o.cfg.outputBufferSize, o.cfg.jsonFlatten,
+			o.cfg.jsonFields, o.cfg.outputTemplate)
+		if err != nil {
+			return fmt.Errorf("creating NDJSON file: %w", err)
+		}
+		fileSink, fileBuffer = ndjson, ndjson.w
+	}
+	fileQueue := o.sink.add(name, fileSink, o.cfg.sinkBuffer, o.cfg.writeOverflow)
+	if o.cfg.flushInterval > 0 {
+		o.onClose(flushEvery(fileQueue, fileBuffer, o.cfg.flushInterval))
+	}
+	return nil
+}
+
+// openPacketLog prints the packet records through the standard logger.
+func (o *outputs) openPacketLog([]*track) error {
+	logs := logSink{
+		pretty:     o.cfg.packetJSONPretty,
+		flatten:    o.cfg.jsonFlatten,
+		projection: o.cfg.jsonFields,
+		template:   o.cfg.outputTemplate,
+		muted:      &o.cfg.muted,
+	}
+	o.sink.add("log", logs, o.cfg.sinkBuffer, o.cfg.writeOverflow)
+	return nil
+}
+
+// packetRecordOf returns the record of a packet of t, with its absolute
+// time ntp, and the metadata document doc it completes, if any.
+func packetRecordOf(t *track, p orderedPacket, ntp time.Time, doc []byte) *PacketRecord {
+	rec := newPacketRecord(p.pkt)
+	rec.Channel = t.rtpChannel()
+	rec.Retransmitted = p.retransmitted
+	if npt := t.npt.Load(); npt != nil {
+		seconds := npt.npt(p.pkt.Timestamp).Seconds()
+		rec.NPTSeconds = &seconds
+	}
+	if t.clock != nil {
+		if at, ok := t.clock.absoluteTime(p.pkt.Timestamp, p.forma.ClockRate(), time.Now()); ok {
+			rec.MediaClockTime = &at
+		}
+	}
+	if t.epoch != nil && !ntp.IsZero() {
+		rec.EpochTime = &ntp
+	}
+	if doc != nil {
+		rec.Metadata = string(doc)
+	}
+	return rec
+}
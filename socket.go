@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// checkLocalAddr returns an error when ip is not assigned to a network
+// interface of the host.
+func checkLocalAddr(ip net.IP) error {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("address %v is not assigned to any interface", ip)
+}
+
+// bindDialContext returns a dial function whose connections originate
+// from the given local address.
+func bindDialContext(ip net.IP) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: ip},
+	}
+	return dialer.DialContext
+}
+
+// pinnedHost is a host name resolved once, whose address is reused by all
+// the connections to that host, so that every reconnection reaches the same
+// server behind a round-robin DNS.
+type pinnedHost struct {
+	name string
+	ip   net.IP
+}
+
+// resolveHost resolves a host name, and pins its first address.
+func resolveHost(ctx context.Context, name string) (*pinnedHost, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for %s", name)
+	}
+
+	ips := make([]string, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP.String()
+	}
+	log.Printf("Resolved %s to %s, pinning %s for the whole session",
+		name, strings.Join(ips, ", "), addrs[0].IP)
+
+	return &pinnedHost{name: name, ip: addrs[0].IP}, nil
+}
+
+// wrap returns a dial function which connects to the pinned address instead
+// of resolving the host name again. Other hosts are dialed unchanged.
+func (p *pinnedHost) wrap(
+	dial func(ctx context.Context, network, address string) (net.Conn, error),
+) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err == nil && host == p.name {
+			address = net.JoinHostPort(p.ip.String(), port)
+		}
+		return dial(ctx, network, address)
+	}
+}
+
+// connAddrs are the addresses of the RTSP connection.
+type connAddrs struct {
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+// connRecorder wraps a dial function, to log and remember the addresses
+// of the RTSP connection, including the ephemeral port chosen by the OS.
+type connRecorder struct {
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	mutex sync.Mutex
+	last  *connAddrs
+}
+
+// dialContext implements the DialContext function of gortsplib.Client.
+func (r *connRecorder) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := r.dial(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := &connAddrs{
+		Local:  conn.LocalAddr().String(),
+		Remote: conn.RemoteAddr().String(),
+	}
+	log.Printf("Connected to %s from %s", addrs.Remote, addrs.Local)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.last = addrs
+	return conn, nil
+}
+
+// addrs returns the addresses of the last connection, or nil when no
+// connection was established.
+func (r *connRecorder) addrs() *connAddrs {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.last
+}
+
+// newListenPacket returns a listen function creating the UDP sockets of the
+// client according to the options: unicast sockets are bound to -bind-addr
+// (multicast sockets are bound to their group address and left untouched),
+// the RTCP sockets of the tracks with rtcp-mux share the port of RTP (see
+// rtcpDemux), and every socket gets the -dscp option. The multicast sockets,
+// the only ones sending to a group (the RTCP receiver reports), also get
+// -multicast-ttl; on Linux, gortsplib creates them itself, with a TTL of 16,
+// so that the option has no effect there.
+func newListenPacket(cfg *config, demux *rtcpDemux) func(network, address string) (net.PacketConn, error) {
+	return func(network, address string) (net.PacketConn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if cfg.bindAddr != nil && err == nil && host == "" {
+			address = net.JoinHostPort(cfg.bindAddr.String(), port)
+		}
+		ttl := 0
+		if ip := net.ParseIP(host); ip != nil && ip.IsMulticast() {
+			ttl = cfg.multicastTTL
+		}
+
+		pc, err := demux.listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		err = setSocketOptions(pc, ttl, cfg.dscp)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+		return pc, nil
+	}
+}
+
+// setSocketOptions sets the multicast TTL (hop limit) and the DSCP bits
+// of a UDP socket. Zero values leave the system defaults.
+func setSocketOptions(pc net.PacketConn, ttl int, dscp int) error {
+	if ttl == 0 && dscp == 0 {
+		return nil
+	}
+
+	// The traffic class holds DSCP in its 6 upper bits, ECN in the lower 2 :
+	tos := dscp << 2
+
+	addr, ok := pc.LocalAddr().(*net.UDPAddr)
+	if ok && addr.IP.To4() == nil && !addr.IP.IsUnspecified() {
+		p := ipv6.NewPacketConn(pc)
+		if ttl != 0 {
+			if err := p.SetMulticastHopLimit(ttl); err != nil {
+				return fmt.Errorf("setting multicast hop limit: %w", err)
+			}
+		}
+		if dscp != 0 {
+			if err := p.SetTrafficClass(tos); err != nil {
+				return fmt.Errorf("setting traffic class: %w", err)
+			}
+		}
+		return nil
+	}
+
+	p := ipv4.NewPacketConn(pc)
+	if ttl != 0 {
+		if err := p.SetMulticastTTL(ttl); err != nil {
+			return fmt.Errorf("setting multicast TTL: %w", err)
+		}
+	}
+	if dscp != 0 {
+		if err := p.SetTOS(tos); err != nil {
+			return fmt.Errorf("setting TOS: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// rtcpSteering is the classic BPF program which picks, among the sockets
+// sharing a port with SO_REUSEPORT, the one receiving a packet: the second
+// one (the RTCP socket) for the RTCP packet types 200 to 207 (RFC 5761),
+// whose byte is the marker bit and payload type of RTP, and the first one
+// (the RTP socket) for everything else. The program sees the UDP payload.
+var rtcpSteering = []bpf.Instruction{
+	bpf.LoadAbsolute{Off: 1, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpLessThan, Val: 200, SkipTrue: 2},
+	bpf.JumpIf{Cond: bpf.JumpGreaterThan, Val: 207, SkipTrue: 1},
+	bpf.RetConstant{Val: 1},
+	bpf.RetConstant{Val: 0},
+}
+
+// listenReusePort binds a UDP socket with SO_REUSEPORT, so that the RTP and
+// RTCP sockets of a track can share its port. The first socket of the port
+// is created with steer, and attaches rtcpSteering to the group.
+func listenReusePort(network, address string, steer bool) (net.PacketConn, error) {
+	lc := net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var serr error
+			err := c.Control(func(fd uintptr) {
+				serr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+			})
+			if err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+	pc, err := lc.ListenPacket(context.Background(), network, address)
+	if err != nil || !steer {
+		return pc, err
+	}
+
+	err = attachSteering(pc.(*net.UDPConn))
+	if err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("steering RTCP: %w", err)
+	}
+	return pc, nil
+}
+
+// attachSteering attaches rtcpSteering to the SO_REUSEPORT group of pc.
+func attachSteering(pc *net.UDPConn) error {
+	raw, err := bpf.Assemble(rtcpSteering)
+	if err != nil {
+		return err
+	}
+	filter := make([]unix.SockFilter, len(raw))
+	for i, ins := range raw {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	prog := &unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+
+	rc, err := pc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = unix.SetsockoptSockFprog(int(fd), unix.SOL_SOCKET, unix.SO_ATTACH_REUSEPORT_CBPF, prog)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"net"
+)
+
+// listenReusePort is not implemented outside Linux, where RTCP multiplexed
+// with RTP can't be steered to its own socket.
+func listenReusePort(_, _ string, _ bool) (net.PacketConn, error) {
+	return nil, errors.ErrUnsupported
+}
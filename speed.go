@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// requestSpeed adds the Speed header (RFC 2326, 12.35) to the PLAY requests
+// of the client, asking the server to deliver the stream at the given
+// multiple of real time. Unlike Scale, it doesn't change the playback rate:
+// recordings are downloaded faster, with their original timestamps.
+func requestSpeed(client *gortsplib.Client, speed float64) {
+	value := base.HeaderValue{strconv.FormatFloat(speed, 'f', -1, 64)}
+
+	onRequest := client.OnRequest
+	client.OnRequest = func(req *base.Request) {
+		if req.Method == base.Play {
+			req.Header["Speed"] = value
+		}
+		if onRequest != nil {
+			onRequest(req)
+		}
+	}
+}
+
+// checkSpeed logs the speed accepted by the server in its PLAY response,
+// and warns when it differs from the requested one. Servers ignoring Speed
+// don't return the header.
+func checkSpeed(res *base.Response, speed float64) {
+	values := res.Header["Speed"]
+	if len(values) == 0 {
+		log.Printf("WARNING: the server didn't confirm the Speed of %v, it probably delivers in real time", speed)
+		return
+	}
+
+	accepted, err := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+	if err != nil {
+		log.Printf("WARNING: invalid Speed header in the PLAY response: %q", values[0])
+		return
+	}
+	if accepted != speed {
+		log.Printf("WARNING: the server delivers at a Speed of %v instead of %v", accepted, speed)
+		return
+	}
+	log.Printf("Server delivers at a Speed of %v", accepted)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/sdp"
+	"github.com/pion/rtp"
+)
+
+// Length of the master salt and of the session authentication key of
+// the AES counter mode suites (RFC 3711, RFC 6188) :
+const (
+	srtpSaltLen    = 14
+	srtpAuthKeyLen = 20
+)
+
+// Labels of the key derivation function (RFC 3711, 4.3.1) :
+const (
+	srtpLabelEncryption = 0x00
+	srtpLabelAuth       = 0x01
+	srtpLabelSalt       = 0x02
+)
+
+// srtpSuite is an SRTP crypto suite, as named in SDES (RFC 4568).
+type srtpSuite struct {
+	name   string
+	keyLen int
+	tagLen int
+}
+
+// srtpSuites are the supported crypto suites.
+var srtpSuites = map[string]srtpSuite{
+	"AES_CM_128_HMAC_SHA1_80": {name: "AES_CM_128_HMAC_SHA1_80", keyLen: 16, tagLen: 10},
+	"AES_CM_128_HMAC_SHA1_32": {name: "AES_CM_128_HMAC_SHA1_32", keyLen: 16, tagLen: 4},
+	"AES_256_CM_HMAC_SHA1_80": {name: "AES_256_CM_HMAC_SHA1_80", keyLen: 32, tagLen: 10},
+	"AES_256_CM_HMAC_SHA1_32": {name: "AES_256_CM_HMAC_SHA1_32", keyLen: 32, tagLen: 4},
+}
+
+// srtpKey is the master key and salt of a track, with their suite.
+type srtpKey struct {
+	suite srtpSuite
+	// master key followed by the master salt, as in the inline
+	// parameter of SDES.
+	keySalt []byte
+	// where the key comes from, for the logs.
+	source string
+}
+
+// parseSRTPKey decodes a base64 master key and salt, and checks its length
+// against the suite.
+func parseSRTPKey(suiteName string, encoded string) (*srtpKey, error) {
+	suite, ok := srtpSuites[suiteName]
+	if !ok {
+		return nil, fmt.Errorf("unsupported SRTP crypto suite %q", suiteName)
+	}
+
+	keySalt, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SRTP key: %w", err)
+	}
+	if len(keySalt) != suite.keyLen+srtpSaltLen {
+		return nil, fmt.Errorf("invalid SRTP key: %s needs %d bytes of master key and salt, got %d",
+			suite.name, suite.keyLen+srtpSaltLen, len(keySalt))
+	}
+
+	return &srtpKey{suite: suite, keySalt: keySalt}, nil
+}
+
+// sdesKeys returns the keys declared by the crypto attributes of the SDP
+// (SDES, RFC 4568), by media index. The first crypto attribute of a media
+// with a supported suite is used. Keys with an MKI are not supported, since
+// the MKI is not negotiated in the SETUP.
+func sdesKeys(raw []byte) map[int]*srtpKey {
+	var ssd sdp.SessionDescription
+	if ssd.Unmarshal(raw) != nil {
+		return nil
+	}
+
+	keys := make(map[int]*srtpKey)
+	for i, md := range ssd.MediaDescriptions {
+		for _, attr := range md.Attributes {
+			if attr.Key != "crypto" {
+				continue
+			}
+
+			// a=crypto:<tag> <suite> inline:<key||salt>[|<lifetime>][|<MKI>:<length>]
+			fields := strings.Fields(attr.Value)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "inline:") {
+				continue
+			}
+			params := strings.Split(strings.TrimPrefix(fields[2], "inline:"), "|")
+			if len(params) > 1 && strings.Contains(params[len(params)-1], ":") {
+				log.Printf("WARNING: media #%d: SRTP keys with an MKI are not supported", i)
+				continue
+			}
+
+			key, err := parseSRTPKey(fields[1], params[0])
+			if err != nil {
+				log.Printf("WARNING: media #%d: %v", i, err)
+				continue
+			}
+			key.source = "SDP"
+			keys[i] = key
+			break
+		}
+	}
+	return keys
+}
+
+// srtpStream is the rollover counter state of an SSRC (RFC 3711, 3.3.1).
+type srtpStream struct {
+	roc     uint32
+	lastSeq uint16
+}
+
+// srtpContext decrypts and authenticates the SRTP packets of a track,
+// with AES in counter mode and HMAC-SHA1. RTCP is not covered: gortsplib
+// decodes RTCP packets before they can be decrypted. It is not safe for
+// concurrent use: it must only be fed by the packet callback of its track.
+type srtpContext struct {
+	suite   srtpSuite
+	block   cipher.Block
+	salt    []byte
+	authKey []byte
+	streams map[uint32]*srtpStream
+
+	authFailures atomic.Uint64
+}
+
+// newSRTPContext derives the session keys of SRTP from a master key.
+func newSRTPContext(key *srtpKey) (*srtpContext, error) {
+	masterKey := key.keySalt[:key.suite.keyLen]
+	masterSalt := key.keySalt[key.suite.keyLen:]
+
+	prf, err := aes.NewCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	derive := func(label byte, n int) []byte {
+		// x = (label || index DIV kdr) XOR master salt, with kdr = 0 :
+		iv := make([]byte, aes.BlockSize)
+		copy(iv, masterSalt)
+		iv[7] ^= label
+		out := make([]byte, n)
+		cipher.NewCTR(prf, iv).XORKeyStream(out, out)
+		return out
+	}
+
+	block, err := aes.NewCipher(derive(srtpLabelEncryption, key.suite.keyLen))
+	if err != nil {
+		return nil, err
+	}
+
+	return &srtpContext{
+		suite:   key.suite,
+		block:   block,
+		salt:    derive(srtpLabelSalt, srtpSaltLen),
+		authKey: derive(srtpLabelAuth, srtpAuthKeyLen),
+		streams: make(map[uint32]*srtpStream),
+	}, nil
+}
+
+// errSRTPAuth is returned when the authentication tag of a packet doesn't
+// match, because of a wrong key or of a corrupted packet.
+var errSRTPAuth = errors.New("SRTP authentication failed")
+
+// decrypt authenticates a packet and replaces its payload with the
+// decrypted one, without the authentication tag.
+func (c *srtpContext) decrypt(pkt *rtp.Packet) error {
+	// The padding of encrypted packets can't be told apart from the
+	// authentication tag once the packet is parsed :
+	if pkt.Padding {
+		c.authFailures.Add(1)
+		return fmt.Errorf("%w: padded packets are not supported", errSRTPAuth)
+	}
+	if len(pkt.Payload) < c.suite.tagLen {
+		c.authFailures.Add(1)
+		return fmt.Errorf("%w: packet too short", errSRTPAuth)
+	}
+
+	header, err := pkt.Header.Marshal()
+	if err != nil {
+		return err
+	}
+
+	stream, ok := c.streams[pkt.SSRC]
+	if !ok {
+		stream = &srtpStream{lastSeq: pkt.SequenceNumber}
+	}
+	roc := stream.estimateROC(pkt.SequenceNumber)
+
+	// Authenticate the header and the encrypted payload, followed by the
+	// rollover counter :
+	payload := pkt.Payload[:len(pkt.Payload)-c.suite.tagLen]
+	tag := pkt.Payload[len(pkt.Payload)-c.suite.tagLen:]
+
+	mac := hmac.New(sha1.New, c.authKey)
+	mac.Write(header)
+	mac.Write(payload)
+	var rocBytes [4]byte
+	binary.BigEndian.PutUint32(rocBytes[:], roc)
+	mac.Write(rocBytes[:])
+	if !hmac.Equal(mac.Sum(nil)[:c.suite.tagLen], tag) {
+		c.authFailures.Add(1)
+		return errSRTPAuth
+	}
+
+	if !ok {
+		c.streams[pkt.SSRC] = stream
+	}
+	stream.update(roc, pkt.SequenceNumber)
+
+	// IV = (salt * 2^16) XOR (SSRC * 2^64) XOR (index * 2^16) :
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, c.salt)
+	var ssrc [4]byte
+	binary.BigEndian.PutUint32(ssrc[:], pkt.SSRC)
+	for i := range ssrc {
+		iv[4+i] ^= ssrc[i]
+	}
+	index := uint64(roc)<<16 | uint64(pkt.SequenceNumber)
+	for i := 0; i < 6; i++ {
+		iv[13-i] ^= byte(index >> (8 * i))
+	}
+
+	decrypted := make([]byte, len(payload))
+	cipher.NewCTR(c.block, iv).XORKeyStream(decrypted, payload)
+	pkt.Payload = decrypted
+	return nil
+}
+
+// estimateROC returns the rollover counter of a sequence number, guessing
+// whether it belongs to the previous, the current or the next cycle
+// (RFC 3711, appendix A).
+func (s *srtpStream) estimateROC(seq uint16) uint32 {
+	switch {
+	case s.lastSeq < 1<<15:
+		if int(seq)-int(s.lastSeq) > 1<<15 && s.roc > 0 {
+			return s.roc - 1
+		}
+	default:
+		if int(s.lastSeq)-(1<<15) > int(seq) {
+			return s.roc + 1
+		}
+	}
+	return s.roc
+}
+
+// update advances the state after an authenticated packet.
+func (s *srtpStream) update(roc uint32, seq uint16) {
+	if roc > s.roc || (roc == s.roc && seq > s.lastSeq) {
+		s.roc = roc
+		s.lastSeq = seq
+	}
+}
+
+// setupSRTP creates the SRTP context of the SETUP tracks which have a key,
+// from -srtp-key or else from the SDP. It fails when a key can't be used.
+func setupSRTP(tracks []*track, raw []byte, override *srtpKey) error {
+	keys := sdesKeys(raw)
+	for _, t := range tracks {
+		if !t.setup {
+			continue
+		}
+
+		key := keys[t.index]
+		if override != nil {
+			key = override
+		}
+		if key == nil {
+			continue
+		}
+
+		var err error
+		t.srtp, err = newSRTPContext(key)
+		if err != nil {
+			return fmt.Errorf("track %s: %w", t, err)
+		}
+		log.Printf("Track %s is encrypted with SRTP (%s), decrypting it with the key from %s; "+
+			"its RTCP packets are ignored", t, key.suite.name, key.source)
+	}
+	return nil
+}
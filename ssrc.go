@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pion/rtp"
+)
+
+// ssrcFilter recognizes, among the decode errors of the client, the packets
+// of a new source. gortsplib locks every format of a track on the SSRC of its
+// first packet, and rejects the packets of other SSRCs before the packet
+// callback, over UDP and TCP alike: after a source switch or a restart of the
+// encoder, the track receives nothing more until the next session. The change
+// is reported once per new source, on the track locked on the SSRC the error
+// expects, instead of an error per packet. Other errors are passed to next.
+type ssrcFilter struct {
+	tracks []*track
+	next   func(err error)
+}
+
+// onDecodeError implements the OnDecodeError function of gortsplib.Client.
+func (f *ssrcFilter) onDecodeError(err error) {
+	var ssrc, expected uint32
+	_, serr := fmt.Sscanf(err.Error(), "received packet with wrong SSRC %d, expected %d", &ssrc, &expected)
+	if serr != nil {
+		f.next(err)
+		return
+	}
+
+	for _, t := range f.tracks {
+		if t.onRejectedSSRC(ssrc, expected) {
+			return
+		}
+	}
+	f.next(err)
+}
+
+// onRejectedSSRC reports the source of a packet rejected by the RTSP library,
+// when the track is locked on expected. It returns whether it is.
+func (t *track) onRejectedSSRC(ssrc uint32, expected uint32) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.packets == 0 || t.lastSSRC != expected {
+		return false
+	}
+	t.onNewSource(ssrc, "from its packets")
+	return true
+}
+
+// onSSRCChange restarts the loss and jitter measurements of the track on the
+// first packet of another source: sequence numbers and timestamps jumped,
+// and the jump must not be counted as losses. The caller must hold the
+// mutex.
+func (t *track) onSSRCChange(pkt *rtp.Packet) {
+	t.ssrcChanges++
+	log.Printf("WARNING: track %s: SSRC changed from %08X to %08X, restarting loss and jitter measurements",
+		t, t.lastSSRC, pkt.SSRC)
+
+	t.lastSeq = pkt.SequenceNumber
+	t.gaps = newGapSet(gapWindow)
+	t.jitter = 0
+}
+
+// onSenderReportSSRC detects a change of source from the SSRC of the sender
+// reports: once the reports of the current source give way to the ones of
+// another SSRC, the source changed. Tracks with retransmissions are skipped,
+// their reports alternate between two sources.
+func (t *track) onSenderReportSSRC(ssrc uint32) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	previous := t.senderSSRC
+	t.senderSSRC = ssrc
+	if t.rtx != nil || t.packets == 0 || previous != t.lastSSRC || ssrc == t.lastSSRC {
+		return
+	}
+	t.onNewSource(ssrc, "from the sender reports")
+}
+
+// onNewSource counts a new source of the track, whose packets don't reach the
+// packet callback, once whatever the number of its packets and reports. The
+// caller must hold the mutex.
+func (t *track) onNewSource(ssrc uint32, how string) {
+	if t.newSSRC == ssrc {
+		return
+	}
+	t.newSSRC = ssrc
+	t.ssrcChanges++
+	log.Printf("WARNING: track %s: the source changed from SSRC %08X to %08X, detected %s: "+
+		"the RTSP library rejects the packets of the new source until the next session", t, t.lastSSRC, ssrc, how)
+}
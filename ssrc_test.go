@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+func TestSSRCFilter(t *testing.T) {
+	tracks := parseTestSDP(t, muxedSDP)
+	for _, tr := range tracks {
+		tr.bitrate.window = time.Second
+	}
+	tracks[0].onPacket(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1, SSRC: 0xAAAA}})
+	tracks[1].onPacket(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1, SSRC: 0xCCCC}})
+
+	var passed []error
+	f := &ssrcFilter{tracks: tracks, next: func(err error) { passed = append(passed, err) }}
+
+	// The packets of a new source of the video track, as rejected by
+	// gortsplib, and its sender reports :
+	for i := 0; i < 5; i++ {
+		f.onDecodeError(fmt.Errorf("received packet with wrong SSRC %d, expected %d", 0xBBBB, 0xAAAA))
+	}
+	tracks[0].onSenderReportSSRC(0xAAAA)
+	tracks[0].onSenderReportSSRC(0xBBBB)
+	// A packet expected by no track, and another error :
+	f.onDecodeError(fmt.Errorf("received packet with wrong SSRC %d, expected %d", 0xBBBB, 0xDDDD))
+	f.onDecodeError(errors.New("invalid packet"))
+
+	if tracks[0].ssrcChanges != 1 {
+		t.Errorf("video: got %d SSRC changes, want 1", tracks[0].ssrcChanges)
+	}
+	if tracks[1].ssrcChanges != 0 {
+		t.Errorf("audio: got %d SSRC changes, want 0", tracks[1].ssrcChanges)
+	}
+	if len(passed) != 2 {
+		t.Errorf("got %d errors passed on, want 2: %v", len(passed), passed)
+	}
+}
+
+func TestSenderReportSSRC(t *testing.T) {
+	tr := parseTestSDP(t, muxedSDP)[0]
+	tr.bitrate.window = time.Second
+	tr.onPacket(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1, SSRC: 1}})
+
+	tr.onSenderReportSSRC(1)
+	tr.onSenderReportSSRC(1)
+	if tr.ssrcChanges != 0 {
+		t.Fatalf("got %d SSRC changes, want 0", tr.ssrcChanges)
+	}
+	tr.onSenderReportSSRC(2)
+	tr.onSenderReportSSRC(2)
+	if tr.ssrcChanges != 1 {
+		t.Errorf("got %d SSRC changes, want 1", tr.ssrcChanges)
+	}
+}
+
+func TestTrackSSRCChange(t *testing.T) {
+	tr := parseTestSDP(t, muxedSDP)[0]
+	tr.bitrate.window = time.Second
+	for seq := uint16(100); seq < 110; seq++ {
+		tr.onPacket(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq, Timestamp: uint32(seq) * 3000, SSRC: 1}})
+	}
+	for seq := uint16(40000); seq < 40010; seq++ {
+		tr.onPacket(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq, Timestamp: uint32(seq) * 7, SSRC: 2}})
+	}
+
+	if tr.ssrcChanges != 1 {
+		t.Errorf("got %d SSRC changes, want 1", tr.ssrcChanges)
+	}
+	if tr.lost != 0 {
+		t.Errorf("got %d lost packets, want 0", tr.lost)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Track states, as they appear in the stats :
+const (
+	// the track receives packets.
+	trackStateOK = "ok"
+	// the track received no packet for the stall timeout.
+	trackStateStalled = "stalled"
+)
+
+// trackStats is a snapshot of the counters of a track, as logged periodically.
+type trackStats struct {
+	Index      int     `json:"index"`
+	Name       string  `json:"name,omitempty"`
+	State      string  `json:"state"`
+	Packets    uint64  `json:"packets"`
+	Bytes      uint64  `json:"bytes"`
+	Duplicates uint64  `json:"duplicates"`
+	Lost       uint64  `json:"lost"`
+	Recovered  uint64  `json:"recovered"`
+	JitterMS   float64 `json:"jitter_ms"`
+	Markers    uint64  `json:"markers"`
+	FrameRate  float64 `json:"frame_rate,omitempty"`
+	// number of contributing sources, and of changes of the mix.
+	CSRCs      int    `json:"csrcs,omitempty"`
+	MixChanges uint64 `json:"mix_changes,omitempty"`
+	// number of padded packets.
+	PaddedPackets uint64 `json:"padded_packets,omitempty"`
+	// number of times the bitrate exceeded -max-bitrate.
+	BitrateAlerts uint64 `json:"bitrate_alerts,omitempty"`
+	// number of SSRC changes.
+	SSRCChanges uint64 `json:"ssrc_changes,omitempty"`
+	// drift of the RTP clock, in ppm, once measured.
+	ClockDriftPPM *float64 `json:"clock_drift_ppm,omitempty"`
+}
+
+// stats returns a snapshot of the counters of the track. The track is
+// stalled when it has been silent for stallTimeout, or never received
+// a packet since the given start time.
+func (t *track) stats(since time.Time, stallTimeout time.Duration) trackStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s := trackStats{
+		Index:      t.index,
+		Name:       t.name,
+		State:      trackStateOK,
+		Packets:    t.packets,
+		Bytes:      t.bytes,
+		Duplicates: t.duplicates,
+		Lost:       t.lost,
+		Recovered:  t.recovered,
+		JitterMS:   durationMS(t.jitterDuration()),
+		Markers:    t.markers,
+		CSRCs:      len(t.csrcs.packets),
+		MixChanges: t.csrcs.changes,
+
+		PaddedPackets: t.padding.packets + t.padding.zeroCount,
+		BitrateAlerts: t.bitrateAlerts,
+		SSRCChanges:   t.ssrcChanges,
+	}
+	_, s.FrameRate = t.markerStats()
+	if ppm, ok := t.drift.ppm(t.media.Formats[0].ClockRate()); ok {
+		s.ClockDriftPPM = &ppm
+	}
+
+	last := t.lastPacket
+	if t.packets == 0 {
+		last = since
+	}
+	if time.Since(last) >= stallTimeout {
+		s.State = trackStateStalled
+	}
+	return s
+}
+
+// statsLine is a line of the periodic stats.
+type statsLine struct {
+	Uptime     string       `json:"uptime"`
+	Heartbeat  bool         `json:"heartbeat,omitempty"`
+	Connection *connAddrs   `json:"connection,omitempty"`
+	Tracks     []trackStats `json:"tracks"`
+}
+
+// statsLogger periodically logs the stats of the SETUP tracks.
+// In on-change mode, a line is only logged when a counter advanced or a
+// track changed state since the previous line, or when the heartbeat
+// elapsed, so that an idle stream is not mistaken for a crash.
+type statsLogger struct {
+	tracks       []*track
+	conn         *connRecorder
+	interval     time.Duration
+	onChange     bool
+	heartbeat    time.Duration
+	stallTimeout time.Duration
+	pretty       bool
+
+	startedAt time.Time
+	last      []trackStats
+	lastAt    time.Time
+}
+
+// newStatsLogger creates a stats logger for the SETUP tracks.
+func newStatsLogger(cfg *config, tracks []*track, conn *connRecorder) *statsLogger {
+	s := &statsLogger{
+		conn:         conn,
+		interval:     cfg.statsInterval,
+		onChange:     cfg.statsOnChange,
+		heartbeat:    cfg.statsHeartbeat,
+		stallTimeout: cfg.stallTimeout,
+		pretty:       cfg.reportJSONPretty,
+	}
+	for _, t := range tracks {
+		if t.setup {
+			s.tracks = append(s.tracks, t)
+		}
+	}
+	return s
+}
+
+// run logs the stats every interval, until ctx is done.
+func (s *statsLogger) run(ctx context.Context) {
+	s.startedAt = time.Now()
+	s.lastAt = s.startedAt
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.tick(now)
+		}
+	}
+}
+
+// tick takes a snapshot of the tracks and logs it when needed.
+func (s *statsLogger) tick(now time.Time) {
+	snapshot := make([]trackStats, len(s.tracks))
+	for i, t := range s.tracks {
+		snapshot[i] = t.stats(s.startedAt, s.stallTimeout)
+	}
+
+	heartbeat := false
+	if s.onChange && statsEqual(snapshot, s.last) {
+		if now.Sub(s.lastAt) < s.heartbeat {
+			return
+		}
+		heartbeat = true
+	}
+
+	s.last = snapshot
+	s.lastAt = now
+	logJSON("Stats", &statsLine{
+		Uptime:     now.Sub(s.startedAt).Round(time.Second).String(),
+		Heartbeat:  heartbeat,
+		Connection: s.conn.addrs(),
+		Tracks:     snapshot,
+	}, s.pretty)
+}
+
+// statsEqual returns whether two snapshots hold the same counters and states.
+// Clock drifts are measures, which are left out.
+func statsEqual(a, b []trackStats) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.ClockDriftPPM, y.ClockDriftPPM = nil, nil
+		if x != y {
+			return false
+		}
+	}
+	return true
+}
+
+// durationMS converts a duration into milliseconds, rounded to the microsecond.
+func durationMS(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
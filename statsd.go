@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maximum size of a StatsD datagram, fitting in the MTU of most networks.
+const statsdMaxDatagram = 1432
+
+// statsdPusher periodically pushes the counters and gauges of the SETUP
+// tracks to a StatsD server, tagged in the DogStatsD format with the URL
+// and the index of the track, and its name when given. Counters are sent
+// as their increase over the highest value already sent: the lost packets
+// decrease when late packets arrive, and StatsD counters can only grow.
+// Failed sends are logged and otherwise ignored, so that the capture goes on
+// when the server is unreachable.
+type statsdPusher struct {
+	tracks       []*track
+	conn         net.Conn
+	prefix       string
+	interval     time.Duration
+	stallTimeout time.Duration
+	urlTag       string
+
+	startedAt time.Time
+	sent      map[int]trackStats
+	failing   bool
+}
+
+// newStatsdPusher creates a pusher for the SETUP tracks. StatsD runs over
+// UDP, so that no connection is actually established.
+func newStatsdPusher(cfg *config, tracks []*track) (*statsdPusher, error) {
+	conn, err := net.Dial("udp", cfg.statsdAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &statsdPusher{
+		conn:         conn,
+		prefix:       cfg.statsdPrefix,
+		interval:     cfg.statsdInterval,
+		stallTimeout: cfg.stallTimeout,
+		urlTag:       statsdTagValue(streamName(cfg.url)),
+		sent:         make(map[int]trackStats),
+	}
+	for _, t := range tracks {
+		if t.setup {
+			p.tracks = append(p.tracks, t)
+		}
+	}
+	return p, nil
+}
+
+// run pushes the metrics every interval, until ctx is done, and closes
+// the socket.
+func (p *statsdPusher) run(ctx context.Context) {
+	defer p.conn.Close()
+
+	p.startedAt = time.Now()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.push()
+		}
+	}
+}
+
+// push sends the metrics of every track, in as few datagrams as possible.
+func (p *statsdPusher) push() {
+	var lines []string
+	for _, t := range p.tracks {
+		s := t.stats(p.startedAt, p.stallTimeout)
+		sent := p.sent[t.index]
+
+		tags := fmt.Sprintf("|#url:%s,track:%d,type:%s,codec:%s",
+			p.urlTag, t.index, t.media.Type, statsdTagValue(t.codec()))
+		if t.name != "" {
+			tags += ",track_name:" + statsdTagValue(t.name)
+		}
+		counter := func(name string, v uint64, sent *uint64) {
+			var delta uint64
+			if v > *sent {
+				delta = v - *sent
+				*sent = v
+			}
+			lines = append(lines, fmt.Sprintf("%s.%s:%d|c%s", p.prefix, name, delta, tags))
+		}
+		gauge := func(name string, v float64) {
+			lines = append(lines, fmt.Sprintf("%s.%s:%s|g%s", p.prefix, name,
+				strconv.FormatFloat(v, 'f', -1, 64), tags))
+		}
+
+		counter("packets", s.Packets, &sent.Packets)
+		counter("bytes", s.Bytes, &sent.Bytes)
+		counter("duplicates", s.Duplicates, &sent.Duplicates)
+		counter("lost", s.Lost, &sent.Lost)
+		counter("recovered", s.Recovered, &sent.Recovered)
+		p.sent[t.index] = sent
+		gauge("jitter_ms", s.JitterMS)
+		stalled := 0.0
+		if s.State == trackStateStalled {
+			stalled = 1
+		}
+		gauge("stalled", stalled)
+	}
+
+	err := p.send(lines)
+	switch {
+	case err != nil && !p.failing:
+		p.failing = true
+		log.Printf("WARNING: can't push metrics to StatsD, will keep trying: %v", err)
+	case err == nil && p.failing:
+		p.failing = false
+		log.Println("Pushing metrics to StatsD again")
+	}
+}
+
+// send writes the lines, packed into datagrams.
+func (p *statsdPusher) send(lines []string) error {
+	var firstErr error
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		_, err := p.conn.Write([]byte(buf.String()))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		buf.Reset()
+	}
+
+	for _, line := range lines {
+		if buf.Len() != 0 && buf.Len()+1+len(line) > statsdMaxDatagram {
+			flush()
+		}
+		if buf.Len() != 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+	flush()
+	return firstErr
+}
+
+// statsdTagValue replaces the characters which have a meaning in the
+// DogStatsD format.
+func statsdTagValue(v string) string {
+	return strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace(v)
+}
+
+// streamName returns the host and path of the URL, which name the stream in
+// the metrics without its credentials.
+func streamName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactURL(raw)
+	}
+	return u.Host + u.Path
+}
+
+// redactURL returns the URL with its password masked, for the outputs
+// which may leave the host.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
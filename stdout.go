@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// stdoutPath is the path with which the media outputs write to the standard
+// output, to be piped into another program such as ffmpeg -i -. Logs go to
+// the standard error, so that the standard output carries the media only.
+const stdoutPath = "-"
+
+// pipeClosed is closed once an output writing into a pipe, such as the
+// standard output, failed because its reader went away, so that the capture
+// ends. SIGPIPE is ignored when an output writes to the standard output, to
+// get the error instead of being killed by the signal.
+var (
+	pipeClosed     = make(chan struct{})
+	pipeClosedOnce sync.Once
+)
+
+// createOutput creates the file of a media output, or returns the standard
+// output for stdoutPath.
+func createOutput(path string) (*os.File, error) {
+	if path == stdoutPath {
+		return os.Stdout, nil
+	}
+	return os.Create(path)
+}
+
+// outputName returns the name of the file of a media output, for the logs.
+func outputName(path string) string {
+	if path == stdoutPath {
+		return "the standard output"
+	}
+	return path
+}
+
+// writesStdout returns whether a media output writes to the standard output.
+func (c *config) writesStdout() bool {
+	return c.mp4Out == stdoutPath || c.rawPayloadOut == stdoutPath || c.pcapngOut == stdoutPath
+}
+
+// isBrokenPipe returns whether a write failed because the reader of the pipe
+// went away. Such writes end the output, instead of being logged one by one.
+func isBrokenPipe(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
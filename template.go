@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// packetTemplateData is the value against which -output-template is
+// evaluated for every packet: the fields of PacketRecord, as in
+// {{.Seq}} or {{.Timestamp}}, along with its track and its size.
+type packetTemplateData struct {
+	Track     int
+	TrackName string
+	Codec     string
+	*PacketRecord
+}
+
+// parseOutputTemplate parses the template of -output-template, and
+// evaluates it once against an empty packet, so that references to unknown
+// fields are reported before the session starts rather than on every packet.
+func parseOutputTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output-template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	data := &packetTemplateData{Codec: "H264", PacketRecord: &PacketRecord{}}
+	err = tmpl.Execute(io.Discard, data)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// renderPacket evaluates tmpl against the record of a packet of t, and
+// returns the rendered line, without its trailing newlines, if any.
+func renderPacket(tmpl *template.Template, t *track, rec *PacketRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, &packetTemplateData{
+		Track:        t.index,
+		TrackName:    t.name,
+		Codec:        t.codec(),
+		PacketRecord: rec,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(buf.String(), "\n")), nil
+}
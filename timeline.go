@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// timelineHeader is the header of the frame timeline.
+var timelineHeader = []string{
+	"frame", "track", "keyframe", "rtp_timestamp", "npt_seconds", "ntp", "received", "size",
+}
+
+// frameTimeline writes a line per access unit of the tracks which can be
+// depacketized into a CSV file, for spreadsheets: its number within its
+// track, from 1, its RTP timestamp, its normal play time when the PLAY
+// response anchored it, its absolute time when the server sent RTCP sender
+// reports, its time of reception and its size, NAL units or frames without
+// their RTP headers. Unknown times are left empty. With -verify-checksums,
+// a last column gives the hash of the unit, see frameChecksum.
+type frameTimeline struct {
+	mutex   sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	w       *csv.Writer
+	newHash func() hash.Hash
+	frames  map[int]int
+}
+
+// timelineFrame is a line of the frame timeline, captured in the packet
+// callback, where the NPT clock of the track can be read.
+type timelineFrame struct {
+	au  *accessUnit
+	npt *time.Duration
+}
+
+// newFrameTimeline creates the timeline file at path and writes its header.
+// algorithm names the hash of the units, or is empty.
+func newFrameTimeline(path string, algorithm string) (*frameTimeline, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tl := &frameTimeline{
+		file:   f,
+		buf:    bufio.NewWriter(f),
+		frames: make(map[int]int),
+	}
+	header := timelineHeader
+	if algorithm != "" {
+		tl.newHash = frameHashes[algorithm]
+		header = append(header[:len(header):len(header)], algorithm)
+	}
+	tl.w = csv.NewWriter(tl.buf)
+	err = tl.w.Write(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return tl, nil
+}
+
+// frameOf returns the line of an access unit. It must be called from the
+// packet callback of its track.
+func frameOf(au *accessUnit) timelineFrame {
+	fr := timelineFrame{au: au}
+	if npt := au.track.npt.Load(); npt != nil {
+		v := npt.npt(uint32(au.pts))
+		fr.npt = &v
+	}
+	return fr
+}
+
+// write appends the line of an access unit.
+func (tl *frameTimeline) write(fr timelineFrame) error {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+
+	au := fr.au
+	tl.frames[au.track.index]++
+
+	size := 0
+	for _, u := range au.units {
+		size += len(u)
+	}
+
+	var npt, ntp string
+	if fr.npt != nil {
+		npt = strconv.FormatFloat(fr.npt.Seconds(), 'f', 6, 64)
+	}
+	if !au.ntp.IsZero() {
+		ntp = au.ntp.Format(time.RFC3339Nano)
+	}
+
+	line := []string{
+		strconv.Itoa(tl.frames[au.track.index]),
+		strconv.Itoa(au.track.index),
+		strconv.FormatBool(au.keyframe),
+		strconv.FormatUint(uint64(uint32(au.pts)), 10),
+		npt,
+		ntp,
+		au.received.Format(time.RFC3339Nano),
+		strconv.Itoa(size),
+	}
+	if tl.newHash != nil {
+		line = append(line, frameChecksum(tl.newHash, au))
+	}
+	return tl.w.Write(line)
+}
+
+// close flushes and closes the file.
+func (tl *frameTimeline) close() error {
+	tl.mutex.Lock()
+	defer tl.mutex.Unlock()
+
+	tl.w.Flush()
+	err := tl.w.Error()
+	if ferr := tl.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := tl.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openFrameTimeline writes the timeline of the frames of the supported
+// tracks into the -frame-timeline-out file, with their hashes when
+// -verify-checksums is given.
+func (o *outputs) openFrameTimeline(tracks []*track) error {
+	setupDepacketizers(tracks, nil)
+	algorithm := ""
+	if o.cfg.verifyChecksums {
+		algorithm = o.cfg.checksumAlgorithm
+	}
+	timeline, err := newFrameTimeline(o.cfg.outputPath(o.cfg.frameTimelineOut), algorithm)
+	if err != nil {
+		return fmt.Errorf("creating frame timeline file: %w", err)
+	}
+	o.onClose(func() {
+		err := timeline.close()
+		if err != nil {
+			log.Printf("Error closing frame timeline file: %v", err)
+		}
+	})
+	queue := o.newQueue("frame timeline")
+
+	o.onAccessUnit(func(au *accessUnit) {
+		fr := frameOf(au)
+		queue.push(func() error {
+			return timeline.write(fr)
+		})
+	})
+	return nil
+}
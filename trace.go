@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4"
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+)
+
+// Headers whose value is masked in the trace, unless -trace-no-redact :
+var traceRedactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// traceEntry is a line of the trace file: an RTSP request or response of the
+// control channel.
+type traceEntry struct {
+	Time time.Time `json:"time"`
+	// request or response of the client, or server_request and
+	// client_response for the requests sent by the server.
+	Direction     string      `json:"direction"`
+	Method        string      `json:"method,omitempty"`
+	URL           string      `json:"url,omitempty"`
+	StatusCode    int         `json:"status_code,omitempty"`
+	StatusMessage string      `json:"status_message,omitempty"`
+	Header        base.Header `json:"header"`
+	Body          string      `json:"body,omitempty"`
+}
+
+// traceWriter appends the RTSP exchange to a file, one JSON object per
+// line. With rotation, files are numbered from the path, like MP4 files,
+// and a new one is started once rotateSize bytes were written.
+type traceWriter struct {
+	path       string
+	redact     bool
+	rotateSize int64
+
+	mutex   sync.Mutex
+	file    *os.File
+	segment int
+	written int64
+	failed  bool
+}
+
+// newTraceWriter opens the trace file at path, appending to it.
+func newTraceWriter(path string, redact bool, rotateSize int64) (*traceWriter, error) {
+	w := &traceWriter{
+		path:       path,
+		redact:     redact,
+		rotateSize: rotateSize,
+	}
+	err := w.open()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// open opens the current file.
+func (w *traceWriter) open() error {
+	path := w.path
+	if w.rotateSize > 0 {
+		path = numberedPath(w.path, w.segment)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file = f
+	w.written = fi.Size()
+	return nil
+}
+
+// attach traces the requests and responses of the client.
+func (w *traceWriter) attach(client *gortsplib.Client) {
+	client.OnRequest = func(req *base.Request) { w.writeRequest("request", req) }
+	client.OnResponse = func(res *base.Response) { w.writeResponse("response", res) }
+	client.OnServerRequest = func(req *base.Request) { w.writeRequest("server_request", req) }
+	client.OnServerResponse = func(res *base.Response) { w.writeResponse("client_response", res) }
+}
+
+// writeRequest traces a request.
+func (w *traceWriter) writeRequest(direction string, req *base.Request) {
+	e := &traceEntry{
+		Time:      time.Now(),
+		Direction: direction,
+		Method:    string(req.Method),
+		Header:    w.header(req.Header),
+		Body:      string(req.Body),
+	}
+	if req.URL != nil {
+		e.URL = req.URL.String()
+		if w.redact {
+			e.URL = (*url.URL)(req.URL).Redacted()
+		}
+	}
+	w.write(e)
+}
+
+// writeResponse traces a response.
+func (w *traceWriter) writeResponse(direction string, res *base.Response) {
+	w.write(&traceEntry{
+		Time:          time.Now(),
+		Direction:     direction,
+		StatusCode:    int(res.StatusCode),
+		StatusMessage: res.StatusMessage,
+		Header:        w.header(res.Header),
+		Body:          string(res.Body),
+	})
+}
+
+// header returns a copy of the header, with the credentials masked.
+func (w *traceWriter) header(h base.Header) base.Header {
+	c := make(base.Header, len(h))
+	for k, v := range h {
+		c[k] = v
+	}
+	if w.redact {
+		for _, k := range traceRedactedHeaders {
+			if _, ok := c[k]; ok {
+				c[k] = base.HeaderValue{"REDACTED"}
+			}
+		}
+	}
+	return c
+}
+
+// write appends an entry to the file. After an error, tracing is disabled,
+// so that the session goes on.
+func (w *traceWriter) write(e *traceEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Error marshaling trace entry to JSON: %v", err)
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.failed {
+		return
+	}
+
+	if w.rotateSize > 0 && w.written != 0 && w.written+int64(len(line))+1 > w.rotateSize {
+		err = w.file.Close()
+		if err == nil {
+			w.segment++
+			err = w.open()
+		}
+		if err != nil {
+			w.failed = true
+			log.Printf("Error rotating trace file, tracing disabled: %v", err)
+			return
+		}
+	}
+
+	n, err := w.file.Write(append(line, '\n'))
+	w.written += int64(n)
+	if err != nil {
+		w.failed = true
+		log.Printf("Error writing trace file, tracing disabled: %v", err)
+	}
+}
+
+// close closes the file.
+func (w *traceWriter) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.failed {
+		return nil
+	}
+	w.failed = true
+	return w.file.Close()
+}
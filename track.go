@@ -0,0 +1,650 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v4/pkg/base"
+	"github.com/bluenviron/gortsplib/v4/pkg/description"
+	"github.com/bluenviron/gortsplib/v4/pkg/format"
+	"github.com/bluenviron/gortsplib/v4/pkg/headers"
+	"github.com/pion/rtp"
+)
+
+// Track status values, as they appear in the final report :
+const (
+	// the track was not SETUP (not requested or SETUP failed).
+	trackStatusNotSetup = "not_setup"
+	// the track was SETUP but never received a packet.
+	trackStatusDead = "dead"
+	// the track was SETUP and received packets.
+	trackStatusOK = "ok"
+)
+
+// track is a media declared in the SDP, along with what was observed on it.
+type track struct {
+	index int
+	media *description.Media
+	// name given with -track-name, if any.
+	name string
+
+	// whether the SETUP request of this track succeeded.
+	setup bool
+	// error returned by the SETUP request, if any.
+	setupErr error
+	// transport negotiated by the SETUP request.
+	transport *headers.Transport
+	// whether the RTCP packets of the track are processed.
+	rtcp bool
+	// drift of the RTP clock, from the RTCP sender reports.
+	drift clockDrift
+	// whether the RTCP port of the track couldn't be bound, with -rtcp-optional.
+	rtcpUnavailable bool
+	// whether the SDP advertises RTP/RTCP multiplexing for the track, and
+	// whether its RTCP socket shares the port of RTP.
+	rtcpMux     bool
+	rtcpDemuxed bool
+
+	// retransmission payload types (RFC 4588), with the payload type
+	// each one retransmits.
+	rtx map[uint8]uint8
+	// puts the retransmitted packets back in sequence order for the
+	// outputs, on the tracks declaring retransmission payload types.
+	reorder *rtxReorderer
+	// encoding of the first format, from its a=rtpmap attribute or its
+	// static payload type, if any.
+	rtpmap *rtpMapping
+	// drops packets at random before the outputs, with -simulate-loss.
+	loss *lossSimulator
+	// duplicate detector, when -dedup is enabled.
+	dedup *dedupFilter
+	// requests the retransmission of lost packets, with -send-nack on
+	// tracks negotiating Generic NACK feedback.
+	nack *nackSender
+	// measures the round-trip time to the server, with -measure-rtt.
+	rtt *rttMeter
+	// normal play time clock, when the PLAY response carries RTP-Info.
+	npt atomic.Pointer[nptClock]
+	// reassembles access units, when an output needs them, or the error
+	// which prevented creating it, and the number of packets it failed on.
+	depacketizer    *depacketizer
+	depacketizerErr error
+	decodeErrors    atomic.Uint64
+	// analyzes the GOP structure, when -gop-report is enabled.
+	gop *gopAnalyzer
+	// looks for black, frozen or silent content, when -content-check is enabled.
+	content *contentChecker
+	// measures the loudness of the audio, when -measure-loudness is enabled.
+	loudness *loudnessMeter
+	// reassembles XML documents, when the track carries ONVIF metadata.
+	metadata *metadataAssembler
+	// reference and media clocks declared in the SDP (RFC 7273), if any.
+	clock *mediaClock
+	// anchor of the RTP timestamps, when given with -rtp-epoch.
+	epoch *rtpEpoch
+	// source of the absolute times of the packets, once logged.
+	timeSource string
+	// numbers packets and frames, when -normalize-timestamps is enabled.
+	normalizer *timestampNormalizer
+	// decrypts the packets, when the track is protected by SRTP.
+	srtp *srtpContext
+	// Blocksize requested with -blocksize, and returned by the server, if any.
+	blocksize         int
+	blocksizeAccepted int
+
+	mutex       sync.Mutex
+	packets     uint64
+	bytes       uint64
+	maxPayload  int
+	duplicates  uint64
+	malformed   uint64
+	padding     paddingStats
+	firstPacket time.Time
+	lastPacket  time.Time
+
+	// Sequence number and interarrival jitter state (RFC 3550) :
+	lastSSRC      uint32
+	lastSeq       uint16
+	lastTimestamp uint32
+	gaps          *gapSet
+	lost          uint64
+	recovered     uint64
+	jitter        float64
+
+	// Changes of SSRC, SSRC of the last sender report, and new source whose
+	// packets are rejected :
+	ssrcChanges uint64
+	senderSSRC  uint32
+	newSSRC     uint32
+
+	// Marker bits, and the timestamps of the first and last marked packets :
+	markers       uint64
+	markerTS      tsUnwrapper
+	firstMarkerTS int64
+	lastMarkerTS  int64
+
+	// Contributing sources of mixed streams :
+	csrcs csrcSet
+
+	// Bitrate over a sliding window, and the alerts raised when it exceeds
+	// maxBitrate, if set :
+	bitrate       bitrateMeter
+	peakBitrate   float64
+	maxBitrate    float64
+	overBitrate   bool
+	bitrateAlerts uint64
+
+	// End of the warmup after PLAY, during which startup transients are
+	// excluded from alerts and peaks. The jitter estimate restarts once
+	// the warmup is over :
+	warmupUntil time.Time
+	warmedUp    bool
+}
+
+// newTracks creates a track for every media of the session description.
+func newTracks(desc *description.Session) []*track {
+	tracks := make([]*track, len(desc.Medias))
+	for i, medi := range desc.Medias {
+		tracks[i] = &track{
+			index: i,
+			media: medi,
+			gaps:  newGapSet(gapWindow),
+			rtx:   rtxPayloadTypes(medi),
+		}
+		if tracks[i].rtx != nil {
+			tracks[i].reorder = newRTXReorderer()
+		}
+		if len(medi.Formats) != 0 {
+			tracks[i].rtpmap = parseRTPMap(medi.Formats[0])
+		}
+	}
+	return tracks
+}
+
+// codec returns the codec name of the first format of the track. Codecs
+// unknown to gortsplib are named by their rtpmap, e.g. speex/16000.
+func (t *track) codec() string {
+	if len(t.media.Formats) == 0 {
+		return "unknown"
+	}
+	if name := t.genericRTPMap(); name != "" {
+		return name
+	}
+	return t.media.Formats[0].Codec()
+}
+
+// genericRTPMap returns the rtpmap of the first format of the track when
+// gortsplib doesn't know its codec, or an empty string.
+func (t *track) genericRTPMap() string {
+	if _, ok := t.media.Formats[0].(*format.Generic); !ok || t.rtpmap == nil {
+		return ""
+	}
+	return t.rtpmap.String()
+}
+
+// String returns a human-readable name of the track, used in logs.
+func (t *track) String() string {
+	if t.name != "" {
+		return fmt.Sprintf("#%d %s (%s/%s)", t.index, t.name, t.media.Type, t.codec())
+	}
+	return fmt.Sprintf("#%d (%s/%s)", t.index, t.media.Type, t.codec())
+}
+
+// onPacket records the reception of a packet.
+// It returns the number of packets received so far, this one included.
+func (t *track) onPacket(pkt *rtp.Packet) uint64 {
+	now := time.Now()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	warmingUp := now.Before(t.warmupUntil)
+	if !warmingUp && !t.warmedUp {
+		t.warmedUp = true
+		t.jitter = 0
+	}
+
+	switch {
+	case t.packets == 0:
+		t.firstPacket = now
+		t.lastSeq = pkt.SequenceNumber
+	case pkt.SSRC != t.lastSSRC:
+		t.onSSRCChange(pkt)
+	default:
+		t.updateLoss(pkt.SequenceNumber)
+		t.updateJitter(now.Sub(t.lastPacket), pkt.Timestamp)
+	}
+	if pkt.Marker {
+		t.onMarker(pkt.Timestamp)
+	}
+	t.csrcs.push(pkt.CSRC)
+	t.padding.push(pkt)
+	t.updateBitrate(now, len(pkt.Payload), warmingUp)
+	t.packets++
+	t.bytes += uint64(len(pkt.Payload))
+	t.maxPayload = max(t.maxPayload, len(pkt.Payload))
+	t.lastPacket = now
+	t.lastSSRC = pkt.SSRC
+	t.lastTimestamp = pkt.Timestamp
+	return t.packets
+}
+
+// updateBitrate records the size of a packet, and raises an alert when the
+// bitrate goes above the maximum. Another alert is only raised once the
+// bitrate went back below it. The caller must hold the mutex.
+func (t *track) updateBitrate(now time.Time, size int, warmingUp bool) {
+	bps := t.bitrate.push(now, size)
+	if warmingUp {
+		return
+	}
+	if bps > t.peakBitrate {
+		t.peakBitrate = bps
+	}
+	if t.maxBitrate == 0 {
+		return
+	}
+
+	switch {
+	case !t.overBitrate && bps > t.maxBitrate:
+		t.overBitrate = true
+		t.bitrateAlerts++
+		log.Printf("WARNING: track %s: bitrate of %sbit/s exceeds -max-bitrate %sbit/s",
+			t, formatBitrate(math.Round(bps)), formatBitrate(t.maxBitrate))
+	case t.overBitrate && bps <= t.maxBitrate:
+		t.overBitrate = false
+		log.Printf("Track %s: bitrate back under -max-bitrate, at %sbit/s", t, formatBitrate(math.Round(bps)))
+	}
+}
+
+// startWarmup starts the warmup of the track, which lasts d.
+func (t *track) startWarmup(d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.warmupUntil = time.Now().Add(d)
+}
+
+// onMarker records a packet with the marker bit set, which ends a frame on
+// video tracks and starts a talk spurt on audio tracks.
+func (t *track) onMarker(ts uint32) {
+	v := t.markerTS.unwrap(ts)
+	if t.markers == 0 {
+		t.firstMarkerTS = v
+	}
+	t.lastMarkerTS = v
+	t.markers++
+}
+
+// markerStats returns the share of packets with the marker bit set and,
+// on video tracks, the frame rate derived from the timestamps of the
+// marked packets. The frame rate is zero when it is unknown.
+// The caller must hold the mutex.
+func (t *track) markerStats() (ratio float64, frameRate float64) {
+	if t.packets == 0 {
+		return 0, 0
+	}
+	ratio = math.Round(float64(t.markers)/float64(t.packets)*1000) / 1000
+
+	clockRate := t.media.Formats[0].ClockRate()
+	span := t.lastMarkerTS - t.firstMarkerTS
+	if t.media.Type == description.MediaTypeVideo && clockRate > 0 && span > 0 {
+		frameRate = float64(t.markers-1) * float64(clockRate) / float64(span)
+		frameRate = math.Round(frameRate*100) / 100
+	}
+	return ratio, frameRate
+}
+
+// onRetransmission records the reception of a retransmitted packet, given
+// its original sequence number. It returns whether the packet filled a gap,
+// as opposed to a packet which was already received.
+func (t *track) onRetransmission(seq uint16) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.gaps.fill(seq) {
+		return false
+	}
+	t.lost--
+	t.recovered++
+	if t.nack != nil {
+		t.nack.fill(seq)
+	}
+	return true
+}
+
+// originalSSRC returns the SSRC of the last packet of the track.
+func (t *track) originalSSRC() uint32 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.lastSSRC
+}
+
+// updateLoss counts the packets skipped between the previous sequence number
+// and seq. A late packet filling a gap counted before is not lost.
+func (t *track) updateLoss(seq uint16) {
+	diff := int16(seq - t.lastSeq)
+	switch {
+	case diff > 0:
+		for i := uint16(1); i < uint16(diff); i++ {
+			t.gaps.add(t.lastSeq + i)
+			if t.nack != nil && diff <= nackMaxGap {
+				t.nack.add(t.lastSeq + i)
+			}
+		}
+		t.lost += uint64(diff - 1)
+		t.lastSeq = seq
+	case diff < 0:
+		if t.gaps.fill(seq) {
+			t.lost--
+			if t.nack != nil {
+				t.nack.fill(seq)
+			}
+		}
+	}
+}
+
+// updateJitter updates the interarrival jitter estimate, in clock rate units,
+// from the time elapsed since the previous packet and the timestamp of this one.
+func (t *track) updateJitter(elapsed time.Duration, ts uint32) {
+	clockRate := t.media.Formats[0].ClockRate()
+	if clockRate <= 0 {
+		return
+	}
+	d := elapsed.Seconds()*float64(clockRate) - float64(int32(ts-t.lastTimestamp))
+	t.jitter += (math.Abs(d) - t.jitter) / 16
+}
+
+// jitterDuration returns the interarrival jitter estimate as a duration.
+// The caller must hold the mutex.
+func (t *track) jitterDuration() time.Duration {
+	clockRate := t.media.Formats[0].ClockRate()
+	if clockRate <= 0 {
+		return 0
+	}
+	return time.Duration(t.jitter / float64(clockRate) * float64(time.Second))
+}
+
+// onDuplicate records the reception of a duplicated packet.
+func (t *track) onDuplicate() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.duplicates++
+}
+
+// onMalformed records the reception of a malformed packet.
+func (t *track) onMalformed() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.malformed++
+}
+
+// packetCount returns the number of packets received so far.
+func (t *track) packetCount() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.packets
+}
+
+// status returns the status of the track, one of the trackStatus values.
+func (t *track) status() string {
+	switch {
+	case !t.setup:
+		return trackStatusNotSetup
+	case t.packetCount() == 0:
+		return trackStatusDead
+	default:
+		return trackStatusOK
+	}
+}
+
+// onSetup records the outcome of the SETUP request of the track.
+func (t *track) onSetup(res *base.Response, err error) {
+	if err != nil {
+		t.setupErr = err
+		return
+	}
+	t.setup = true
+
+	var th headers.Transport
+	if th.Unmarshal(res.Header["Transport"]) == nil {
+		t.transport = &th
+	}
+}
+
+// channels returns the interleaved channels of the RTP and RTCP packets of
+// the track, when it is streamed over the RTSP connection. They are unknown
+// after an automatic switch to TCP during PLAY, which SETUPs again.
+func (t *track) channels() *[2]int {
+	if t.transport == nil || t.transport.Protocol != headers.TransportProtocolTCP {
+		return nil
+	}
+	return t.transport.InterleavedIDs
+}
+
+// rtpChannel returns the interleaved channel of the RTP packets of the
+// track, or nil when it is not streamed over TCP.
+func (t *track) rtpChannel() *int {
+	if ch := t.channels(); ch != nil {
+		return &ch[0]
+	}
+	return nil
+}
+
+// logChannels logs the interleaved channels of the SETUP tracks streamed
+// over TCP, to correlate the output with packet captures.
+func logChannels(tracks []*track) {
+	for _, t := range tracks {
+		if ch := t.channels(); ch != nil {
+			log.Printf("Track %s is interleaved on channel %d (RTP) and %d (RTCP)", t, ch[0], ch[1])
+		}
+	}
+}
+
+// transportReport describes the transport negotiated for a track.
+type transportReport struct {
+	Protocol    string  `json:"protocol"`
+	Delivery    string  `json:"delivery,omitempty"`
+	ClientPorts *[2]int `json:"client_ports,omitempty"`
+	ServerPorts *[2]int `json:"server_ports,omitempty"`
+	Interleaved *[2]int `json:"interleaved,omitempty"`
+	Destination string  `json:"destination,omitempty"`
+	SSRC        *uint32 `json:"ssrc,omitempty"`
+	Header      string  `json:"header"`
+}
+
+// setupReport is the per-track section of the setup summary.
+type setupReport struct {
+	Index     int              `json:"index"`
+	Name      string           `json:"name,omitempty"`
+	Type      string           `json:"type"`
+	Codec     string           `json:"codec"`
+	Selected  bool             `json:"selected"`
+	Setup     bool             `json:"setup"`
+	Error     string           `json:"error,omitempty"`
+	Transport *transportReport `json:"transport,omitempty"`
+	// whether the SDP advertises rtcp-mux, and whether the server applies
+	// it, sending RTP and RTCP from the same port.
+	RTCPMux           bool `json:"rtcp_mux,omitempty"`
+	RTCPMuxNegotiated bool `json:"rtcp_mux_negotiated,omitempty"`
+	// whether RTCP is received on the RTP port, demultiplexed from RTP.
+	RTCPDemuxed bool `json:"rtcp_demuxed,omitempty"`
+	// whether the RTCP port couldn't be bound, the track going on without
+	// RTCP.
+	RTCPUnavailable bool `json:"rtcp_unavailable,omitempty"`
+}
+
+// setupReport returns the outcome of the SETUP of the track.
+func (t *track) setupReport(selected bool) setupReport {
+	r := setupReport{
+		Index:    t.index,
+		Name:     t.name,
+		Type:     string(t.media.Type),
+		Codec:    t.codec(),
+		Selected: selected,
+		Setup:    t.setup,
+
+		RTCPMux:           t.rtcpMux,
+		RTCPMuxNegotiated: t.rtcpMuxNegotiated(),
+		RTCPDemuxed:       t.rtcpDemuxed,
+		RTCPUnavailable:   t.rtcpUnavailable,
+	}
+	if t.setupErr != nil {
+		r.Error = t.setupErr.Error()
+	}
+
+	if th := t.transport; th != nil {
+		tr := &transportReport{
+			Protocol:    th.Protocol.String(),
+			ClientPorts: th.ClientPorts,
+			ServerPorts: th.ServerPorts,
+			Interleaved: th.InterleavedIDs,
+			SSRC:        th.SSRC,
+			Header:      th.Marshal()[0],
+		}
+		if th.Delivery != nil {
+			tr.Delivery = th.Delivery.String()
+		}
+		if th.Destination != nil {
+			tr.Destination = th.Destination.String()
+		}
+		r.Transport = tr
+	}
+	return r
+}
+
+// trackReport is the per-track section of the final report.
+type trackReport struct {
+	Index       int     `json:"index"`
+	Name        string  `json:"name,omitempty"`
+	Type        string  `json:"type"`
+	Codec       string  `json:"codec"`
+	Status      string  `json:"status"`
+	Packets     uint64  `json:"packets"`
+	Bytes       uint64  `json:"bytes"`
+	Duplicates  uint64  `json:"duplicates"`
+	Malformed   uint64  `json:"malformed"`
+	Lost        uint64  `json:"lost"`
+	Recovered   uint64  `json:"recovered"`
+	JitterMS    float64 `json:"jitter_ms"`
+	Markers     uint64  `json:"markers"`
+	MarkerRatio float64 `json:"marker_ratio"`
+	// zero when the track is not video, or does not use the marker bit.
+	FrameRate   float64    `json:"frame_rate,omitempty"`
+	FirstPacket *time.Time `json:"first_packet,omitempty"`
+	LastPacket  *time.Time `json:"last_packet,omitempty"`
+	GOP         *gopReport `json:"gop,omitempty"`
+	// integrated loudness and true peak, with -measure-loudness.
+	Loudness *loudnessReport `json:"loudness,omitempty"`
+	// Generic NACKs sent with -send-nack, and the packets they recovered.
+	NACK *nackReport `json:"nack,omitempty"`
+	// round-trip time to the server, with -measure-rtt.
+	RTT *rttReport `json:"rtt,omitempty"`
+	// contributing sources, when the track is a mix of sources.
+	Mix *mixReport `json:"mix,omitempty"`
+	// padded packets, when some are.
+	Padding *paddingReport `json:"padding,omitempty"`
+	// highest bitrate over the sliding window, and number of times it
+	// exceeded -max-bitrate.
+	PeakBitrate   float64 `json:"peak_bitrate_bps"`
+	BitrateAlerts uint64  `json:"bitrate_alerts,omitempty"`
+	// number of SSRC changes.
+	SSRCChanges uint64 `json:"ssrc_changes,omitempty"`
+	// packets dropped before the outputs with -simulate-loss.
+	SimulatedLosses uint64 `json:"simulated_losses,omitempty"`
+	// whether the RTP timestamps are locked to a reference clock (RFC 7273).
+	SynchronizedClock bool `json:"synchronized_clock,omitempty"`
+	// drift of the RTP clock against the NTP clock of the server, in ppm,
+	// once RTCP sender reports span long enough.
+	ClockDriftPPM *float64 `json:"clock_drift_ppm,omitempty"`
+	// SRTP crypto suite of the track, and number of packets dropped because
+	// they could not be authenticated.
+	SRTP             string `json:"srtp,omitempty"`
+	SRTPAuthFailures uint64 `json:"srtp_auth_failures,omitempty"`
+	// payload sizes against the Blocksize requested with -blocksize.
+	Blocksize *blocksizeReport `json:"blocksize,omitempty"`
+	// packets on which the depacketizer failed.
+	DecodeErrors uint64 `json:"decode_errors,omitempty"`
+}
+
+// report returns a snapshot of the track for the final report.
+func (t *track) report() trackReport {
+	r := trackReport{
+		Index:  t.index,
+		Name:   t.name,
+		Type:   string(t.media.Type),
+		Codec:  t.codec(),
+		Status: t.status(),
+
+		SynchronizedClock: t.clock != nil && t.clock.Synchronized,
+	}
+	if ppm, ok := t.drift.ppm(t.media.Formats[0].ClockRate()); ok {
+		r.ClockDriftPPM = &ppm
+	}
+	if t.loudness != nil {
+		r.Loudness = t.loudness.report()
+	}
+	if t.srtp != nil {
+		r.SRTP = t.srtp.suite.name
+		r.SRTPAuthFailures = t.srtp.authFailures.Load()
+	}
+
+	if t.loss != nil {
+		r.SimulatedLosses = t.loss.dropped.Load()
+	}
+	r.DecodeErrors = t.decodeErrors.Load()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r.Packets = t.packets
+	r.Bytes = t.bytes
+	r.Duplicates = t.duplicates
+	r.Malformed = t.malformed
+	r.Lost = t.lost
+	r.Recovered = t.recovered
+	r.Markers = t.markers
+	r.MarkerRatio, r.FrameRate = t.markerStats()
+	r.Mix = t.csrcs.report()
+	r.Padding = t.padding.report(t.packets, t.srtp != nil)
+	r.PeakBitrate = math.Round(t.peakBitrate)
+	r.BitrateAlerts = t.bitrateAlerts
+	r.SSRCChanges = t.ssrcChanges
+	if t.blocksize != 0 {
+		r.Blocksize = newBlocksizeReport(t.blocksize, t.blocksizeAccepted, t.maxPayload)
+	}
+	if t.gop != nil {
+		r.GOP = t.gop.report()
+	}
+	if t.nack != nil {
+		r.NACK = t.nack.report()
+	}
+	if t.rtt != nil {
+		r.RTT = t.rtt.report()
+	}
+	r.JitterMS = durationMS(t.jitterDuration())
+	if t.packets != 0 {
+		first, last := t.firstPacket, t.lastPacket
+		r.FirstPacket = &first
+		r.LastPacket = &last
+	}
+	return r
+}
+
+// warnDeadTracks logs a warning for every SETUP track which has not received
+// any packet. It is meant to be called once the stall timeout has elapsed
+// after PLAY, to tell apart tracks which are advertised but never sent by
+// the server from tracks which were not requested at all. It returns whether
+// it warned.
+func warnDeadTracks(tracks []*track, stallTimeout time.Duration) bool {
+	warned := false
+	for _, t := range tracks {
+		if t.setup && t.packetCount() == 0 {
+			log.Printf("WARNING: track %s was SETUP but received no packet within %v: advertised but dead", t, stallTimeout)
+			warned = true
+		}
+	}
+	return warned
+}
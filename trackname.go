@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// trackNameFlag is a flag holding the names given to some tracks, by track
+// index, in the index=name form.
+type trackNameFlag map[int]string
+
+// String implements flag.Value.
+func (m *trackNameFlag) String() string {
+	indexes := make([]int, 0, len(*m))
+	for i := range *m {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	entries := make([]string, len(indexes))
+	for j, i := range indexes {
+		entries[j] = strconv.Itoa(i) + "=" + (*m)[i]
+	}
+	return strings.Join(entries, ",")
+}
+
+// Set implements flag.Value. It can be given several times, or with a
+// comma-separated list. Names are restricted to letters, digits, dots,
+// dashes and underscores, so that they can be used as is in metric tags and
+// file names downstream.
+func (m *trackNameFlag) Set(s string) error {
+	if *m == nil {
+		*m = make(trackNameFlag)
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		indexStr, name, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid track name %q: expected index=name", entry)
+		}
+
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 {
+			return fmt.Errorf("invalid track index %q", indexStr)
+		}
+
+		for _, r := range name {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+				r == '.' || r == '-' || r == '_') {
+				return fmt.Errorf("invalid track name %q: only letters, digits, '.', '-' and '_' are allowed", name)
+			}
+		}
+
+		(*m)[index] = name
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// Warnings about malformed packets are rate limited: after the first
+// validationBurst ones, at most one is logged every validationInterval :
+const (
+	validationBurst    = 10
+	validationInterval = 10 * time.Second
+)
+
+// rtpProblems returns the structural problems of a packet, or nil when the
+// packet is sane.
+func rtpProblems(pkt *rtp.Packet) []string {
+	var problems []string
+
+	if pkt.Version != 2 {
+		problems = append(problems, fmt.Sprintf("version is %d instead of 2", pkt.Version))
+	}
+
+	// Payload types 72 to 76 would be mistaken for RTCP (RFC 5761) :
+	if pkt.PayloadType >= 72 && pkt.PayloadType <= 76 {
+		problems = append(problems, fmt.Sprintf("payload type %d conflicts with RTCP packet types", pkt.PayloadType))
+	}
+
+	// Padding-only packets are legitimate, empty ones are not :
+	if len(pkt.Payload) == 0 && !pkt.Padding {
+		problems = append(problems, "payload is empty")
+	}
+	if pkt.Padding && pkt.PaddingSize == 0 {
+		problems = append(problems, "padding bit is set but the padding length is zero")
+	}
+
+	seen := make(map[uint32]struct{}, len(pkt.CSRC))
+	for _, csrc := range pkt.CSRC {
+		if _, ok := seen[csrc]; ok {
+			problems = append(problems, fmt.Sprintf("CSRC %d is listed twice", csrc))
+		}
+		seen[csrc] = struct{}{}
+	}
+
+	if pkt.Extension {
+		isRFC8285 := pkt.ExtensionProfile == 0xBEDE || pkt.ExtensionProfile&0xFFF0 == 0x1000
+		switch {
+		case isRFC8285 && len(pkt.GetExtensionIDs()) == 0:
+			problems = append(problems, "extension header carries no extension element")
+		case !isRFC8285 && len(pkt.Extensions) == 1 && len(pkt.GetExtension(0)) == 0:
+			problems = append(problems, fmt.Sprintf("extension header with profile 0x%04x is empty", pkt.ExtensionProfile))
+		}
+	} else if len(pkt.Extensions) != 0 {
+		problems = append(problems, "extension elements are present but the extension bit is not set")
+	}
+
+	return problems
+}
+
+// rtpValidator checks the structure of every packet, counts the malformed
+// ones on their track and logs them, with a rate limit.
+type rtpValidator struct {
+	mutex      sync.Mutex
+	logged     int
+	lastLogged time.Time
+	suppressed int
+}
+
+// check validates a packet of a track.
+func (v *rtpValidator) check(t *track, pkt *rtp.Packet) {
+	problems := rtpProblems(pkt)
+	if problems == nil {
+		return
+	}
+	t.onMalformed()
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	now := time.Now()
+	if v.logged >= validationBurst && now.Sub(v.lastLogged) < validationInterval {
+		v.suppressed++
+		return
+	}
+
+	suffix := ""
+	if v.suppressed != 0 {
+		suffix = fmt.Sprintf(" (%d similar warnings suppressed)", v.suppressed)
+	}
+	log.Printf("WARNING: malformed RTP packet on track %s, sequence number %d: %s%s",
+		t, pkt.SequenceNumber, strings.Join(problems, "; "), suffix)
+
+	v.logged++
+	v.lastLogged = now
+	v.suppressed = 0
+}
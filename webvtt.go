@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// duration of the cues: metadata documents are instantaneous, so a cue is
+// displayed for a fixed time, overlapping the next ones if needed.
+const webvttCueDuration = 2 * time.Second
+
+// onvifMetadata is the part of an ONVIF MetadataStream document turned into
+// captions: the objects detected by video analytics, and the events.
+// Elements are matched by local name, whatever their namespace prefix.
+type onvifMetadata struct {
+	Frames        []onvifFrame        `xml:"VideoAnalytics>Frame"`
+	Notifications []onvifNotification `xml:"Event>NotificationMessage"`
+}
+
+// onvifFrame lists the objects detected in a video frame.
+type onvifFrame struct {
+	Objects []onvifObject `xml:"Object"`
+}
+
+// onvifObject is an object detected by video analytics, with its classes
+// in the syntax of ONVIF 2.x or of later versions.
+type onvifObject struct {
+	ID         string   `xml:"ObjectId,attr"`
+	Types      []string `xml:"Appearance>Class>Type"`
+	Candidates []string `xml:"Appearance>Class>ClassCandidate>Type"`
+}
+
+// onvifNotification is an event, such as motion or a tampering alarm.
+type onvifNotification struct {
+	Topic   string            `xml:"Topic"`
+	Sources []onvifSimpleItem `xml:"Message>Message>Source>SimpleItem"`
+	Data    []onvifSimpleItem `xml:"Message>Message>Data>SimpleItem"`
+}
+
+// onvifSimpleItem is a name and value pair of an event.
+type onvifSimpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+// metadataCaptions returns the text of the captions of a metadata document:
+// a line per event, and a line per video frame with detected objects.
+func metadataCaptions(doc []byte) ([]string, error) {
+	var m onvifMetadata
+	err := xml.Unmarshal(doc, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, n := range m.Notifications {
+		line := onvifTopic(n.Topic)
+		if items := formatSimpleItems(n.Data); items != "" {
+			line += ": " + items
+		}
+		if items := formatSimpleItems(n.Sources); items != "" {
+			line += " (" + items + ")"
+		}
+		lines = append(lines, line)
+	}
+
+	for _, f := range m.Frames {
+		if len(f.Objects) == 0 {
+			continue
+		}
+		classes := make(map[string]struct{})
+		for _, o := range f.Objects {
+			for _, c := range append(o.Types, o.Candidates...) {
+				classes[strings.TrimSpace(c)] = struct{}{}
+			}
+		}
+		delete(classes, "")
+		line := fmt.Sprintf("%d objects", len(f.Objects))
+		if len(classes) != 0 {
+			names := make([]string, 0, len(classes))
+			for c := range classes {
+				names = append(names, c)
+			}
+			sort.Strings(names)
+			line += ": " + strings.Join(names, ", ")
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+// onvifTopic returns an event topic without the namespace prefixes of its
+// levels, as in RuleEngine/CellMotionDetector/Motion.
+func onvifTopic(topic string) string {
+	levels := strings.Split(strings.TrimSpace(topic), "/")
+	for i, level := range levels {
+		if _, name, ok := strings.Cut(level, ":"); ok {
+			levels[i] = name
+		}
+	}
+	return strings.Join(levels, "/")
+}
+
+// formatSimpleItems returns name=value pairs separated by commas.
+func formatSimpleItems(items []onvifSimpleItem) string {
+	pairs := make([]string, len(items))
+	for i, item := range items {
+		pairs[i] = item.Name + "=" + item.Value
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// webvttWriter writes the captions of metadata documents into a WebVTT
+// file. Cues are timed from the NTP time of the documents, relative to the
+// first NTP time known on any track, so that they follow the media timeline.
+type webvttWriter struct {
+	mutex sync.Mutex
+	file  *os.File
+	w     *bufio.Writer
+
+	origin time.Time
+	cues   int
+	// number of documents received before their track had an NTP mapping :
+	unmapped uint64
+}
+
+// newWebVTTWriter creates the WebVTT file at path.
+func newWebVTTWriter(path string) (*webvttWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &webvttWriter{
+		file: f,
+		w:    bufio.NewWriter(f),
+	}
+	_, err = w.w.WriteString("WEBVTT\n\n")
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// start sets the origin of the timeline, when it is not already set.
+func (w *webvttWriter) start(ntp time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.origin.IsZero() && !ntp.IsZero() {
+		w.origin = ntp
+	}
+}
+
+// write appends the cue of a metadata document of a track, received at
+// the given NTP time. Documents without captions are skipped.
+func (w *webvttWriter) write(t *track, doc []byte, ntp time.Time) error {
+	lines, err := metadataCaptions(doc)
+	if err != nil {
+		log.Printf("WARNING: track %s: can't parse metadata document: %v", t, err)
+		return nil
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if ntp.IsZero() || w.origin.IsZero() {
+		w.unmapped++
+		if w.unmapped == 1 {
+			log.Printf("WARNING: track %s: metadata received before the first RTCP sender report, "+
+				"it is not written to WebVTT", t)
+		}
+		return nil
+	}
+
+	begin := max(ntp.Sub(w.origin), 0)
+	escaper := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	_, err = fmt.Fprintf(w.w, "%s --> %s\n%s\n\n",
+		formatWebVTTTime(begin), formatWebVTTTime(begin+webvttCueDuration),
+		escaper.Replace(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	w.cues++
+	return nil
+}
+
+// formatWebVTTTime formats a cue timestamp, as hh:mm:ss.ttt.
+func formatWebVTTTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// close flushes and closes the file, and logs how many cues were written.
+func (w *webvttWriter) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	log.Printf("%d WebVTT cues written into %s (%d metadata documents without timing skipped)",
+		w.cues, w.file.Name(), w.unmapped)
+
+	err := w.w.Flush()
+	cerr := w.file.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openWebVTT turns the documents of the metadata tracks into the captions
+// of the -webvtt-out file.
+func (o *outputs) openWebVTT([]*track) error {
+	webvttOut, err := newWebVTTWriter(o.cfg.outputPath(o.cfg.webvttOut))
+	if err != nil {
+		return fmt.Errorf("creating WebVTT file: %w", err)
+	}
+	o.onClose(func() {
+		err := webvttOut.close()
+		if err != nil {
+			log.Printf("Error closing WebVTT file: %v", err)
+		}
+	})
+	o.webvtt = webvttOut
+	queue := o.newQueue("WebVTT")
+
+	o.onDocument(func(t *track, doc []byte, ntp time.Time) {
+		queue.push(func() error {
+			err := webvttOut.write(t, doc, ntp)
+			if err != nil {
+				log.Printf("Error writing WebVTT of track %s: %v", t, err)
+			}
+			return nil
+		})
+	})
+	return nil
+}
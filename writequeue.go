@@ -0,0 +1,155 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Policies applied when the queue of an output is full :
+const (
+	// The write is dropped, so that reception is never delayed :
+	writeOverflowDrop = "drop"
+
+	// The packet callback waits for the output, so that nothing is lost,
+	// at the risk of losing packets in the network instead :
+	writeOverflowBlock = "block"
+)
+
+// writeQueue runs the writes of an output from its own goroutine, so that
+// slow storage doesn't stall the packet callback. Up to size writes are
+// queued; beyond, writes are dropped or the caller waits, depending on the
+// overflow policy. Writes queued after close are discarded.
+type writeQueue struct {
+	name  string
+	block bool
+	jobs  chan func() error
+	done  chan struct{}
+
+	// The writes waiting for a free slot, with the block policy, don't hold
+	// the mutex: they are counted in waiting, and give up once stop is
+	// closed, before jobs is :
+	mutex   sync.RWMutex
+	closed  bool
+	stop    chan struct{}
+	waiting sync.WaitGroup
+
+	dropped atomic.Uint64
+	blocked atomic.Uint64
+	failed  atomic.Bool
+}
+
+// newWriteQueue starts the goroutine of an output, queuing up to size writes.
+func newWriteQueue(name string, size int, overflow string) *writeQueue {
+	q := &writeQueue{
+		name:  name,
+		block: overflow == writeOverflowBlock,
+		jobs:  make(chan func() error, size),
+		done:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// run executes the queued writes, until the queue is closed. After an error,
+// the output is disabled and the following writes are discarded. When the
+// output is a pipe whose reader went away, pipeClosed ends the capture.
+func (q *writeQueue) run() {
+	defer close(q.done)
+
+	for job := range q.jobs {
+		if q.failed.Load() {
+			continue
+		}
+		err := job()
+		switch {
+		case isBrokenPipe(err):
+			log.Printf("Reader of the %s output went away, disabling it", q.name)
+			q.failed.Store(true)
+			pipeClosedOnce.Do(func() { close(pipeClosed) })
+		case err != nil:
+			log.Printf("Error writing to %s output, disabling it: %v", q.name, err)
+			q.failed.Store(true)
+		}
+	}
+}
+
+// push queues a write. When the queue is full, the write is dropped, or
+// push waits for a free slot with the block policy, until the queue is
+// closed.
+func (q *writeQueue) push(job func() error) {
+	q.mutex.RLock()
+	if q.closed || q.failed.Load() {
+		q.mutex.RUnlock()
+		return
+	}
+
+	select {
+	case q.jobs <- job:
+		q.mutex.RUnlock()
+		return
+	default:
+	}
+
+	if !q.block {
+		q.mutex.RUnlock()
+		if q.dropped.Add(1) == 1 {
+			log.Printf("WARNING: %s output can't keep up, dropping writes", q.name)
+		}
+		return
+	}
+
+	q.waiting.Add(1)
+	q.mutex.RUnlock()
+	defer q.waiting.Done()
+
+	if q.blocked.Add(1) == 1 {
+		log.Printf("WARNING: %s output can't keep up, delaying reception", q.name)
+	}
+	select {
+	case q.jobs <- job:
+	case <-q.stop:
+	}
+}
+
+// close waits for the queued writes. It can be called several times.
+func (q *writeQueue) close() {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.stop)
+	q.mutex.Unlock()
+
+	q.waiting.Wait()
+	close(q.jobs)
+	<-q.done
+
+	if n := q.dropped.Load(); n != 0 {
+		log.Printf("WARNING: %s output dropped %d writes", q.name, n)
+	}
+	if n := q.blocked.Load(); n != 0 {
+		log.Printf("WARNING: %s output delayed reception %d times", q.name, n)
+	}
+}
+
+// outputReport is the outcome of the queue of an output.
+type outputReport struct {
+	Name    string `json:"name"`
+	Dropped uint64 `json:"dropped"`
+	Blocked uint64 `json:"blocked,omitempty"`
+	Failed  bool   `json:"failed,omitempty"`
+}
+
+// report returns the counters of the queue.
+func (q *writeQueue) report() outputReport {
+	return outputReport{
+		Name:    q.name,
+		Dropped: q.dropped.Load(),
+		Blocked: q.blocked.Load(),
+		Failed:  q.failed.Load(),
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the writes run by a queue.
+type recorder struct {
+	mutex sync.Mutex
+	ran   []int
+}
+
+// job returns a write recording n once release, when given, is closed.
+func (r *recorder) job(n int, release <-chan struct{}) func() error {
+	return func() error {
+		if release != nil {
+			<-release
+		}
+		r.mutex.Lock()
+		r.ran = append(r.ran, n)
+		r.mutex.Unlock()
+		return nil
+	}
+}
+
+// fillQueue pushes a write holding the goroutine of q until release is
+// closed, then a write filling its single slot.
+func fillQueue(t *testing.T, q *writeQueue, r *recorder, release <-chan struct{}) {
+	started := make(chan struct{})
+	q.push(func() error {
+		close(started)
+		return r.job(1, release)()
+	})
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the first write didn't start")
+	}
+	q.push(r.job(2, nil))
+}
+
+func TestWriteQueueDrop(t *testing.T) {
+	r := &recorder{}
+	q := newWriteQueue("test", 1, writeOverflowDrop)
+	release := make(chan struct{})
+	fillQueue(t, q, r, release)
+
+	q.push(r.job(3, nil))
+	q.push(r.job(4, nil))
+	close(release)
+	q.close()
+
+	if !slices.Equal(r.ran, []int{1, 2}) {
+		t.Errorf("ran %v, want [1 2]", r.ran)
+	}
+	if got := q.report(); got.Dropped != 2 || got.Blocked != 0 {
+		t.Errorf("report %+v, want 2 dropped", got)
+	}
+}
+
+func TestWriteQueueBlock(t *testing.T) {
+	r := &recorder{}
+	q := newWriteQueue("test", 1, writeOverflowBlock)
+	release := make(chan struct{})
+	fillQueue(t, q, r, release)
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push(r.job(3, nil))
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push didn't wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push didn't return once a slot was free")
+	}
+	q.close()
+
+	if !slices.Equal(r.ran, []int{1, 2, 3}) {
+		t.Errorf("ran %v, want [1 2 3]", r.ran)
+	}
+	if got := q.report(); got.Dropped != 0 || got.Blocked != 1 {
+		t.Errorf("report %+v, want 1 blocked", got)
+	}
+}
+
+func TestWriteQueueCloseWhileBlocked(t *testing.T) {
+	r := &recorder{}
+	q := newWriteQueue("test", 1, writeOverflowBlock)
+	release := make(chan struct{})
+	fillQueue(t, q, r, release)
+
+	pushed := make(chan struct{})
+	go func() {
+		q.push(r.job(3, nil))
+		close(pushed)
+	}()
+	for q.blocked.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// close gives up the blocked write, without waiting for the output to
+	// free a slot :
+	closed := make(chan struct{})
+	go func() {
+		q.close()
+		close(closed)
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("push didn't return on close")
+	}
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("close didn't return")
+	}
+
+	if !slices.Equal(r.ran, []int{1, 2}) {
+		t.Errorf("ran %v, want [1 2]", r.ran)
+	}
+
+	// Writes after close are discarded, and close can be called again :
+	q.push(r.job(4, nil))
+	q.close()
+	if len(r.ran) != 2 {
+		t.Errorf("ran %v after close", r.ran)
+	}
+}
+
+func TestWriteQueueFailure(t *testing.T) {
+	r := &recorder{}
+	q := newWriteQueue("test", 4, writeOverflowDrop)
+	q.push(func() error { return errors.New("disk full") })
+	q.push(r.job(1, nil))
+	q.close()
+
+	if len(r.ran) != 0 {
+		t.Errorf("ran %v after a failure", r.ran)
+	}
+	if !q.report().Failed {
+		t.Error("the output isn't reported as failed")
+	}
+}